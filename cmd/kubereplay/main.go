@@ -4,7 +4,12 @@ import (
 	"os"
 
 	"github.com/joinnis/kubereplay/pkg/cmd/describe"
+	"github.com/joinnis/kubereplay/pkg/cmd/diff"
 	"github.com/joinnis/kubereplay/pkg/cmd/get"
+	"github.com/joinnis/kubereplay/pkg/cmd/history"
+	"github.com/joinnis/kubereplay/pkg/cmd/latency"
+	"github.com/joinnis/kubereplay/pkg/cmd/timeline"
+	"github.com/joinnis/kubereplay/pkg/cmd/tree"
 	"github.com/spf13/cobra"
 )
 
@@ -27,12 +32,32 @@ Examples:
   kubereplay get node i-0871709ffb35ae35b -g /aws/eks/cluster-name/audit
   
   # Get node events from audit logs
-  kubereplay describe node i-0871709ffb35ae35b -g /aws/eks/cluster-name/audit`,
+  kubereplay describe node i-0871709ffb35ae35b -g /aws/eks/cluster-name/audit
+
+  # List every recorded revision of a pod
+  kubereplay history pod my-pod -n default -f /path/to/audit.log
+
+  # Show what changed on a node between two times
+  kubereplay diff node i-0871709ffb35ae35b -g /aws/eks/cluster-name/audit --from 2025-09-15T15:00:00Z --to 2025-09-15T16:00:00Z
+
+  # Show a deployment's ReplicaSets, their pods and the nodes the pods ran on
+  kubereplay tree deployment my-deployment -n default -f /path/to/audit.log
+
+  # Merge the events of a pod and the node it ran on into one timeline
+  kubereplay timeline pod/default/my-pod node/i-0871709ffb35ae35b -g /aws/eks/cluster-name/audit
+
+  # Get the scheduling and startup latency percentiles of the pods on each node
+  kubereplay latency pods -A --group-by node -f /path/to/audit.log`,
 }
 
 func init() {
 	root.AddCommand(describe.Cmd)
+	root.AddCommand(diff.Cmd)
 	root.AddCommand(get.Cmd)
+	root.AddCommand(history.Cmd)
+	root.AddCommand(latency.Cmd)
+	root.AddCommand(timeline.Cmd)
+	root.AddCommand(tree.Cmd)
 }
 
 func main() {
@@ -1,24 +1,26 @@
 package audit
 
 import (
+	"encoding/json"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type Event struct {
-	Kind                     string                 `json:"kind"`
-	APIVersion               string                 `json:"apiVersion"`
-	Level                    string                 `json:"level"`
-	AuditID                  string                 `json:"auditID"`
-	Stage                    string                 `json:"stage"`
-	RequestURI               string                 `json:"requestURI"`
-	Verb                     string                 `json:"verb"`
-	User                     User                   `json:"user"`
-	ObjectRef                *ObjectReference       `json:"objectRef,omitempty"`
-	ResponseStatus           *metav1.Status         `json:"responseStatus,omitempty"`
-	RequestObject            map[string]interface{} `json:"requestObject,omitempty"`
-	ResponseObject           map[string]interface{} `json:"responseObject,omitempty"`
-	RequestReceivedTimestamp metav1.Time            `json:"requestReceivedTimestamp"`
-	StageTimestamp           metav1.Time            `json:"stageTimestamp"`
+	Kind                     string           `json:"kind"`
+	APIVersion               string           `json:"apiVersion"`
+	Level                    string           `json:"level"`
+	AuditID                  string           `json:"auditID"`
+	Stage                    string           `json:"stage"`
+	RequestURI               string           `json:"requestURI"`
+	Verb                     string           `json:"verb"`
+	User                     User             `json:"user"`
+	ObjectRef                *ObjectReference `json:"objectRef,omitempty"`
+	ResponseStatus           *metav1.Status   `json:"responseStatus,omitempty"`
+	RequestObject            json.RawMessage  `json:"requestObject,omitempty"`
+	ResponseObject           json.RawMessage  `json:"responseObject,omitempty"`
+	RequestReceivedTimestamp metav1.Time      `json:"requestReceivedTimestamp"`
+	StageTimestamp           metav1.Time      `json:"stageTimestamp"`
 }
 
 type User struct {
@@ -30,6 +32,7 @@ type ObjectReference struct {
 	Resource        string `json:"resource"`
 	Namespace       string `json:"namespace"`
 	Name            string `json:"name"`
+	Subresource     string `json:"subresource"`
 	UID             string `json:"uid"`
 	APIGroup        string `json:"apiGroup"`
 	APIVersion      string `json:"apiVersion"`
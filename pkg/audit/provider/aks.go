@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// aksAuditCategories are the diagnostic log categories that hold kube-apiserver audit events.
+// kube-audit-admin leaves out get and list requests.
+var aksAuditCategories = sets.New("kube-audit", "kube-audit-admin")
+
+// AKS reads the kube-audit diagnostic logs of AKS clusters that Azure Monitor exported, like the
+// PT1H.json blobs that a diagnostic setting archives to a storage account. Each record wraps the
+// audit event that kube-apiserver logged in an envelope, which is unwrapped so that the parsers work
+// on AKS clusters unchanged.
+type AKS struct {
+	*File
+}
+
+// NewAKS returns a provider for the exported diagnostic logs at logPaths, which can be anything
+// that NewFile takes. Directories are walked recursively, so that a storage account's
+// resourceId=/.../y=/m=/d=/h=/m= hierarchy can be read from where it was downloaded to.
+func NewAKS(logPaths ...string) (*AKS, error) {
+	var expanded []string
+	for _, logPath := range logPaths {
+		info, err := os.Stat(logPath)
+		if err != nil || !info.IsDir() {
+			expanded = append(expanded, logPath)
+			continue
+		}
+		if err := filepath.WalkDir(logPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				expanded = append(expanded, path)
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("reading audit log directory %s, %w", logPath, err)
+		}
+	}
+	f, err := newFile(aksFormat{}, expanded...)
+	if err != nil {
+		return nil, err
+	}
+	return &AKS{File: f}, nil
+}
+
+// diagnosticRecord is a record of an Azure Monitor diagnostic log, either as a diagnostic setting
+// archives it, with the audit event in properties.log, or as a row of the AzureDiagnostics table,
+// with the audit event in log_s. Exports of a whole batch wrap their records in records.
+type diagnosticRecord struct {
+	// Category also matches the Category column of AzureDiagnostics rows, as field names are matched
+	// case-insensitively
+	Category   string `json:"category"`
+	Properties struct {
+		Log string `json:"log"`
+	} `json:"properties"`
+	LogS    string            `json:"log_s"`
+	Records []json.RawMessage `json:"records,omitempty"`
+}
+
+// log returns the audit event that the record wraps, or nil for records of other categories
+func (r diagnosticRecord) log() []byte {
+	if !aksAuditCategories.Has(r.Category) {
+		return nil
+	}
+	return []byte(lo.CoalesceOrEmpty(r.Properties.Log, r.LogS))
+}
+
+// aksFormat unwraps audit events from Azure Monitor diagnostic records
+type aksFormat struct{}
+
+func (aksFormat) header(data []byte) (eventHeader, error) {
+	var record diagnosticRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return eventHeader{}, err
+	}
+	if record.Records != nil {
+		return eventHeader{Items: record.Records}, nil
+	}
+	log := record.log()
+	if len(log) == 0 {
+		return eventHeader{}, nil
+	}
+	header, err := kubernetesFormat{}.header(log)
+	if err != nil {
+		return eventHeader{}, fmt.Errorf("decoding audit event in %s record, %w", record.Category, err)
+	}
+	return header, nil
+}
+
+func (aksFormat) event(data []byte) (auditmodel.Event, error) {
+	var record diagnosticRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return auditmodel.Event{}, err
+	}
+	event, err := kubernetesFormat{}.event(record.log())
+	if err != nil {
+		return auditmodel.Event{}, fmt.Errorf("decoding audit event in %s record, %w", record.Category, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/object"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var aksStart = time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+
+// aksAuditEvent is the audit event of an update of the deployment default/web, as kube-apiserver
+// logs it
+func aksAuditEvent(auditID string, second int) string {
+	return fmt.Sprintf(`{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":%q,"stage":"ResponseComplete","requestURI":"/apis/apps/v1/namespaces/default/deployments/web","verb":"update","user":{"username":"admin"},"objectRef":{"resource":"deployments","namespace":"default","name":"web","apiGroup":"apps","apiVersion":"v1"},"requestReceivedTimestamp":"2025-09-15T16:00:%02dZ","stageTimestamp":"2025-09-15T16:00:%02dZ"}`, auditID, second, second)
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// aksRecord is a record that a diagnostic setting archives to a storage account, one per line of a
+// PT1H.json blob
+func aksRecord(category, log string) string {
+	return fmt.Sprintf(`{"time":"2025-09-15T16:00:00.1234567Z","resourceId":"/SUBSCRIPTIONS/0000/RESOURCEGROUPS/RG/PROVIDERS/MICROSOFT.CONTAINERSERVICE/MANAGEDCLUSTERS/C","category":%q,"operationName":"Microsoft.ContainerService/managedClusters/diagnosticLogs/Read","properties":{"log":%s,"stream":"stdout","pod":"kube-apiserver-7d9f8c6b5-x2v9k","containerID":"0f1e2d3c"}}`, category, quote(log))
+}
+
+// aksRow is a row of the AzureDiagnostics table, as a Log Analytics query exports it
+func aksRow(category, log string) string {
+	return fmt.Sprintf(`{"TenantId":"1111","TimeGenerated":"2025-09-15T16:00:00.123Z","Category":%q,"ResourceProvider":"MICROSOFT.CONTAINERSERVICE","log_s":%s,"pod_s":"kube-apiserver-7d9f8c6b5-x2v9k","Type":"AzureDiagnostics"}`, category, quote(log))
+}
+
+func TestAKSFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		record  string
+		want    string
+		wantErr bool
+	}{
+		{name: "kube-audit record", record: aksRecord("kube-audit", aksAuditEvent("a", 1)), want: "a"},
+		{name: "kube-audit-admin record", record: aksRecord("kube-audit-admin", aksAuditEvent("b", 2)), want: "b"},
+		{name: "AzureDiagnostics row", record: aksRow("kube-audit", aksAuditEvent("c", 3)), want: "c"},
+		{name: "other category", record: aksRecord("kube-apiserver", "I0915 16:00:00.123456       1 controller.go:615] quota admission added evaluator")},
+		{name: "guard row", record: aksRow("guard", `{"level":"info","msg":"authenticated"}`)},
+		{name: "malformed audit event", record: aksRecord("kube-audit", `{"auditID":"d",`), wantErr: true},
+		{name: "malformed record", record: `{"category":"kube-audit","properties":`, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header, err := aksFormat{}.header([]byte(tc.record))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if tc.want == "" {
+				if header.ObjectRef != nil || header.Items != nil {
+					t.Errorf("got header %+v for a record of another category, want none", header)
+				}
+				return
+			}
+			if header.AuditID != tc.want || header.ObjectRef == nil || header.ObjectRef.Name != "web" {
+				t.Errorf("got header %+v, want the header of event %s", header, tc.want)
+			}
+			e, err := aksFormat{}.event([]byte(tc.record))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e.AuditID != tc.want || e.Verb != "update" || e.ObjectRef.Resource != "deployments" || e.User.Username != "admin" {
+				t.Errorf("got event %+v, want the update of event %s", e, tc.want)
+			}
+		})
+	}
+}
+
+func TestAKSFormatRecords(t *testing.T) {
+	envelope := `{"records":[` + aksRecord("kube-apiserver", "I0915 started") + "," + aksRecord("kube-audit", aksAuditEvent("a", 1)) + `]}`
+	header, err := aksFormat{}.header([]byte(envelope))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(header.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(header.Items))
+	}
+	if header.ObjectRef != nil {
+		t.Errorf("got objectRef %+v for the envelope, want none", header.ObjectRef)
+	}
+}
+
+func TestAKSProviderReadsExports(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// files are written under a storage account's hierarchy, and the provider is given its root
+		files map[string]string
+	}{
+		{
+			name: "PT1H.json",
+			files: map[string]string{"resourceId=/SUBSCRIPTIONS/0000/y=2025/m=09/d=15/h=16/m=00/PT1H.json": strings.Join([]string{
+				aksRecord("kube-apiserver", "I0915 started"),
+				aksRecord("kube-audit", aksAuditEvent("a", 1)),
+				aksRecord("kube-audit-admin", aksAuditEvent("b", 2)),
+				aksRecord("cluster-autoscaler", "I0915 scale up"),
+			}, "\n")},
+		},
+		{
+			name: "event hub records",
+			files: map[string]string{"records.json": `{"records":[` + aksRecord("kube-audit", aksAuditEvent("a", 1)) + "," + aksRecord("guard", "{}") + `]}` + "\n" +
+				`{"records":[` + aksRecord("kube-audit", aksAuditEvent("b", 2)) + `]}`},
+		},
+		{
+			name: "pretty-printed records",
+			files: map[string]string{"records.json": "{\n  \"records\": [\n    " + aksRecord("kube-audit", aksAuditEvent("a", 1)) + ",\n    " +
+				aksRecord("kube-audit", aksAuditEvent("b", 2)) + "\n  ]\n}\n"},
+		},
+		{
+			name:  "Log Analytics export",
+			files: map[string]string{"query.json": "[" + aksRow("kube-audit", aksAuditEvent("a", 1)) + "," + aksRow("kube-apiserver", "I0915 started") + "," + aksRow("kube-audit", aksAuditEvent("b", 2)) + "]"},
+		},
+		{
+			name: "hours in several blobs",
+			files: map[string]string{
+				"resourceId=/SUBSCRIPTIONS/0000/y=2025/m=09/d=15/h=16/m=00/PT1H.json": aksRecord("kube-audit", aksAuditEvent("a", 1)),
+				"resourceId=/SUBSCRIPTIONS/0000/y=2025/m=09/d=15/h=17/m=00/PT1H.json": aksRecord("kube-audit", aksAuditEvent("b", 2)),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			a, err := NewAKS(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for e, err := range a.GetEvents(context.Background(), object.WorkloadParser{Kind: object.ObjectTypeDeployment}, "get", aksStart, aksStart.Add(time.Minute), types.NamespacedName{Namespace: "default", Name: "web"}) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got = append(got, e.AuditID)
+			}
+			if want := []string{"a", "b"}; !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -3,7 +3,12 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -15,12 +20,42 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 )
 
+const (
+	// maxQueryResults is the maximum number of rows that a single Logs Insights query can return
+	maxQueryResults = 10000
+	// maxConcurrentQueries bounds how many Logs Insights queries run at once when a time window is
+	// split. Concurrent queries are limited per account, so this leaves headroom for other users.
+	maxConcurrentQueries = 5
+)
+
+// CloudWatchLogsAPI is the subset of the CloudWatch Logs client that the provider relies on
+type CloudWatchLogsAPI interface {
+	StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error)
+	GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error)
+}
+
+// ErrQueryTimeout is returned when a query doesn't complete within the configured query timeout
+var ErrQueryTimeout = errors.New("query timed out")
+
+// QueryStatusError is returned when a query ends in a status other than Complete
+type QueryStatusError struct {
+	QueryID string
+	Status  cloudwatchlogstypes.QueryStatus
+}
+
+func (e *QueryStatusError) Error() string {
+	return fmt.Sprintf("query %s finished with status %s", e.QueryID, e.Status)
+}
+
 type CloudWatch struct {
-	client       *cloudwatchlogs.Client
+	client       CloudWatchLogsAPI
 	logGroupName string
+	queryTimeout time.Duration
+	pollInterval time.Duration
 }
 
-func NewCloudWatch(logGroupName, region string) (*CloudWatch, error) {
+func NewCloudWatch(logGroupName, region string, queryTimeout time.Duration) (*CloudWatch, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
@@ -28,63 +63,244 @@ func NewCloudWatch(logGroupName, region string) (*CloudWatch, error) {
 	if region != "" {
 		cfg.Region = region
 	}
+	return NewCloudWatchFromClient(cloudwatchlogs.NewFromConfig(cfg), logGroupName, queryTimeout), nil
+}
 
+// NewCloudWatchFromClient creates a CloudWatch provider that queries through the given client.
+// A zero queryTimeout waits on queries for as long as the context allows.
+func NewCloudWatchFromClient(client CloudWatchLogsAPI, logGroupName string, queryTimeout time.Duration) *CloudWatch {
 	return &CloudWatch{
-		client:       cloudwatchlogs.NewFromConfig(cfg),
+		client:       client,
 		logGroupName: logGroupName,
-	}, nil
+		queryTimeout: queryTimeout,
+		pollInterval: 500 * time.Millisecond,
+	}
 }
 
+// Query runs a single Logs Insights query and waits for it to finish. Queries that end in a status
+// other than Complete return a QueryStatusError, and queries that outlive the query timeout are
+// stopped and return ErrQueryTimeout.
 func (c *CloudWatch) Query(ctx context.Context, query string, startTime, endTime time.Time) (cloudwatchlogs.GetQueryResultsOutput, error) {
 	startQuery, err := c.client.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
-		QueryString:         lo.ToPtr(query),
+		QueryString:         lo.ToPtr(fmt.Sprintf("%s| limit %d\n", query, maxQueryResults)),
 		StartTime:           lo.ToPtr(startTime.Unix()),
 		EndTime:             lo.ToPtr(endTime.Unix()),
 		LogGroupIdentifiers: []string{c.logGroupName},
 	})
 	if err != nil {
-		return cloudwatchlogs.GetQueryResultsOutput{}, err
+		return cloudwatchlogs.GetQueryResultsOutput{}, fmt.Errorf("starting query, %w", err)
 	}
-	var result *cloudwatchlogs.GetQueryResultsOutput
-	lo.WaitFor(func(_ int) bool {
-		result, err = c.client.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{
+	queryID := lo.FromPtr(startQuery.QueryId)
+
+	if c.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.queryTimeout, ErrQueryTimeout)
+		defer cancel()
+	}
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		result, err := c.client.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{
 			QueryId: startQuery.QueryId,
 		})
 		if err != nil {
+			if ctx.Err() != nil {
+				return cloudwatchlogs.GetQueryResultsOutput{}, c.stopQuery(ctx, queryID)
+			}
+			return cloudwatchlogs.GetQueryResultsOutput{}, fmt.Errorf("getting results for query %s, %w", queryID, err)
+		}
+		switch result.Status {
+		case cloudwatchlogstypes.QueryStatusComplete:
+			return *result, nil
+		case cloudwatchlogstypes.QueryStatusFailed, cloudwatchlogstypes.QueryStatusCancelled,
+			cloudwatchlogstypes.QueryStatusTimeout, cloudwatchlogstypes.QueryStatusUnknown:
+			return cloudwatchlogs.GetQueryResultsOutput{}, &QueryStatusError{QueryID: queryID, Status: result.Status}
+		}
+		select {
+		case <-ctx.Done():
+			return cloudwatchlogs.GetQueryResultsOutput{}, c.stopQuery(ctx, queryID)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close is a no-op, as CloudWatch Logs queries don't hold on to anything between them
+func (c *CloudWatch) Close() error {
+	return nil
+}
+
+// stopQuery stops a query that's no longer being waited on, so that it doesn't keep counting against
+// the account's concurrent query limit, and returns the reason that the query was abandoned
+func (c *CloudWatch) stopQuery(ctx context.Context, queryID string) error {
+	// The query's context is already done, so stopping it needs a fresh one
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := c.client.StopQuery(stopCtx, &cloudwatchlogs.StopQueryInput{QueryId: lo.ToPtr(queryID)}); err != nil {
+		return fmt.Errorf("query %s, %w (stopping query, %w)", queryID, context.Cause(ctx), err)
+	}
+	return fmt.Errorf("query %s, %w", queryID, context.Cause(ctx))
+}
+
+// QueryAll runs the query over [startTime, endTime] and streams back every matching row. Logs Insights
+// caps the rows returned by a single query, so whenever a query hits the cap its window is bisected
+// and both halves are queried again, recursively. Sub-queries run concurrently, bounded by
+// maxConcurrentQueries, and the rows of each window are held on to until every earlier window was
+// streamed, so rows come back in the order of their windows. Rows from overlapping windows may be
+// returned more than once.
+func (c *CloudWatch) QueryAll(ctx context.Context, query string, startTime, endTime time.Time) iter.Seq2[[]cloudwatchlogstypes.ResultField, error] {
+	return func(yield func([]cloudwatchlogstypes.ResultField, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		sem := make(chan struct{}, maxConcurrentQueries)
+		var run func(w *queryWindow, start, end time.Time)
+		run = func(w *queryWindow, start, end time.Time) {
+			defer close(w.done)
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				w.err = context.Cause(ctx)
+				return
+			}
+			res, err := c.Query(ctx, query, start, end)
+			<-sem
+			if err == nil && truncated(res) {
+				// Queries have second granularity, so windows can't be split any further than that
+				if end.Sub(start) > time.Second {
+					mid := start.Add(end.Sub(start) / 2)
+					w.halves = []*queryWindow{newQueryWindow(), newQueryWindow()}
+					go run(w.halves[0], start, mid)
+					go run(w.halves[1], mid, end)
+					return
+				}
+				fmt.Fprintf(os.Stderr, "Warning: more than %d events between %s and %s, results are truncated\n",
+					maxQueryResults, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+			}
+			w.rows, w.err = res.Results, err
+		}
+		root := newQueryWindow()
+		go run(root, startTime, endTime)
+
+		// Windows are streamed depth first, so the earlier half of a window is streamed before the later one
+		var stream func(w *queryWindow) bool
+		stream = func(w *queryWindow) bool {
+			<-w.done
+			if w.err != nil {
+				yield(nil, w.err)
+				return false
+			}
+			for _, half := range w.halves {
+				if !stream(half) {
+					return false
+				}
+			}
+			for _, row := range w.rows {
+				if !yield(row, nil) {
+					return false
+				}
+			}
+			// The rows were streamed, so they don't need to be held on to until the rest of the windows are
+			w.rows = nil
 			return true
 		}
-		return result.Status == cloudwatchlogstypes.QueryStatusComplete
-	}, time.Minute*5, 500*time.Millisecond)
-	if err != nil {
-		return cloudwatchlogs.GetQueryResultsOutput{}, err
+		stream(root)
 	}
-	return *result, nil
-}
-
-func (c *CloudWatch) GetEvents(ctx context.Context, parser object.ObjectParser, cmdType string, startTime, endTime time.Time, nn types.NamespacedName) ([]auditmodel.Event, error) {
-	var query string
-	switch cmdType {
-	case "get":
-		query = parser.GetQuery(nn)
-	case "describe":
-		query = parser.DescribeQuery(nn)
-	default:
-		panic(fmt.Sprintf("invalid command type: %s", cmdType))
+}
+
+// queryWindow is the result of querying a window of QueryAll, which is either its rows or the two
+// halves that it was split into. done is closed once the window's query finished.
+type queryWindow struct {
+	done   chan struct{}
+	rows   [][]cloudwatchlogstypes.ResultField
+	halves []*queryWindow
+	err    error
+}
+
+func newQueryWindow() *queryWindow {
+	return &queryWindow{done: make(chan struct{})}
+}
+
+// truncated reports whether a query matched more rows than it was able to return
+func truncated(res cloudwatchlogs.GetQueryResultsOutput) bool {
+	if res.Statistics != nil && res.Statistics.RecordsMatched > float64(len(res.Results)) {
+		return true
 	}
+	return len(res.Results) >= maxQueryResults
+}
 
-	res, err := c.Query(ctx, query, startTime, endTime)
+func (c *CloudWatch) GetEvents(ctx context.Context, parser object.ObjectParser, cmdType string, startTime, endTime time.Time, nn types.NamespacedName) iter.Seq2[auditmodel.Event, error] {
+	q, _, err := queryFor(parser, cmdType, nn)
 	if err != nil {
-		return nil, err
+		return failedEvents(err)
 	}
-	var auditEvents []auditmodel.Event
-	for _, r := range res.Results {
-		for _, field := range r {
-			if *field.Field == "@message" {
-				var auditEvent auditmodel.Event
-				lo.Must0(json.Unmarshal([]byte(*field.Value), &auditEvent))
-				auditEvents = append(auditEvents, auditEvent)
+	query := insightsQuery(q)
+	return uniqueEvents(func(yield func(auditmodel.Event, error) bool) {
+		for row, err := range c.QueryAll(ctx, query, startTime, endTime) {
+			if err != nil {
+				yield(auditmodel.Event{}, err)
+				return
+			}
+			for _, field := range row {
+				if *field.Field == "@message" {
+					var auditEvent auditmodel.Event
+					if err := json.Unmarshal([]byte(*field.Value), &auditEvent); err != nil {
+						if !yield(auditmodel.Event{}, &object.ParseError{AuditID: auditEvent.AuditID, Err: err}) {
+							return
+						}
+						continue
+					}
+					if !yield(auditEvent, nil) {
+						return
+					}
+				}
 			}
 		}
+	})
+}
+
+// insightsQuery renders a query in the Logs Insights query language. EKS writes the audit log to the
+// kube-apiserver-audit log streams of the cluster's log group.
+func insightsQuery(q object.Query) string {
+	// Alternatives that skip Contains leave it to the others to check
+	skipContains := lo.SomeBy(q.URIs, func(m object.URIMatch) bool { return m.SkipContains })
+	b := &strings.Builder{}
+	b.WriteString("\nfields @timestamp, @message\n| filter @logStream like \"apiserver\"\n")
+	if len(q.Verbs) > 0 {
+		fmt.Fprintf(b, "| filter %s\n", insightsVerbs(q.Verbs))
+	}
+	if len(q.URIs) > 0 {
+		clauses := lo.Map(q.URIs, func(m object.URIMatch, _ int) string {
+			var conditions []string
+			for _, c := range m.Contains {
+				conditions = append(conditions, fmt.Sprintf("requestURI like %q", c))
+			}
+			for _, e := range m.Excludes {
+				conditions = append(conditions, fmt.Sprintf("requestURI not like %q", e))
+			}
+			if len(m.Verbs) > 0 {
+				conditions = append(conditions, insightsVerbs(m.Verbs))
+			}
+			if skipContains && !m.SkipContains && q.Contains != "" {
+				conditions = append(conditions, fmt.Sprintf("@message like %q", q.Contains))
+			}
+			return "(" + strings.Join(conditions, " and ") + ")"
+		})
+		fmt.Fprintf(b, "| filter %s\n", strings.Join(clauses, " or "))
+	}
+	for _, c := range q.URIContains {
+		fmt.Fprintf(b, "| filter requestURI like %q\n", c)
+	}
+	if q.Contains != "" && !skipContains {
+		fmt.Fprintf(b, "| filter @message like %q\n", q.Contains)
+	}
+	// Events are streamed oldest first, and Logs Insights returns the newest first unless told otherwise
+	b.WriteString("| sort @timestamp asc\n")
+	return b.String()
+}
+
+func insightsVerbs(verbs []string) string {
+	if len(verbs) == 1 {
+		return fmt.Sprintf("verb = %q", verbs[0])
 	}
-	return auditEvents, nil
+	return fmt.Sprintf("verb in [%s]", strings.Join(lo.Map(verbs, func(v string, _ int) string { return strconv.Quote(v) }), ", "))
 }
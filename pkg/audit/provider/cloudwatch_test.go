@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cloudwatchlogstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeCloudWatchLogs is a CloudWatchLogsAPI whose queries are answered by results, given the
+// window of the query
+type fakeCloudWatchLogs struct {
+	startErr error
+	results  func(startTime, endTime time.Time) (*cloudwatchlogs.GetQueryResultsOutput, error)
+
+	mu      sync.Mutex
+	queries map[string][2]time.Time
+	stopped []string
+}
+
+func (f *fakeCloudWatchLogs) StartQuery(_ context.Context, params *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+	if f.startErr != nil {
+		return nil, f.startErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.queries == nil {
+		f.queries = map[string][2]time.Time{}
+	}
+	id := fmt.Sprintf("query-%d", len(f.queries)+1)
+	f.queries[id] = [2]time.Time{time.Unix(*params.StartTime, 0), time.Unix(*params.EndTime, 0)}
+	return &cloudwatchlogs.StartQueryOutput{QueryId: lo.ToPtr(id)}, nil
+}
+
+func (f *fakeCloudWatchLogs) GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	window := f.queries[*params.QueryId]
+	f.mu.Unlock()
+	return f.results(window[0], window[1])
+}
+
+func (f *fakeCloudWatchLogs) StopQuery(_ context.Context, params *cloudwatchlogs.StopQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = append(f.stopped, *params.QueryId)
+	return &cloudwatchlogs.StopQueryOutput{Success: true}, nil
+}
+
+func withStatus(status cloudwatchlogstypes.QueryStatus) func(time.Time, time.Time) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	return func(time.Time, time.Time) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+		return &cloudwatchlogs.GetQueryResultsOutput{Status: status}, nil
+	}
+}
+
+func newTestCloudWatch(client CloudWatchLogsAPI, queryTimeout time.Duration) *CloudWatch {
+	c := NewCloudWatchFromClient(client, "/aws/eks/test/cluster", queryTimeout)
+	c.pollInterval = time.Millisecond
+	return c
+}
+
+var (
+	cwStart = time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+	cwEnd   = cwStart.Add(time.Hour)
+)
+
+func TestCloudWatchQueryStatus(t *testing.T) {
+	for _, status := range []cloudwatchlogstypes.QueryStatus{
+		cloudwatchlogstypes.QueryStatusFailed,
+		cloudwatchlogstypes.QueryStatusCancelled,
+		cloudwatchlogstypes.QueryStatusTimeout,
+		cloudwatchlogstypes.QueryStatusUnknown,
+	} {
+		t.Run(string(status), func(t *testing.T) {
+			c := newTestCloudWatch(&fakeCloudWatchLogs{results: withStatus(status)}, 0)
+			_, err := c.Query(context.Background(), "fields @message", cwStart, cwEnd)
+			var statusErr *QueryStatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("expected a QueryStatusError, got %v", err)
+			}
+			if statusErr.Status != status || statusErr.QueryID != "query-1" {
+				t.Errorf("got status %s for %s, want %s for query-1", statusErr.Status, statusErr.QueryID, status)
+			}
+		})
+	}
+}
+
+func TestCloudWatchQueryTimeout(t *testing.T) {
+	fake := &fakeCloudWatchLogs{results: withStatus(cloudwatchlogstypes.QueryStatusRunning)}
+	c := newTestCloudWatch(fake, 20*time.Millisecond)
+	_, err := c.Query(context.Background(), "fields @message", cwStart, cwEnd)
+	if !errors.Is(err, ErrQueryTimeout) {
+		t.Fatalf("expected ErrQueryTimeout, got %v", err)
+	}
+	if len(fake.stopped) != 1 || fake.stopped[0] != "query-1" {
+		t.Errorf("expected query-1 to be stopped, stopped %v", fake.stopped)
+	}
+}
+
+func TestCloudWatchQueryCancelled(t *testing.T) {
+	fake := &fakeCloudWatchLogs{results: withStatus(cloudwatchlogstypes.QueryStatusRunning)}
+	c := newTestCloudWatch(fake, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.Query(ctx, "fields @message", cwStart, cwEnd)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+	if len(fake.stopped) != 1 {
+		t.Errorf("expected the query to be stopped, stopped %v", fake.stopped)
+	}
+}
+
+func TestCloudWatchQueryErrors(t *testing.T) {
+	errAPI := errors.New("AccessDeniedException")
+	for _, tc := range []struct {
+		name string
+		fake *fakeCloudWatchLogs
+	}{
+		{
+			name: "StartQuery",
+			fake: &fakeCloudWatchLogs{startErr: errAPI},
+		},
+		{
+			name: "GetQueryResults",
+			fake: &fakeCloudWatchLogs{results: func(time.Time, time.Time) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+				return nil, errAPI
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCloudWatch(tc.fake, time.Minute)
+			if _, err := c.Query(context.Background(), "fields @message", cwStart, cwEnd); !errors.Is(err, errAPI) {
+				t.Errorf("Query returned %v, want %v", err, errAPI)
+			}
+			var events int
+			for _, err := range c.GetEvents(context.Background(), object.PodParser{}, "get", cwStart, cwEnd, types.NamespacedName{Namespace: "default", Name: "web"}) {
+				if err == nil {
+					events++
+					continue
+				}
+				if !errors.Is(err, errAPI) {
+					t.Errorf("GetEvents returned %v, want %v", err, errAPI)
+				}
+			}
+			if events != 0 {
+				t.Errorf("GetEvents returned %d events before the error, want 0", events)
+			}
+		})
+	}
+}
+
+func TestCloudWatchBisectsTruncatedQueries(t *testing.T) {
+	// 25 events a second over 20 minutes is 30000 events, which takes several levels of bisection
+	const perSecond = 25
+	window := 20 * time.Minute
+	fake := &fakeCloudWatchLogs{results: func(startTime, endTime time.Time) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+		res := &cloudwatchlogs.GetQueryResultsOutput{Status: cloudwatchlogstypes.QueryStatusComplete, Statistics: &cloudwatchlogstypes.QueryStatistics{}}
+		// Like Logs Insights, both ends of the window are inclusive
+		for ts := lo.Latest(startTime, cwStart); !ts.After(endTime) && ts.Before(cwStart.Add(window)); ts = ts.Add(time.Second) {
+			for i := range perSecond {
+				res.Statistics.RecordsMatched++
+				if len(res.Results) == maxQueryResults {
+					continue
+				}
+				message := fmt.Sprintf(`{"auditID":"%d-%d","stage":"ResponseComplete","verb":"update","requestReceivedTimestamp":%q}`, ts.Unix(), i, ts.Format(time.RFC3339))
+				res.Results = append(res.Results, []cloudwatchlogstypes.ResultField{
+					{Field: lo.ToPtr("@timestamp"), Value: lo.ToPtr(ts.Format(time.RFC3339))},
+					{Field: lo.ToPtr("@message"), Value: lo.ToPtr(message)},
+				})
+			}
+		}
+		return res, nil
+	}}
+	c := newTestCloudWatch(fake, time.Minute)
+
+	auditIDs := map[string]int{}
+	for e, err := range c.GetEvents(context.Background(), object.PodParser{}, "get", cwStart, cwStart.Add(window), types.NamespacedName{Namespace: "default", Name: "web"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		auditIDs[e.AuditID]++
+	}
+	if want := perSecond * int(window/time.Second); len(auditIDs) != want {
+		t.Errorf("got %d distinct events, want %d", len(auditIDs), want)
+	}
+	for id, n := range auditIDs {
+		if n > 1 {
+			t.Errorf("event %s was returned %d times", id, n)
+		}
+	}
+	if len(fake.queries) < 4 {
+		t.Errorf("expected the window to be bisected, ran %d queries", len(fake.queries))
+	}
+}
+
+func TestCloudWatchStreamsWindowsInOrder(t *testing.T) {
+	mid := cwStart.Add(cwEnd.Sub(cwStart) / 2)
+	rows := func(timestamps ...time.Time) *cloudwatchlogs.GetQueryResultsOutput {
+		res := &cloudwatchlogs.GetQueryResultsOutput{Status: cloudwatchlogstypes.QueryStatusComplete, Statistics: &cloudwatchlogstypes.QueryStatistics{}}
+		for _, ts := range timestamps {
+			res.Statistics.RecordsMatched++
+			res.Results = append(res.Results, []cloudwatchlogstypes.ResultField{{Field: lo.ToPtr("@timestamp"), Value: lo.ToPtr(ts.Format(time.RFC3339))}})
+		}
+		return res
+	}
+	// The later half of the window finishes first, and the earlier half keeps running until it did
+	laterDone := make(chan struct{})
+	var once sync.Once
+	fake := &fakeCloudWatchLogs{results: func(startTime, endTime time.Time) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+		switch {
+		case startTime.Equal(cwStart) && endTime.Equal(cwEnd):
+			res := rows(cwStart)
+			res.Statistics.RecordsMatched = maxQueryResults + 1
+			return res, nil
+		case startTime.Equal(mid):
+			once.Do(func() { close(laterDone) })
+			return rows(mid.Add(time.Minute), mid.Add(2*time.Minute)), nil
+		}
+		select {
+		case <-laterDone:
+			return rows(cwStart.Add(time.Minute), cwStart.Add(2*time.Minute)), nil
+		default:
+			return &cloudwatchlogs.GetQueryResultsOutput{Status: cloudwatchlogstypes.QueryStatusRunning}, nil
+		}
+	}}
+	c := newTestCloudWatch(fake, time.Minute)
+
+	var got []string
+	for row, err := range c.QueryAll(context.Background(), "fields @timestamp", cwStart, cwEnd) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, *row[0].Value)
+	}
+	want := lo.Map([]time.Time{cwStart.Add(time.Minute), cwStart.Add(2 * time.Minute), mid.Add(time.Minute), mid.Add(2 * time.Minute)}, func(ts time.Time, _ int) string {
+		return ts.Format(time.RFC3339)
+	})
+	if !slices.Equal(got, want) {
+		t.Errorf("got rows at %v, want %v", got, want)
+	}
+}
+
+func TestInsightsQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		query object.Query
+		want  string
+	}{
+		{
+			name:  "named object",
+			query: object.Query{Verbs: []string{"create", "delete"}, URIContains: []string{"/namespaces/default/"}, Contains: "web"},
+			want: `| filter verb in ["create", "delete"]
+| filter requestURI like "/namespaces/default/"
+| filter @message like "web"
+`,
+		},
+		{
+			name: "alternative that skips the name",
+			query: object.Query{Verbs: []string{"update"}, URIs: []object.URIMatch{
+				{Contains: []string{"nodes"}, Excludes: []string{"csi"}},
+				{Contains: []string{"pods"}, Verbs: []string{"delete"}, SkipContains: true},
+			}, Contains: "node-a"},
+			want: `| filter verb = "update"
+| filter (requestURI like "nodes" and requestURI not like "csi" and @message like "node-a") or (requestURI like "pods" and verb = "delete")
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := "\nfields @timestamp, @message\n| filter @logStream like \"apiserver\"\n" + tc.want + "| sort @timestamp asc\n"
+			if got := insightsQuery(tc.query); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"unicode"
+)
+
+// rawEvent is an audit event that hasn't been decoded yet, along with where it is in its log
+type rawEvent struct {
+	data     []byte
+	position string
+}
+
+// rawEvents streams the values of an audit log, detecting whether it's written as JSON lines, as a
+// JSON array, or as pretty-printed JSON objects that span several lines. Values are usually audit
+// events, but may also be EventLists, which are unwrapped into their events when they span several
+// lines and are left to the caller otherwise. The same goes for Azure Monitor's records.
+//
+// Lines of JSON lines aren't validated, so that a bad line only affects itself. In the other shapes
+// a syntax error leaves the decoder unable to find the next value, so it ends the sequence.
+func rawEvents(r io.Reader) iter.Seq2[rawEvent, error] {
+	return func(yield func(rawEvent, error) bool) {
+		br := bufio.NewReaderSize(r, 1<<20)
+		first, err := firstByte(br)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			yield(rawEvent{}, err)
+			return
+		}
+		switch first {
+		case '[':
+			decodeArray(json.NewDecoder(br), yield)
+		case '{':
+			// Every JSON line is a complete object, while the first line of a pretty-printed object
+			// is just its opening brace
+			line, err := br.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(rawEvent{}, err)
+				return
+			}
+			rest := io.MultiReader(bytes.NewReader(line), br)
+			if json.Valid(line) {
+				readLines(bufio.NewReaderSize(rest, 1<<20), yield)
+			} else {
+				decodeObjects(json.NewDecoder(rest), yield)
+			}
+		default:
+			readLines(br, yield)
+		}
+	}
+}
+
+// firstByte returns the first byte of r that isn't whitespace, without consuming it
+func firstByte(r *bufio.Reader) (byte, error) {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[n-1])) {
+			return b[n-1], nil
+		}
+	}
+}
+
+// readLines yields every line that isn't blank. Lines aren't limited in length, so events with large
+// request and response objects are read whole.
+func readLines(r *bufio.Reader, yield func(rawEvent, error) bool) {
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if !yield(rawEvent{data: line, position: fmt.Sprintf("line %d", n)}, nil) {
+				return
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			yield(rawEvent{}, err)
+			return
+		}
+	}
+}
+
+// decodeArray yields the elements of a JSON array
+func decodeArray(dec *json.Decoder, yield func(rawEvent, error) bool) {
+	if _, err := dec.Token(); err != nil {
+		yield(rawEvent{}, fmt.Errorf("decoding JSON array, %w", err))
+		return
+	}
+	for i := 1; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			yield(rawEvent{}, fmt.Errorf("decoding element %d of JSON array, %w", i, err))
+			return
+		}
+		if !yield(rawEvent{data: raw, position: fmt.Sprintf("element %d", i)}, nil) {
+			return
+		}
+	}
+}
+
+// decodeObjects yields a stream of JSON objects. Objects are walked one field at a time, so that
+// the items of a list are yielded as they're decoded rather than once the whole list is.
+func decodeObjects(dec *json.Decoder, yield func(rawEvent, error) bool) {
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			yield(rawEvent{}, fmt.Errorf("decoding JSON object at offset %d, %w", offset, err))
+			return
+		}
+		if tok != json.Delim('{') {
+			yield(rawEvent{}, fmt.Errorf("expected a JSON object at offset %d, found %v", offset, tok))
+			return
+		}
+		fields := map[string]json.RawMessage{}
+		isList := false
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				yield(rawEvent{}, fmt.Errorf("decoding JSON object at offset %d, %w", offset, err))
+				return
+			}
+			// Audit events don't have items or records, so an object with them is a list of events, like
+			// an EventList or the records that Azure Monitor exports
+			if key == "items" || key == "records" {
+				isList = true
+				if !decodeItems(dec, offset, yield) {
+					return
+				}
+				continue
+			}
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				yield(rawEvent{}, fmt.Errorf("decoding JSON object at offset %d, %w", offset, err))
+				return
+			}
+			fields[key.(string)] = raw
+		}
+		if _, err := dec.Token(); err != nil {
+			yield(rawEvent{}, fmt.Errorf("decoding JSON object at offset %d, %w", offset, err))
+			return
+		}
+		if isList {
+			continue
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			yield(rawEvent{}, fmt.Errorf("encoding JSON object at offset %d, %w", offset, err))
+			return
+		}
+		if !yield(rawEvent{data: data, position: fmt.Sprintf("offset %d", offset)}, nil) {
+			return
+		}
+	}
+}
+
+// decodeItems yields the items of the list at offset, returning whether the sequence should go on
+func decodeItems(dec *json.Decoder, offset int64, yield func(rawEvent, error) bool) bool {
+	tok, err := dec.Token()
+	if err != nil {
+		yield(rawEvent{}, fmt.Errorf("decoding items of list at offset %d, %w", offset, err))
+		return false
+	}
+	if tok == nil {
+		return true
+	}
+	if tok != json.Delim('[') {
+		yield(rawEvent{}, fmt.Errorf("expected the items of list at offset %d to be an array, found %v", offset, tok))
+		return false
+	}
+	for i := 1; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			yield(rawEvent{}, fmt.Errorf("decoding item %d of list at offset %d, %w", i, offset, err))
+			return false
+		}
+		if !yield(rawEvent{data: raw, position: fmt.Sprintf("item %d of list at offset %d", i, offset)}, nil) {
+			return false
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		yield(rawEvent{}, fmt.Errorf("decoding items of list at offset %d, %w", offset, err))
+		return false
+	}
+	return true
+}
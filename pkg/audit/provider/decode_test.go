@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// decodeEvent is a pretty-printed audit event that spans several lines
+const decodeEvent = `{
+  "kind": "Event",
+  "auditID": "%s",
+  "verb": "get"
+}`
+
+func TestRawEvents(t *testing.T) {
+	oversized := fmt.Sprintf(`{"auditID":"big","requestObject":{"data":%q}}`, strings.Repeat("x", 3<<20))
+	for _, tc := range []struct {
+		name    string
+		log     string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "JSON lines",
+			log:  "{\"auditID\":\"a\"}\n\n  \n{\"auditID\":\"b\"}\n{\"auditID\":\"c\"}",
+			want: []string{"line 1 a", "line 4 b", "line 5 c"},
+		},
+		{
+			name: "bad JSON line",
+			log:  "{\"auditID\":\"a\"}\n{\"auditID\":\n{\"auditID\":\"c\"}\n",
+			want: []string{"line 1 a", "line 2 ", "line 3 c"},
+		},
+		{
+			name: "EventList on one line",
+			log:  `{"kind":"EventList","items":[{"auditID":"a"},{"auditID":"b"}]}` + "\n" + `{"kind":"EventList","items":[{"auditID":"c"}]}`,
+			want: []string{"line 1 EventList", "line 2 EventList"},
+		},
+		{
+			name: "pretty-printed EventList",
+			log:  "{\n  \"kind\": \"EventList\",\n  \"apiVersion\": \"audit.k8s.io/v1\",\n  \"items\": [\n" + fmt.Sprintf(decodeEvent, "a") + ",\n" + fmt.Sprintf(decodeEvent, "b") + "\n  ],\n  \"metadata\": {}\n}\n",
+			want: []string{"item 1 of list at offset 0 a", "item 2 of list at offset 0 b"},
+		},
+		{
+			name: "EventList without items",
+			log:  "{\n  \"kind\": \"EventList\",\n  \"items\": null\n}\n" + fmt.Sprintf(decodeEvent, "a"),
+			want: []string{"offset 42 a"},
+		},
+		{
+			name: "records",
+			log:  "{\n  \"records\": [\n    {\"category\": \"kube-audit\", \"properties\": {\"log\": \"{}\"}, \"auditID\": \"a\"}\n  ]\n}\n",
+			want: []string{"item 1 of list at offset 0 a"},
+		},
+		{
+			name: "JSON array",
+			log:  "\n  [" + fmt.Sprintf(decodeEvent, "a") + ",\n" + `{"auditID":"b"}` + "]\n",
+			want: []string{"element 1 a", "element 2 b"},
+		},
+		{
+			name: "empty JSON array",
+			log:  "[]",
+		},
+		{
+			name:    "truncated JSON array",
+			log:     `[{"auditID":"a"},{"auditID":`,
+			want:    []string{"element 1 a"},
+			wantErr: "decoding element 2 of JSON array",
+		},
+		{
+			name: "pretty-printed objects",
+			log:  fmt.Sprintf(decodeEvent, "a") + "\n" + fmt.Sprintf(decodeEvent, "b") + "\n",
+			want: []string{"offset 0 a", "offset 56 b"},
+		},
+		{
+			name:    "truncated pretty-printed objects",
+			log:     fmt.Sprintf(decodeEvent, "a") + "\n{\n  \"auditID\": \"b\",\n",
+			want:    []string{"offset 0 a"},
+			wantErr: "decoding JSON object at offset 56",
+		},
+		{
+			name:    "pretty-printed value that isn't an object",
+			log:     fmt.Sprintf(decodeEvent, "a") + "\n\"b\"\n",
+			want:    []string{"offset 0 a"},
+			wantErr: "expected a JSON object at offset 56",
+		},
+		{
+			name: "oversized line",
+			log:  `{"auditID":"a"}` + "\n" + oversized + "\n" + `{"auditID":"c"}` + "\n",
+			want: []string{"line 1 a", "line 2 big", "line 3 c"},
+		},
+		{
+			name: "oversized first line",
+			log:  oversized,
+			want: []string{"line 1 big"},
+		},
+		{
+			name: "empty",
+			log:  " \n\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			var errs []string
+			for raw, err := range rawEvents(strings.NewReader(tc.log)) {
+				if err != nil {
+					errs = append(errs, err.Error())
+					continue
+				}
+				var fields struct {
+					AuditID string `json:"auditID"`
+					Kind    string `json:"kind"`
+				}
+				_ = json.Unmarshal(raw.data, &fields)
+				got = append(got, raw.position+" "+lo.CoalesceOrEmpty(fields.AuditID, fields.Kind))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			switch {
+			case tc.wantErr == "" && len(errs) > 0:
+				t.Errorf("unexpected errors %q", errs)
+			case tc.wantErr != "" && (len(errs) != 1 || !strings.Contains(errs[0], tc.wantErr)):
+				t.Errorf("got errors %q, want one containing %q", errs, tc.wantErr)
+			}
+		})
+	}
+}
+
+// decodePodEvent is an audit event for the pod default/web, logged at the given second
+func decodePodEvent(auditID string, second int) string {
+	return fmt.Sprintf(`{"auditID":%q,"stage":"ResponseComplete","verb":"update","objectRef":{"resource":"pods","namespace":"default","name":"web"},"requestReceivedTimestamp":"2025-09-15T16:00:%02dZ"}`, auditID, second)
+}
+
+func TestFileSkipsEventsThatCantBeParsed(t *testing.T) {
+	path := test.WriteFile(t, "audit.log",
+		decodePodEvent("a", 1),
+		// Neither the header nor the event of a truncated line can be decoded
+		`{"auditID":"truncated","objectRef":{"resource":"pods","namespace":"default","name":"web"`,
+		// The header of an EventList is decoded, and each of its items is processed on its own
+		`{"kind":"EventList","items":[`+decodePodEvent("b", 2)+`,{"auditID":"bad-user","user":"web","objectRef":{"resource":"pods","namespace":"default","name":"web"},"requestReceivedTimestamp":"2025-09-15T16:00:03Z"}]}`,
+		// Events of other objects aren't parsed at all
+		`{"auditID":"other","objectRef":{"resource":"pods","namespace":"default","name":"db"`,
+		decodePodEvent("c", 4),
+	)
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+	nn := types.NamespacedName{Namespace: "default", Name: "web"}
+	warnings := &bytes.Buffer{}
+	var got []string
+	for e, err := range object.SkipParseErrors(f.GetEvents(context.Background(), object.PodParser{}, "get", start, start.Add(time.Minute), nn), warnings) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, e.AuditID)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	for _, want := range []string{
+		path + " line 2",
+		"parsing event bad-user",
+		"skipped 2 event(s)",
+	} {
+		if !strings.Contains(warnings.String(), want) {
+			t.Errorf("warnings don't contain %q:\n%s", want, warnings)
+		}
+	}
+	if strings.Contains(warnings.String(), "db") {
+		t.Errorf("warned about an event of another object:\n%s", warnings)
+	}
+}
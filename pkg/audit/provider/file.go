@@ -2,43 +2,334 @@ package provider
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
 	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/klauspost/compress/zstd"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// Stdin is the path that reads the audit log from stdin
+const Stdin = "-"
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// File reads audit events from local audit log files. Files that are compressed with gzip or zstd,
+// like the ones kube-apiserver rotates its audit log into, are decompressed as they're read.
+// Each file may hold JSON lines, EventLists, a JSON array or pretty-printed events, see rawEvents.
 type File struct {
-	logPath string
+	logPaths []string
+	format   entryFormat
+	// stdin is the audit log that was piped in, spooled to an unlinked temporary file so that it can
+	// be read again for every query
+	stdin *os.File
+}
+
+// NewFile returns a provider for the audit logs at logPaths. Each path can be a file, a directory
+// whose files are all read, a glob, or - for stdin.
+func NewFile(logPaths ...string) (*File, error) {
+	return newFile(kubernetesFormat{}, logPaths...)
+}
+
+func newFile(format entryFormat, logPaths ...string) (*File, error) {
+	f := &File{format: format}
+	for _, logPath := range logPaths {
+		expanded, err := expandPath(logPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range expanded {
+			if !slices.Contains(f.logPaths, p) {
+				f.logPaths = append(f.logPaths, p)
+			}
+		}
+	}
+	if len(f.logPaths) == 0 {
+		return nil, fmt.Errorf("no audit log files found in %s", strings.Join(logPaths, ", "))
+	}
+	if slices.Contains(f.logPaths, Stdin) {
+		stdin, err := spoolStdin()
+		if err != nil {
+			return nil, fmt.Errorf("reading audit log from stdin, %w", err)
+		}
+		f.stdin = stdin
+	}
+	return f, nil
 }
 
-func NewFile(logPath string) (*File, error) {
-	if _, err := os.Stat(logPath); os.IsNotExist(err) {
+// expandPath returns the files that a path names
+func expandPath(logPath string) ([]string, error) {
+	if logPath == Stdin {
+		return []string{Stdin}, nil
+	}
+	if strings.ContainsAny(logPath, "*?[") {
+		matches, err := filepath.Glob(logPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit log glob %s, %w", logPath, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no audit log files match %s", logPath)
+		}
+		var files []string
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+				files = append(files, m)
+			}
+		}
+		return files, nil
+	}
+	info, err := os.Stat(logPath)
+	if os.IsNotExist(err) {
 		return nil, fmt.Errorf("audit log file does not exist: %s", logPath)
 	}
-	return &File{logPath: logPath}, nil
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log file %s, %w", logPath, err)
+	}
+	if !info.IsDir() {
+		return []string{logPath}, nil
+	}
+	entries, err := os.ReadDir(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log directory %s, %w", logPath, err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			files = append(files, filepath.Join(logPath, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// spoolStdin copies stdin to a temporary file, which is unlinked straight away so that it's removed
+// once it's closed by Close, or by the process exiting
+func spoolStdin() (*os.File, error) {
+	tmp, err := os.CreateTemp("", "kubereplay-stdin-*")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, os.Stdin); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// Close closes the audit log that was spooled from stdin, if any
+func (f *File) Close() error {
+	if f.stdin == nil {
+		return nil
+	}
+	err := f.stdin.Close()
+	f.stdin = nil
+	return err
+}
+
+// open opens an audit log, decompressing it when it starts with the magic number of gzip or zstd
+func (f *File) open(logPath string) (io.Reader, func(), error) {
+	var r io.Reader
+	closeFile := func() {}
+	if logPath == Stdin {
+		r = io.NewSectionReader(f.stdin, 0, math.MaxInt64)
+	} else {
+		file, err := os.Open(logPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		r, closeFile = file, func() { file.Close() }
+	}
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			closeFile()
+			return nil, nil, fmt.Errorf("decompressing gzip audit log %s, %w", logPath, err)
+		}
+		return gz, func() { gz.Close(); closeFile() }, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			closeFile()
+			return nil, nil, fmt.Errorf("decompressing zstd audit log %s, %w", logPath, err)
+		}
+		return zr, func() { zr.Close(); closeFile() }, nil
+	}
+	return br, closeFile, nil
+}
+
+// eventHeader is the subset of an audit event that's needed to decide whether the event is relevant
+// to a query. Decoding into it skips building the request and response objects for events that
+// are going to be dropped anyway.
+type eventHeader struct {
+	AuditID                  string                      `json:"auditID"`
+	ObjectRef                *auditmodel.ObjectReference `json:"objectRef,omitempty"`
+	RequestReceivedTimestamp metav1.Time                 `json:"requestReceivedTimestamp"`
+	// Items are the events of an EventList
+	Items []json.RawMessage `json:"items,omitempty"`
+}
+
+// entryFormat decodes the entries of an audit log into audit events
+type entryFormat interface {
+	// header decodes the parts of an entry that are needed to decide whether it's relevant to a query.
+	// Entries that aren't about a Kubernetes object have no ObjectRef.
+	header(data []byte) (eventHeader, error)
+	event(data []byte) (auditmodel.Event, error)
+}
+
+// kubernetesFormat is the audit.k8s.io/v1 Event that kube-apiserver writes to its audit log
+type kubernetesFormat struct{}
+
+func (kubernetesFormat) header(data []byte) (eventHeader, error) {
+	var header eventHeader
+	err := json.Unmarshal(data, &header)
+	return header, err
+}
+
+func (kubernetesFormat) event(data []byte) (auditmodel.Event, error) {
+	var event auditmodel.Event
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
+// fileEvent is an event read from one of the audit logs, along with the time that it's merged by
+type fileEvent struct {
+	event     auditmodel.Event
+	timestamp time.Time
+	err       error
+}
+
+// GetEvents reads every audit log in parallel and merges their events by the time that their
+// requests were received. The events of each log are kept in the order they were logged in.
+func (f *File) GetEvents(ctx context.Context, parser object.ObjectParser, cmdType string, startTime, endTime time.Time, nn types.NamespacedName) iter.Seq2[auditmodel.Event, error] {
+	query, filter, err := queryFor(parser, cmdType, nn)
+	if err != nil {
+		return failedEvents(err)
+	}
+	substrings := lo.Map(query.Substrings(), func(s string, _ int) []byte { return []byte(s) })
+
+	return func(yield func(auditmodel.Event, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		// Stops the readers when the sequence isn't consumed to the end
+		defer cancel()
+
+		streams := make([]chan fileEvent, len(f.logPaths))
+		for i, logPath := range f.logPaths {
+			streams[i] = make(chan fileEvent, 256)
+			go f.read(ctx, logPath, filter, startTime, endTime, substrings, streams[i])
+		}
+		heads := make([]*fileEvent, len(streams))
+		for i, stream := range streams {
+			if e, ok := <-stream; ok {
+				heads[i] = &e
+			}
+		}
+		for {
+			next := -1
+			for i, head := range heads {
+				if head != nil && (next == -1 || head.timestamp.Before(heads[next].timestamp)) {
+					next = i
+				}
+			}
+			if next == -1 {
+				return
+			}
+			e := *heads[next]
+			heads[next] = nil
+			if n, ok := <-streams[next]; ok {
+				heads[next] = &n
+			}
+			if !yield(e.event, e.err) {
+				return
+			}
+			// Any other error than a ParseError ends the sequence
+			var parseErr *object.ParseError
+			if e.err != nil && !errors.As(e.err, &parseErr) {
+				return
+			}
+		}
+	}
 }
 
-func (f *File) GetEvents(_ context.Context, _ object.ObjectParser, _ string, _, _ time.Time, _ types.NamespacedName) ([]auditmodel.Event, error) {
-	file, err := os.Open(f.logPath)
+// read sends the events of an audit log that pass the filter and are inside the time window, and
+// closes events once the log has been read
+func (f *File) read(ctx context.Context, logPath string, filter object.Filter, startTime, endTime time.Time, substrings [][]byte, events chan<- fileEvent) {
+	defer close(events)
+	send := func(e fileEvent) bool {
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	r, closeLog, err := f.open(logPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open audit log: %w", err)
+		send(fileEvent{err: err})
+		return
 	}
-	defer file.Close()
+	defer closeLog()
 
-	var events []auditmodel.Event
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var auditEvent auditmodel.Event
-		if err := json.Unmarshal(scanner.Bytes(), &auditEvent); err != nil {
-			continue
+	var process func(data []byte, position string) bool
+	process = func(data []byte, position string) bool {
+		// Every event that we care about mentions the object name somewhere, or the verb of the requests
+		// that don't have to, so this drops the vast majority of events without decoding any JSON
+		if len(substrings) > 0 && !lo.SomeBy(substrings, func(s []byte) bool { return bytes.Contains(data, s) }) {
+			return true
+		}
+		header, err := f.format.header(data)
+		if err != nil {
+			return send(fileEvent{err: &object.ParseError{Err: fmt.Errorf("%s %s, %w", logPath, position, err)}})
+		}
+		if header.Items != nil {
+			for i, item := range header.Items {
+				if !process(item, fmt.Sprintf("%s item %d", position, i+1)) {
+					return false
+				}
+			}
+			return true
+		}
+		if header.ObjectRef == nil || !filter(header.ObjectRef) {
+			return true
+		}
+		if header.RequestReceivedTimestamp.Time.Before(startTime) || header.RequestReceivedTimestamp.Time.After(endTime) {
+			return true
+		}
+		e := fileEvent{timestamp: header.RequestReceivedTimestamp.Time}
+		if e.event, err = f.format.event(data); err != nil {
+			e.err = &object.ParseError{AuditID: header.AuditID, Err: err}
+		}
+		return send(e)
+	}
+	for raw, err := range rawEvents(r) {
+		if err != nil {
+			send(fileEvent{err: fmt.Errorf("reading audit log %s: %w", logPath, err)})
+			return
+		}
+		if !process(raw.data, raw.position) {
+			return
 		}
-		events = append(events, auditEvent)
 	}
-	return events, scanner.Err()
 }
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestFileDescribeNodeFindsDeletesWithoutTheNode(t *testing.T) {
+	path := test.WriteFile(t, "audit.log",
+		`{"auditID":"bind","stage":"ResponseComplete","verb":"create","objectRef":{"resource":"pods","namespace":"default","name":"web","subresource":"binding"},"requestReceivedTimestamp":"2025-09-15T16:01:00Z","requestObject":{"kind":"Binding","target":{"kind":"Node","name":"node-a"}}}`,
+		`{"auditID":"update","stage":"ResponseComplete","verb":"update","objectRef":{"resource":"pods","namespace":"default","name":"web"},"requestReceivedTimestamp":"2025-09-15T16:02:00Z"}`,
+		`{"auditID":"delete","stage":"ResponseComplete","verb":"delete","objectRef":{"resource":"pods","namespace":"default","name":"web"},"requestReceivedTimestamp":"2025-09-15T16:05:00Z"}`,
+	)
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+	var got []string
+	for e, err := range f.GetEvents(context.Background(), object.NodeParser{}, "describe", start, start.Add(time.Hour), types.NamespacedName{Name: "node-a"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, e.AuditID)
+	}
+	if want := []string{"bind", "delete"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFileDropsEventsOutsideTheQuery(t *testing.T) {
+	event := func(auditID, namespace, name, timestamp, extra string) string {
+		return fmt.Sprintf(`{"auditID":%q,"stage":"ResponseComplete","verb":"update","objectRef":{"resource":"pods","namespace":%q,"name":%q},"requestReceivedTimestamp":%q%s}`, auditID, namespace, name, timestamp, extra)
+	}
+	// An event whose user can't be decoded is only reported when it's fully decoded
+	const badUser = `,"user":"web"`
+	path := test.WriteFile(t, "audit.log",
+		event("before", "default", "web", "2025-09-15T15:59:59Z", badUser),
+		event("start", "default", "web", "2025-09-15T16:00:00Z", ""),
+		// Both mention web, so they get past the substring check and are dropped by their objectRef
+		event("other-name", "default", "db", "2025-09-15T16:00:10Z", `,"requestObject":{"metadata":{"labels":{"app":"web"}}}`+badUser),
+		event("other-namespace", "web", "web", "2025-09-15T16:00:20Z", badUser),
+		`{"auditID":"no-object","stage":"ResponseComplete","verb":"get","requestURI":"/apis/web","requestReceivedTimestamp":"2025-09-15T16:00:30Z"}`,
+		event("end", "default", "web", "2025-09-15T16:01:00Z", ""),
+		event("after", "default", "web", "2025-09-15T16:01:01Z", badUser),
+	)
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The time window includes both of its ends
+	start := time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+	nn := types.NamespacedName{Namespace: "default", Name: "web"}
+	var got []string
+	for e, err := range f.GetEvents(context.Background(), object.PodParser{}, "get", start, start.Add(time.Minute), nn) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, e.AuditID)
+	}
+	if want := []string{"start", "end"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// podAuditIDs returns the IDs of the events that f gets for the pod default/web
+func podAuditIDs(t *testing.T, f *File) []string {
+	t.Helper()
+	start := time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+	nn := types.NamespacedName{Namespace: "default", Name: "web"}
+	var got []string
+	for e, err := range f.GetEvents(context.Background(), object.PodParser{}, "get", start, start.Add(time.Minute), nn) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, e.AuditID)
+	}
+	return got
+}
+
+// writeCompressed writes lines to dir/name, compressed with gzip or zstd
+func writeCompressed(t *testing.T, dir, name, compression string, lines ...string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch compression {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = zw
+	}
+	if _, err := io.WriteString(w, strings.Join(lines, "\n")+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileDecompresses(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// file is the name of the compressed log, the compression is detected from its content
+		file        string
+		compression string
+	}{
+		{name: "gzip", file: "audit-2025-09-15T16-00-00.000.log.gz", compression: "gzip"},
+		{name: "zstd", file: "audit.log.zst", compression: "zstd"},
+		{name: "gzip without an extension", file: "audit-rotated", compression: "gzip"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeCompressed(t, t.TempDir(), tc.file, tc.compression, decodePodEvent("a", 1), decodePodEvent("b", 2))
+			f, err := NewFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := podAuditIDs(t, f), []string{"a", "b"}; !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileExpandsPaths(t *testing.T) {
+	dir := t.TempDir()
+	// The rotated logs of kube-apiserver, next to the one it's writing to
+	write := func(name string, lines ...string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCompressed(t, dir, "audit-2025-09-15T16-00-02.000.log.gz", "gzip", decodePodEvent("a", 1))
+	write("audit-2025-09-15T16-00-04.000.log", decodePodEvent("b", 3))
+	write("audit.log", decodePodEvent("c", 5))
+	write("policy.yaml", "apiVersion: audit.k8s.io/v1")
+	if err := os.Mkdir(filepath.Join(dir, "audit-archive"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		logPaths []string
+		want     []string
+	}{
+		{name: "glob", logPaths: []string{filepath.Join(dir, "audit-*")}, want: []string{"a", "b"}},
+		// policy.yaml is read as well, and has no events of the pod. Subdirectories are skipped.
+		{name: "directory", logPaths: []string{dir}, want: []string{"a", "b", "c"}},
+		{
+			name:     "overlapping paths are read once",
+			logPaths: []string{filepath.Join(dir, "audit*"), filepath.Join(dir, "audit.log")},
+			want:     []string{"a", "b", "c"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFile(tc.logPaths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := podAuditIDs(t, f); !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if _, err := NewFile(filepath.Join(dir, "*.json")); err == nil || !strings.Contains(err.Error(), "no audit log files match") {
+		t.Errorf("got error %v for a glob without matches", err)
+	}
+}
+
+func TestFileMergesLogsInOrder(t *testing.T) {
+	// The audit logs of two API servers, each in order, that interleave with each other
+	first := test.WriteFile(t, "audit.log", decodePodEvent("a", 1), decodePodEvent("c", 3), decodePodEvent("d", 4))
+	second := test.WriteFile(t, "audit.log", decodePodEvent("b", 2), decodePodEvent("e", 5))
+	f, err := NewFile(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := podAuditIDs(t, f), []string{"a", "b", "c", "d", "e"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFileReadsStdin(t *testing.T) {
+	piped := test.WriteFile(t, "audit.log", decodePodEvent("a", 1), decodePodEvent("b", 2))
+	in, err := os.Open(piped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	stdin := os.Stdin
+	os.Stdin = in
+	f, err := NewFile(Stdin)
+	os.Stdin = stdin
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stdin is spooled, so it can be queried more than once
+	for range 2 {
+		if got, want := podAuditIDs(t, f), []string{"a", "b"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("closing the spooled stdin, %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("closing the provider again, %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GKE reads the Kubernetes audit logs of GKE clusters from Cloud Logging entries that were exported
+// as JSON, like the files that a sink writes to Cloud Storage or the output of
+// "gcloud logging read --format=json". Each entry is mapped onto the audit event that kube-apiserver
+// would have logged, so that the parsers work on GKE clusters unchanged.
+type GKE struct {
+	*File
+}
+
+// NewGKE returns a provider for the exported Cloud Logging entries at logPaths, which can be
+// anything that NewFile takes
+func NewGKE(logPaths ...string) (*GKE, error) {
+	f, err := newFile(gkeFormat{}, logPaths...)
+	if err != nil {
+		return nil, err
+	}
+	return &GKE{File: f}, nil
+}
+
+// logEntry is a Cloud Logging LogEntry with an AuditLog payload
+type logEntry struct {
+	InsertID  string    `json:"insertId"`
+	Timestamp time.Time `json:"timestamp"`
+	Operation struct {
+		ID string `json:"id"`
+	} `json:"operation"`
+	ProtoPayload struct {
+		MethodName         string `json:"methodName"`
+		ResourceName       string `json:"resourceName"`
+		AuthenticationInfo struct {
+			PrincipalEmail string `json:"principalEmail"`
+		} `json:"authenticationInfo"`
+		// Status is a google.rpc.Status, whose code is a gRPC code rather than an HTTP one
+		Status *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+		Request  json.RawMessage `json:"request"`
+		Response json.RawMessage `json:"response"`
+	} `json:"protoPayload"`
+}
+
+// grpcToHTTP maps the gRPC codes that the Kubernetes API's errors are logged with back to the HTTP
+// status codes that they were returned with
+var grpcToHTTP = map[int]int{
+	0:  http.StatusOK,
+	1:  499,
+	3:  http.StatusBadRequest,
+	4:  http.StatusGatewayTimeout,
+	5:  http.StatusNotFound,
+	6:  http.StatusConflict,
+	7:  http.StatusForbidden,
+	8:  http.StatusTooManyRequests,
+	9:  http.StatusBadRequest,
+	10: http.StatusConflict,
+	12: http.StatusNotImplemented,
+	14: http.StatusServiceUnavailable,
+	16: http.StatusUnauthorized,
+}
+
+// gkeFormat maps Cloud Logging entries onto audit events
+type gkeFormat struct{}
+
+func (gkeFormat) header(data []byte) (eventHeader, error) {
+	var entry logEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return eventHeader{}, err
+	}
+	header := eventHeader{
+		AuditID:                  lo.CoalesceOrEmpty(entry.Operation.ID, entry.InsertID),
+		RequestReceivedTimestamp: metav1.NewTime(entry.Timestamp),
+	}
+	// Entries that aren't about a Kubernetes object, like the cluster's own admin activity, are skipped
+	if ref, _, ok := parseResourceName(entry.ProtoPayload.ResourceName); ok {
+		header.ObjectRef = &ref
+	}
+	return header, nil
+}
+
+func (gkeFormat) event(data []byte) (auditmodel.Event, error) {
+	var entry logEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return auditmodel.Event{}, err
+	}
+	payload := entry.ProtoPayload
+	ref, requestURI, ok := parseResourceName(payload.ResourceName)
+	if !ok {
+		return auditmodel.Event{}, fmt.Errorf("unrecognized resource name %q", payload.ResourceName)
+	}
+	event := auditmodel.Event{
+		Kind:       "Event",
+		APIVersion: "audit.k8s.io/v1",
+		Level:      lo.Ternary(isEmptyJSON(payload.Request) && isEmptyJSON(payload.Response), "Metadata", "RequestResponse"),
+		AuditID:    lo.CoalesceOrEmpty(entry.Operation.ID, entry.InsertID),
+		Stage:      "ResponseComplete",
+		RequestURI: requestURI,
+		// Method names end in the verb, like io.k8s.core.v1.pods.binding.create
+		Verb:                     payload.MethodName[strings.LastIndex(payload.MethodName, ".")+1:],
+		User:                     auditmodel.User{Username: payload.AuthenticationInfo.PrincipalEmail},
+		ObjectRef:                &ref,
+		RequestReceivedTimestamp: metav1.NewTime(entry.Timestamp),
+		StageTimestamp:           metav1.NewTime(entry.Timestamp),
+	}
+	if payload.Status != nil {
+		code, ok := grpcToHTTP[payload.Status.Code]
+		event.ResponseStatus = &metav1.Status{Code: int32(lo.Ternary(ok, code, http.StatusInternalServerError)), Message: payload.Status.Message}
+	}
+	var err error
+	if event.RequestObject, err = kubernetesObject(payload.Request, ref); err != nil {
+		return auditmodel.Event{}, fmt.Errorf("decoding request, %w", err)
+	}
+	if event.ResponseObject, err = kubernetesObject(payload.Response, ref); err != nil {
+		return auditmodel.Event{}, fmt.Errorf("decoding response, %w", err)
+	}
+	return event, nil
+}
+
+// apiVersionPattern matches the versions of Kubernetes APIs, like v1 or v2beta1
+var apiVersionPattern = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// parseResourceName parses the resource name of an AuditLog, like
+// core/v1/namespaces/default/pods/my-pod/binding or karpenter.sh/v1/nodeclaims/default-x8zvc, into
+// the object reference and request URI of the request. Names of other services' resources, like
+// projects/p/locations/l/clusters/c for GKE's own admin activity, aren't parsed.
+func parseResourceName(resourceName string) (auditmodel.ObjectReference, string, bool) {
+	parts := strings.Split(resourceName, "/")
+	if len(parts) < 3 || lo.Contains(parts, "") || !apiVersionPattern.MatchString(parts[1]) {
+		return auditmodel.ObjectReference{}, "", false
+	}
+	ref := auditmodel.ObjectReference{APIGroup: lo.Ternary(parts[0] == "core", "", parts[0]), APIVersion: parts[1]}
+	rest := parts[2:]
+	if len(rest) >= 3 && rest[0] == "namespaces" {
+		ref.Namespace, rest = rest[1], rest[2:]
+	}
+	if len(rest) > 3 {
+		return auditmodel.ObjectReference{}, "", false
+	}
+	ref.Resource = rest[0]
+	if len(rest) > 1 {
+		ref.Name = rest[1]
+	}
+	if len(rest) > 2 {
+		ref.Subresource = rest[2]
+	}
+	prefix := lo.Ternary(ref.APIGroup == "", "/api/"+ref.APIVersion, "/apis/"+ref.APIGroup+"/"+ref.APIVersion)
+	return ref, prefix + "/" + strings.Join(parts[2:], "/"), true
+}
+
+// kubernetesObject turns an object of an AuditLog back into the object that Kubernetes logs. Cloud
+// Logging records the object's type in an @type field, like core.k8s.io/v1.Pod, which is dropped
+// for the apiVersion and kind when the object doesn't have them.
+func kubernetesObject(raw json.RawMessage, ref auditmodel.ObjectReference) (json.RawMessage, error) {
+	if isEmptyJSON(raw) || bytes.TrimSpace(raw)[0] != '{' {
+		return raw, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	var typ string
+	if t, ok := fields["@type"]; ok {
+		if err := json.Unmarshal(t, &typ); err != nil {
+			return nil, fmt.Errorf("decoding @type, %w", err)
+		}
+		delete(fields, "@type")
+	}
+	if _, ok := fields["kind"]; !ok && strings.Contains(typ, ".") {
+		fields["kind"] = lo.Must(json.Marshal(typ[strings.LastIndex(typ, ".")+1:]))
+		if _, ok := fields["apiVersion"]; !ok {
+			fields["apiVersion"] = lo.Must(json.Marshal(schema.GroupVersion{Group: ref.APIGroup, Version: ref.APIVersion}.String()))
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func isEmptyJSON(raw json.RawMessage) bool {
+	return len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null"
+}
@@ -0,0 +1,267 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var gkeStart = time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+
+func TestParseResourceName(t *testing.T) {
+	for _, tc := range []struct {
+		resourceName string
+		want         auditmodel.ObjectReference
+		wantURI      string
+		wantErr      bool
+	}{
+		{
+			resourceName: "core/v1/namespaces/default/pods/web",
+			want:         auditmodel.ObjectReference{APIVersion: "v1", Namespace: "default", Resource: "pods", Name: "web"},
+			wantURI:      "/api/v1/namespaces/default/pods/web",
+		},
+		{
+			resourceName: "core/v1/namespaces/default/pods/web/binding",
+			want:         auditmodel.ObjectReference{APIVersion: "v1", Namespace: "default", Resource: "pods", Name: "web", Subresource: "binding"},
+			wantURI:      "/api/v1/namespaces/default/pods/web/binding",
+		},
+		{
+			resourceName: "apps/v1/namespaces/default/deployments",
+			want:         auditmodel.ObjectReference{APIGroup: "apps", APIVersion: "v1", Namespace: "default", Resource: "deployments"},
+			wantURI:      "/apis/apps/v1/namespaces/default/deployments",
+		},
+		{
+			resourceName: "apps/v1/namespaces/default/deployments/web/scale",
+			want:         auditmodel.ObjectReference{APIGroup: "apps", APIVersion: "v1", Namespace: "default", Resource: "deployments", Name: "web", Subresource: "scale"},
+			wantURI:      "/apis/apps/v1/namespaces/default/deployments/web/scale",
+		},
+		{
+			resourceName: "core/v1/nodes/gke-c-default-pool-1a2b3c4d-x9z8",
+			want:         auditmodel.ObjectReference{APIVersion: "v1", Resource: "nodes", Name: "gke-c-default-pool-1a2b3c4d-x9z8"},
+			wantURI:      "/api/v1/nodes/gke-c-default-pool-1a2b3c4d-x9z8",
+		},
+		{
+			resourceName: "core/v1/nodes/gke-c-default-pool-1a2b3c4d-x9z8/status",
+			want:         auditmodel.ObjectReference{APIVersion: "v1", Resource: "nodes", Name: "gke-c-default-pool-1a2b3c4d-x9z8", Subresource: "status"},
+			wantURI:      "/api/v1/nodes/gke-c-default-pool-1a2b3c4d-x9z8/status",
+		},
+		{
+			// A namespace is itself a cluster-scoped object
+			resourceName: "core/v1/namespaces/kube-system",
+			want:         auditmodel.ObjectReference{APIVersion: "v1", Resource: "namespaces", Name: "kube-system"},
+			wantURI:      "/api/v1/namespaces/kube-system",
+		},
+		{
+			resourceName: "rbac.authorization.k8s.io/v1/clusterroles/view",
+			want:         auditmodel.ObjectReference{APIGroup: "rbac.authorization.k8s.io", APIVersion: "v1", Resource: "clusterroles", Name: "view"},
+			wantURI:      "/apis/rbac.authorization.k8s.io/v1/clusterroles/view",
+		},
+		{
+			resourceName: "autoscaling/v2beta2/namespaces/default/horizontalpodautoscalers/web",
+			want:         auditmodel.ObjectReference{APIGroup: "autoscaling", APIVersion: "v2beta2", Namespace: "default", Resource: "horizontalpodautoscalers", Name: "web"},
+			wantURI:      "/apis/autoscaling/v2beta2/namespaces/default/horizontalpodautoscalers/web",
+		},
+		{resourceName: "projects/my-project/locations/us-central1/clusters/c", wantErr: true},
+		{resourceName: "projects/my-project/zones/us-central1-a/clusters/c/nodePools/default-pool", wantErr: true},
+		{resourceName: "core/v1", wantErr: true},
+		{resourceName: "core/v1/namespaces/default/pods//binding", wantErr: true},
+		{resourceName: "", wantErr: true},
+	} {
+		t.Run(tc.resourceName, func(t *testing.T) {
+			ref, uri, ok := parseResourceName(tc.resourceName)
+			if ok == tc.wantErr {
+				t.Fatalf("got ok %v, want %v", ok, !tc.wantErr)
+			}
+			if ref != tc.want || uri != tc.wantURI {
+				t.Errorf("got %+v at %q, want %+v at %q", ref, uri, tc.want, tc.wantURI)
+			}
+		})
+	}
+}
+
+// gkePodCreate, gkeBinding, gkeConflict and gkeNodePatch are Cloud Logging entries as a Cloud Storage
+// sink exports them, with the request and response objects trimmed
+const (
+	gkePodCreate = `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","authenticationInfo":{"principalEmail":"system:serviceaccount:kube-system:replicaset-controller"},"authorizationInfo":[{"granted":true,"permission":"io.k8s.core.v1.pods.create","resource":"core/v1/namespaces/default/pods"}],"methodName":"io.k8s.core.v1.pods.create","requestMetadata":{"callerIp":"172.16.0.2","callerSuppliedUserAgent":"kube-controller-manager/v1.30.5 (linux/amd64) kubernetes/74e84a9/system:serviceaccount:kube-system:replicaset-controller"},"resourceName":"core/v1/namespaces/default/pods","serviceName":"k8s.io","status":{"code":0},"request":{"@type":"core.k8s.io/v1.Pod","metadata":{"generateName":"web-7c5ddbdf54-","namespace":"default"},"spec":{"containers":[{"name":"web","image":"nginx:1.27"}]}},"response":{"@type":"core.k8s.io/v1.Pod","apiVersion":"v1","kind":"Pod","metadata":{"name":"web-7c5ddbdf54-x2v9k","generateName":"web-7c5ddbdf54-","namespace":"default","uid":"6f1c9b1e-7d2a-4c1e-9a64-0f0c2d6c9b11","resourceVersion":"1234567"},"spec":{"containers":[{"name":"web","image":"nginx:1.27"}]},"status":{"phase":"Pending"}}},"insertId":"3b5a4b0e-2f1c-4a5e-9d8f-6c7b8a9d0e1f","resource":{"type":"k8s_cluster","labels":{"project_id":"my-project","location":"us-central1","cluster_name":"c"}},"timestamp":"2025-09-15T16:00:01.123456Z","severity":"NOTICE","logName":"projects/my-project/logs/cloudaudit.googleapis.com%2Factivity","operation":{"id":"6e0e7c3c-4a36-4f0a-8b9a-2b5f5f7c1d2e","producer":"k8s.io","first":true,"last":true},"receiveTimestamp":"2025-09-15T16:00:02.345678Z"}`
+	gkeBinding   = `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","authenticationInfo":{"principalEmail":"system:kube-scheduler"},"methodName":"io.k8s.core.v1.pods.binding.create","resourceName":"core/v1/namespaces/default/pods/web-7c5ddbdf54-x2v9k/binding","serviceName":"k8s.io","status":{"code":0},"request":{"@type":"core.k8s.io/v1.Binding","metadata":{"name":"web-7c5ddbdf54-x2v9k","namespace":"default","uid":"6f1c9b1e-7d2a-4c1e-9a64-0f0c2d6c9b11"},"target":{"kind":"Node","name":"gke-c-default-pool-1a2b3c4d-x9z8"}},"response":{"@type":"core.k8s.io/v1.Status","status":"Success","code":201}},"insertId":"9c8b7a6d-5e4f-4a3b-2c1d-0e9f8a7b6c5d","resource":{"type":"k8s_cluster","labels":{"cluster_name":"c"}},"timestamp":"2025-09-15T16:00:01.5Z","severity":"NOTICE","logName":"projects/my-project/logs/cloudaudit.googleapis.com%2Factivity","operation":{"id":"0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e","producer":"k8s.io","first":true,"last":true}}`
+	gkeConflict  = `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","authenticationInfo":{"principalEmail":"system:serviceaccount:kube-system:deployment-controller"},"methodName":"io.k8s.apps.v1.deployments.status.update","resourceName":"apps/v1/namespaces/default/deployments/web/status","serviceName":"k8s.io","status":{"code":10,"message":"Operation cannot be fulfilled on deployments.apps \"web\": the object has been modified; please apply your changes to the latest version and try again"}},"insertId":"1a2b3c4d","resource":{"type":"k8s_cluster","labels":{"cluster_name":"c"}},"timestamp":"2025-09-15T16:00:03Z","severity":"ERROR","logName":"projects/my-project/logs/cloudaudit.googleapis.com%2Factivity"}`
+	gkeNodePatch = `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","authenticationInfo":{"principalEmail":"system:node:gke-c-default-pool-1a2b3c4d-x9z8"},"methodName":"io.k8s.core.v1.nodes.status.patch","resourceName":"core/v1/nodes/gke-c-default-pool-1a2b3c4d-x9z8/status","serviceName":"k8s.io","status":{"code":7,"message":"nodes \"gke-c-default-pool-1a2b3c4d-x9z8\" is forbidden"},"request":{"status":{"conditions":[{"type":"Ready","status":"True"}]}}},"insertId":"5e6f7a8b","resource":{"type":"k8s_cluster","labels":{"cluster_name":"c"}},"timestamp":"2025-09-15T16:00:04Z","severity":"ERROR","logName":"projects/my-project/logs/cloudaudit.googleapis.com%2Fdata_access","operation":{"id":"7c8d9e0f","producer":"k8s.io","first":true,"last":true}}`
+	gkeUnknown   = `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","authenticationInfo":{"principalEmail":"admin@my-project.iam.gserviceaccount.com"},"methodName":"io.k8s.core.v1.configmaps.delete","resourceName":"core/v1/namespaces/default/configmaps/settings","serviceName":"k8s.io","status":{"code":2,"message":"etcdserver: request timed out"}},"insertId":"2b3c4d5e","timestamp":"2025-09-15T16:00:05Z","operation":{"id":"8d9e0f1a","producer":"k8s.io","first":true,"last":true}}`
+	gkeCluster   = `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","authenticationInfo":{"principalEmail":"admin@example.com"},"methodName":"google.container.v1.ClusterManager.UpdateCluster","resourceName":"projects/my-project/locations/us-central1/clusters/c","serviceName":"container.googleapis.com","status":{}},"insertId":"-abc123","resource":{"type":"gke_cluster","labels":{"cluster_name":"c"}},"timestamp":"2025-09-15T16:00:06Z","severity":"NOTICE","logName":"projects/my-project/logs/cloudaudit.googleapis.com%2Factivity","operation":{"id":"operation-1726416006","producer":"container.googleapis.com","first":true}}`
+)
+
+func TestGKEEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		entry        string
+		want         auditmodel.Event
+		wantStatus   int32
+		wantRequest  map[string]any
+		wantResponse map[string]any
+	}{
+		{
+			name:  "create with a response",
+			entry: gkePodCreate,
+			want: auditmodel.Event{
+				Level: "RequestResponse", AuditID: "6e0e7c3c-4a36-4f0a-8b9a-2b5f5f7c1d2e", RequestURI: "/api/v1/namespaces/default/pods", Verb: "create",
+				User:      auditmodel.User{Username: "system:serviceaccount:kube-system:replicaset-controller"},
+				ObjectRef: &auditmodel.ObjectReference{APIVersion: "v1", Namespace: "default", Resource: "pods"},
+			},
+			wantStatus: http.StatusOK,
+			// The type of the request is restored from @type, and the response keeps its own
+			wantRequest:  map[string]any{"apiVersion": "v1", "kind": "Pod"},
+			wantResponse: map[string]any{"apiVersion": "v1", "kind": "Pod", "uid": "6f1c9b1e-7d2a-4c1e-9a64-0f0c2d6c9b11"},
+		},
+		{
+			name:  "subresource",
+			entry: gkeBinding,
+			want: auditmodel.Event{
+				Level: "RequestResponse", AuditID: "0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e", RequestURI: "/api/v1/namespaces/default/pods/web-7c5ddbdf54-x2v9k/binding", Verb: "create",
+				User:      auditmodel.User{Username: "system:kube-scheduler"},
+				ObjectRef: &auditmodel.ObjectReference{APIVersion: "v1", Namespace: "default", Resource: "pods", Name: "web-7c5ddbdf54-x2v9k", Subresource: "binding"},
+			},
+			wantStatus:   http.StatusOK,
+			wantRequest:  map[string]any{"apiVersion": "v1", "kind": "Binding"},
+			wantResponse: map[string]any{"apiVersion": "v1", "kind": "Status"},
+		},
+		{
+			name:  "conflict without an operation",
+			entry: gkeConflict,
+			want: auditmodel.Event{
+				Level: "Metadata", AuditID: "1a2b3c4d", RequestURI: "/apis/apps/v1/namespaces/default/deployments/web/status", Verb: "update",
+				User:      auditmodel.User{Username: "system:serviceaccount:kube-system:deployment-controller"},
+				ObjectRef: &auditmodel.ObjectReference{APIGroup: "apps", APIVersion: "v1", Namespace: "default", Resource: "deployments", Name: "web", Subresource: "status"},
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:  "forbidden patch of a cluster-scoped object",
+			entry: gkeNodePatch,
+			want: auditmodel.Event{
+				Level: "RequestResponse", AuditID: "7c8d9e0f", RequestURI: "/api/v1/nodes/gke-c-default-pool-1a2b3c4d-x9z8/status", Verb: "patch",
+				User:      auditmodel.User{Username: "system:node:gke-c-default-pool-1a2b3c4d-x9z8"},
+				ObjectRef: &auditmodel.ObjectReference{APIVersion: "v1", Resource: "nodes", Name: "gke-c-default-pool-1a2b3c4d-x9z8", Subresource: "status"},
+			},
+			wantStatus: http.StatusForbidden,
+			// A patch has no type, and is passed through as it was sent
+			wantRequest: map[string]any{"apiVersion": nil, "kind": nil},
+		},
+		{
+			name:  "unknown gRPC code",
+			entry: gkeUnknown,
+			want: auditmodel.Event{
+				Level: "Metadata", AuditID: "8d9e0f1a", RequestURI: "/api/v1/namespaces/default/configmaps/settings", Verb: "delete",
+				User:      auditmodel.User{Username: "admin@my-project.iam.gserviceaccount.com"},
+				ObjectRef: &auditmodel.ObjectReference{APIVersion: "v1", Namespace: "default", Resource: "configmaps", Name: "settings"},
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header, err := gkeFormat{}.header([]byte(tc.entry))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if header.AuditID != tc.want.AuditID || header.ObjectRef == nil || *header.ObjectRef != *tc.want.ObjectRef {
+				t.Errorf("got header %s %+v, want %s %+v", header.AuditID, header.ObjectRef, tc.want.AuditID, tc.want.ObjectRef)
+			}
+			e, err := gkeFormat{}.event([]byte(tc.entry))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e.Kind != "Event" || e.APIVersion != "audit.k8s.io/v1" || e.Stage != "ResponseComplete" {
+				t.Errorf("got %s %s at stage %s, want a ResponseComplete audit.k8s.io/v1 Event", e.APIVersion, e.Kind, e.Stage)
+			}
+			if e.Level != tc.want.Level || e.AuditID != tc.want.AuditID || e.RequestURI != tc.want.RequestURI || e.Verb != tc.want.Verb || e.User.Username != tc.want.User.Username {
+				t.Errorf("got %s %s %s %s by %s, want %s %s %s %s by %s", e.Level, e.AuditID, e.Verb, e.RequestURI, e.User.Username,
+					tc.want.Level, tc.want.AuditID, tc.want.Verb, tc.want.RequestURI, tc.want.User.Username)
+			}
+			if *e.ObjectRef != *tc.want.ObjectRef {
+				t.Errorf("got objectRef %+v, want %+v", *e.ObjectRef, *tc.want.ObjectRef)
+			}
+			if !e.RequestReceivedTimestamp.Equal(&header.RequestReceivedTimestamp) || e.RequestReceivedTimestamp.IsZero() {
+				t.Errorf("got timestamp %s, want %s", e.RequestReceivedTimestamp, header.RequestReceivedTimestamp)
+			}
+			if e.ResponseStatus == nil || e.ResponseStatus.Code != tc.wantStatus {
+				t.Errorf("got status %+v, want code %d", e.ResponseStatus, tc.wantStatus)
+			}
+			checkObject(t, "request", e.RequestObject, tc.wantRequest)
+			checkObject(t, "response", e.ResponseObject, tc.wantResponse)
+		})
+	}
+}
+
+// checkObject checks the top-level fields of an object, and the uid in its metadata, failing if the
+// object is logged when no fields are wanted. @type is never expected to be left in the object.
+func checkObject(t *testing.T, name string, raw json.RawMessage, want map[string]any) {
+	t.Helper()
+	if want == nil {
+		if !isEmptyJSON(raw) {
+			t.Errorf("got %s %s, want none", name, raw)
+		}
+		return
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		t.Fatalf("decoding %s, %v", name, err)
+	}
+	if _, ok := obj["@type"]; ok {
+		t.Errorf("%s still has @type", name)
+	}
+	for field, value := range want {
+		got := obj[field]
+		if field == "uid" {
+			got = obj["metadata"].(map[string]any)["uid"]
+		}
+		if got != value {
+			t.Errorf("%s has %s %v, want %v", name, field, got, value)
+		}
+	}
+}
+
+func TestGKESkipsOtherServices(t *testing.T) {
+	header, err := gkeFormat{}.header([]byte(gkeCluster))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if header.ObjectRef != nil {
+		t.Errorf("got objectRef %+v for GKE's own admin activity, want none", header.ObjectRef)
+	}
+}
+
+func TestGKEProviderReadsExports(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		log  string
+	}{
+		{name: "JSON lines", log: gkePodCreate + "\n" + gkeCluster + "\n" + gkeBinding},
+		// Like gcloud logging read --order=asc --format=json prints them
+		{name: "JSON array", log: "[\n" + gkePodCreate + ",\n" + gkeCluster + ",\n" + gkeBinding + "\n]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := NewGKE(test.WriteFile(t, "entries.json", tc.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for e, err := range g.GetEvents(context.Background(), object.PodParser{}, "describe", gkeStart, gkeStart.Add(time.Minute), types.NamespacedName{Namespace: "default", Name: "web-7c5ddbdf54-x2v9k"}) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got = append(got, e.Verb+" "+e.RequestURI)
+			}
+			want := []string{"create /api/v1/namespaces/default/pods", "create /api/v1/namespaces/default/pods/web-7c5ddbdf54-x2v9k/binding"}
+			if !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
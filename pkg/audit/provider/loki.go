@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// lokiPageLimit is the number of lines that a single query_range request asks for, which is Loki's
+	// default max_entries_limit_per_query
+	lokiPageLimit = 5000
+	// lokiWindow is how much of the time window a single query_range request covers. Loki rejects or
+	// splits queries over long ranges, so the time window is queried one piece at a time.
+	lokiWindow = time.Hour
+	// lokiRequestTimeout bounds a single query_range request, so that a Loki that stops responding
+	// fails the command instead of hanging it
+	lokiRequestTimeout = 2 * time.Minute
+)
+
+// Loki reads audit events from Grafana Loki through its query_range API. The audit events are the
+// log lines of the streams that the selector matches, as shipped by Promtail, Alloy or the like.
+type Loki struct {
+	client   *http.Client
+	url      string
+	selector string
+	orgID    string
+}
+
+// NewLoki creates a Loki provider for the Loki at url. orgID is sent as the tenant of a multi-tenant
+// Loki, and left out when it's empty. The provider has a client and connections of its own, which
+// are closed along with it.
+func NewLoki(url, selector, orgID string) (*Loki, error) {
+	client := &http.Client{
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		Timeout:   lokiRequestTimeout,
+	}
+	return NewLokiFromClient(client, url, selector, orgID)
+}
+
+// NewLokiFromClient creates a Loki provider that sends its requests through the given client
+func NewLokiFromClient(client *http.Client, lokiURL, selector, orgID string) (*Loki, error) {
+	if _, err := url.Parse(lokiURL); err != nil {
+		return nil, fmt.Errorf("parsing loki url, %w", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(selector), "{") {
+		return nil, fmt.Errorf("loki selector %q isn't a stream selector like {job=\"audit\"}", selector)
+	}
+	return &Loki{client: client, url: strings.TrimSuffix(lokiURL, "/"), selector: selector, orgID: orgID}, nil
+}
+
+// lokiEntry is a log line of a query_range response
+type lokiEntry struct {
+	timestamp time.Time
+	line      string
+}
+
+// Close closes the idle connections that are kept open to Loki between queries
+func (l *Loki) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
+
+// QueryRange runs a single query_range request over [startTime, endTime) and returns up to
+// lokiPageLimit lines from the start of the range, oldest first
+func (l *Loki) QueryRange(ctx context.Context, query string, startTime, endTime time.Time) ([]lokiEntry, error) {
+	params := url.Values{
+		"query":     {query},
+		"start":     {strconv.FormatInt(startTime.UnixNano(), 10)},
+		"end":       {strconv.FormatInt(endTime.UnixNano(), 10)},
+		"limit":     {strconv.Itoa(lokiPageLimit)},
+		"direction": {"forward"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating query_range request, %w", err)
+	}
+	if l.orgID != "" {
+		req.Header.Set("X-Scope-OrgID", l.orgID)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying loki, %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("querying loki, %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Values [][2]string `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding query_range response, %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("querying loki, status %q: %s", result.Status, result.Error)
+	}
+	if result.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("query_range returned %q instead of streams", result.Data.ResultType)
+	}
+	var entries []lokiEntry
+	for _, stream := range result.Data.Result {
+		for _, v := range stream.Values {
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing timestamp %q of query_range response, %w", v[0], err)
+			}
+			entries = append(entries, lokiEntry{timestamp: time.Unix(0, ns), line: v[1]})
+		}
+	}
+	// The lines of each stream are ordered, but the streams are interleaved
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].timestamp.Before(entries[j].timestamp) })
+	return entries, nil
+}
+
+// QueryAll runs the query over [startTime, endTime] one window of lokiWindow at a time, and pages
+// through each window from the last line of a full page. Lines at the boundary of a page are
+// returned again by the next one.
+func (l *Loki) QueryAll(ctx context.Context, query string, startTime, endTime time.Time) iter.Seq2[lokiEntry, error] {
+	return func(yield func(lokiEntry, error) bool) {
+		for windowStart := startTime; windowStart.Before(endTime); {
+			windowEnd := lo.Earliest(windowStart.Add(lokiWindow), endTime)
+			// The end of a range is exclusive, so the last window is stretched to take in its end
+			end := lo.Ternary(windowEnd.Equal(endTime), windowEnd.Add(time.Nanosecond), windowEnd)
+			for start := windowStart; ; {
+				entries, err := l.QueryRange(ctx, query, start, end)
+				if err != nil {
+					yield(lokiEntry{}, err)
+					return
+				}
+				for _, e := range entries {
+					if !yield(e, nil) {
+						return
+					}
+				}
+				if len(entries) < lokiPageLimit {
+					break
+				}
+				next := entries[len(entries)-1].timestamp
+				if !next.After(start) {
+					// A whole page shares a timestamp, so the lines after it at the same time can't be reached
+					fmt.Fprintf(os.Stderr, "Warning: more than %d events at %s, results are truncated\n", lokiPageLimit, start.UTC().Format(time.RFC3339Nano))
+					next = start.Add(time.Nanosecond)
+				}
+				start = next
+			}
+			windowStart = windowEnd
+		}
+	}
+}
+
+func (l *Loki) GetEvents(ctx context.Context, parser object.ObjectParser, cmdType string, startTime, endTime time.Time, nn types.NamespacedName) iter.Seq2[auditmodel.Event, error] {
+	q, _, err := queryFor(parser, cmdType, nn)
+	if err != nil {
+		return failedEvents(err)
+	}
+	query := logQL(l.selector, q)
+	return uniqueEvents(func(yield func(auditmodel.Event, error) bool) {
+		for entry, err := range l.QueryAll(ctx, query, startTime, endTime) {
+			if err != nil {
+				yield(auditmodel.Event{}, err)
+				return
+			}
+			var auditEvent auditmodel.Event
+			if err := json.Unmarshal([]byte(entry.line), &auditEvent); err != nil {
+				if !yield(auditmodel.Event{}, &object.ParseError{Err: err}) {
+					return
+				}
+				continue
+			}
+			if !yield(auditEvent, nil) {
+				return
+			}
+		}
+	})
+}
+
+// logQL renders a query in LogQL. Substrings of the whole line become line filters, which Loki
+// evaluates before parsing anything, and the verb and request URI are matched with label filters on
+// the fields that the json parser extracts. When alternatives skip Contains, the line only has to
+// contain one of the query's substrings, so whether it contains Contains is extracted into the
+// contains label for the alternatives that don't skip it to check, like insightsQuery does.
+func logQL(selector string, q object.Query) string {
+	// Alternatives that skip Contains leave it to the others to check
+	skipContains := lo.SomeBy(q.URIs, func(m object.URIMatch) bool { return m.SkipContains }) && q.Contains != ""
+	b := &strings.Builder{}
+	b.WriteString(selector)
+	if substrings := q.Substrings(); len(substrings) == 1 {
+		fmt.Fprintf(b, " |= %s", strconv.Quote(substrings[0]))
+	} else if len(substrings) > 1 {
+		fmt.Fprintf(b, " |~ %s", strconv.Quote(strings.Join(lo.Map(substrings, func(s string, _ int) string { return regexp.QuoteMeta(s) }), "|")))
+	}
+	b.WriteString(` | json verb="verb", requestURI="requestURI"`)
+	if skipContains {
+		// The regexp parser doesn't set the label on lines that don't match
+		fmt.Fprintf(b, " | regexp %s", strconv.Quote("(?P<contains>"+regexp.QuoteMeta(q.Contains)+")"))
+	}
+	if len(q.Verbs) > 0 {
+		fmt.Fprintf(b, " | %s", logQLVerbs(q.Verbs))
+	}
+	if len(q.URIs) > 0 {
+		clauses := lo.Map(q.URIs, func(m object.URIMatch, _ int) string {
+			var conditions []string
+			for _, c := range m.Contains {
+				conditions = append(conditions, "requestURI=~"+strconv.Quote(".*"+regexp.QuoteMeta(c)+".*"))
+			}
+			for _, e := range m.Excludes {
+				conditions = append(conditions, "requestURI!~"+strconv.Quote(".*"+regexp.QuoteMeta(e)+".*"))
+			}
+			if len(m.Verbs) > 0 {
+				conditions = append(conditions, logQLVerbs(m.Verbs))
+			}
+			if skipContains && !m.SkipContains {
+				conditions = append(conditions, `contains!=""`)
+			}
+			return "(" + strings.Join(conditions, " and ") + ")"
+		})
+		fmt.Fprintf(b, " | %s", strings.Join(clauses, " or "))
+	}
+	for _, c := range q.URIContains {
+		fmt.Fprintf(b, " | requestURI=~%s", strconv.Quote(".*"+regexp.QuoteMeta(c)+".*"))
+	}
+	return b.String()
+}
+
+// logQLVerbs matches the verbs, whose regular expression is anchored like every LogQL matcher
+func logQLVerbs(verbs []string) string {
+	if len(verbs) == 1 {
+		return "verb=" + strconv.Quote(verbs[0])
+	}
+	return "verb=~" + strconv.Quote(strings.Join(lo.Map(verbs, func(v string, _ int) string { return regexp.QuoteMeta(v) }), "|"))
+}
@@ -0,0 +1,300 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/object"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeLoki answers query_range requests from its entries like Loki does, split across two streams
+type fakeLoki struct {
+	entries []lokiEntry
+
+	mu       sync.Mutex
+	requests []http.Request
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, *r)
+	f.mu.Unlock()
+	if r.URL.Path != "/loki/api/v1/query_range" {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if q.Get("direction") != "forward" {
+		http.Error(w, "only forward queries are faked", http.StatusBadRequest)
+		return
+	}
+	streams := [2][][2]string{}
+	n := 0
+	for _, e := range f.entries {
+		// Like Loki, start is inclusive and end is exclusive
+		if ns := e.timestamp.UnixNano(); ns < start || ns >= end || n == limit {
+			continue
+		}
+		streams[n%2] = append(streams[n%2], [2]string{strconv.FormatInt(e.timestamp.UnixNano(), 10), e.line})
+		n++
+	}
+	result := []map[string]any{}
+	for i, values := range streams {
+		if len(values) > 0 {
+			result = append(result, map[string]any{"stream": map[string]string{"job": "audit", "pod": fmt.Sprint(i)}, "values": values})
+		}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": map[string]any{"resultType": "streams", "result": result}})
+}
+
+func lokiLine(auditID, stage string, at time.Time) string {
+	return fmt.Sprintf(`{"auditID":%q,"stage":%q,"verb":"update","requestReceivedTimestamp":%q}`, auditID, stage, at.Format(time.RFC3339Nano))
+}
+
+func newTestLoki(t *testing.T, handler http.Handler, orgID string) *Loki {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	l, err := NewLokiFromClient(server.Client(), server.URL+"/", `{job="audit"}`, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+var lokiStart = time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+
+func TestLokiPagesThroughWindows(t *testing.T) {
+	fake := &fakeLoki{}
+	// A burst of 12000 events in the first hour takes three pages, a handful more are spread over
+	// the next hour and a half, and the last one is logged at the very end of the time window
+	for i := range 12000 {
+		fake.entries = append(fake.entries, lokiEntry{timestamp: lokiStart.Add(time.Duration(i) * 100 * time.Millisecond)})
+	}
+	for i := range 13 {
+		fake.entries = append(fake.entries, lokiEntry{timestamp: lokiStart.Add(time.Hour + time.Duration(i)*7*time.Minute)})
+	}
+	end := lokiStart.Add(150 * time.Minute)
+	fake.entries = append(fake.entries, lokiEntry{timestamp: end})
+	for i := range fake.entries {
+		fake.entries[i].line = lokiLine(fmt.Sprintf("e%05d", i), "ResponseComplete", fake.entries[i].timestamp)
+	}
+	l := newTestLoki(t, fake, "")
+
+	var got []string
+	for e, err := range l.GetEvents(context.Background(), object.PodParser{}, "get", lokiStart, end, types.NamespacedName{Namespace: "default", Name: "web"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, e.AuditID)
+	}
+	if len(got) != len(fake.entries) {
+		t.Fatalf("got %d events, want %d", len(got), len(fake.entries))
+	}
+	if !sort.StringsAreSorted(got) {
+		t.Errorf("events aren't in order")
+	}
+	// Every page runs the same query, which matches the pod's name before decoding the line
+	const wantQuery = `{job="audit"} |= "web" | json verb="verb", requestURI="requestURI" | verb=~"create|update|patch|apply|delete" | requestURI=~".*pods.*" | requestURI=~".*/namespaces/default/.*"`
+	for _, r := range fake.requests {
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+		if window := time.Duration(end - start); window > lokiWindow+time.Nanosecond {
+			t.Errorf("request covers %s, longer than %s", window, lokiWindow)
+		}
+		if q.Get("limit") != strconv.Itoa(lokiPageLimit) {
+			t.Errorf("request asks for %s lines, want %d", q.Get("limit"), lokiPageLimit)
+		}
+		if q.Get("query") != wantQuery {
+			t.Errorf("got query\n%s\nwant\n%s", q.Get("query"), wantQuery)
+		}
+		if r.Header.Get("X-Scope-OrgID") != "" {
+			t.Errorf("request has a tenant without an org ID")
+		}
+	}
+	// Three pages of the first hour, one page each of the rest of the window
+	if len(fake.requests) != 5 {
+		t.Errorf("made %d requests, want 5", len(fake.requests))
+	}
+}
+
+func TestLokiDeduplicatesStages(t *testing.T) {
+	fake := &fakeLoki{entries: []lokiEntry{
+		{timestamp: lokiStart, line: lokiLine("a", "RequestReceived", lokiStart)},
+		{timestamp: lokiStart.Add(time.Second), line: lokiLine("a", "ResponseComplete", lokiStart)},
+		{timestamp: lokiStart.Add(2 * time.Second), line: lokiLine("b", "ResponseComplete", lokiStart.Add(2*time.Second))},
+	}}
+	l := newTestLoki(t, fake, "")
+	var got []string
+	for e, err := range l.GetEvents(context.Background(), object.PodParser{}, "get", lokiStart, lokiStart.Add(time.Minute), types.NamespacedName{Namespace: "default", Name: "web"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, e.AuditID+"/"+e.Stage)
+	}
+	if want := "a/ResponseComplete b/ResponseComplete"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestLokiSendsOrgID(t *testing.T) {
+	fake := &fakeLoki{}
+	l := newTestLoki(t, fake, "tenant-1")
+	if _, err := l.QueryRange(context.Background(), `{job="audit"}`, lokiStart, lokiStart.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fake.requests[0].Header.Get("X-Scope-OrgID"); got != "tenant-1" {
+		t.Errorf("X-Scope-OrgID is %q, want tenant-1", got)
+	}
+}
+
+func TestLokiErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "non-2xx",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "no org id", http.StatusUnauthorized)
+			},
+			want: "401 Unauthorized: no org id",
+		},
+		{
+			name: "error status",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"status":"error","error":"max entries limit per query exceeded"}`)
+			},
+			want: `status "error": max entries limit per query exceeded`,
+		},
+		{
+			name: "matrix",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[]}}`)
+			},
+			want: `returned "matrix" instead of streams`,
+		},
+		{
+			name: "malformed",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `{"status":`)
+			},
+			want: "decoding query_range response",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newTestLoki(t, tc.handler, "")
+			var errs []error
+			for _, err := range l.GetEvents(context.Background(), object.PodParser{}, "get", lokiStart, lokiStart.Add(3*time.Hour), types.NamespacedName{Namespace: "default", Name: "web"}) {
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if len(errs) != 1 {
+				t.Fatalf("got errors %v, want one", errs)
+			}
+			if !strings.Contains(errs[0].Error(), tc.want) {
+				t.Errorf("error %q doesn't contain %q", errs[0], tc.want)
+			}
+		})
+	}
+}
+
+func TestNewLokiFromClient(t *testing.T) {
+	for _, tc := range []struct {
+		url, selector string
+		wantErr       bool
+	}{
+		{url: "http://loki:3100", selector: `{job="audit"}`},
+		{url: "http://loki:3100", selector: ` {job=~".*audit.*"}`},
+		{url: "http://loki:3100", selector: `job="audit"`, wantErr: true},
+		{url: "http://loki:3100/\x7f", selector: `{job="audit"}`, wantErr: true},
+	} {
+		if _, err := NewLokiFromClient(http.DefaultClient, tc.url, tc.selector, ""); (err != nil) != tc.wantErr {
+			t.Errorf("NewLokiFromClient(%q, %q) returned %v, want error %v", tc.url, tc.selector, err, tc.wantErr)
+		}
+	}
+}
+
+func TestNewLokiHasItsOwnClient(t *testing.T) {
+	l, err := NewLoki("http://loki:3100", `{job="audit"}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closing the provider mustn't close the connections of the rest of the process
+	if l.client == http.DefaultClient || l.client.Transport == nil || l.client.Transport == http.DefaultTransport {
+		t.Error("the provider shares the default client or transport")
+	}
+	if l.client.Timeout == 0 {
+		t.Error("the provider's requests don't time out")
+	}
+}
+
+func TestLogQL(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		query object.Query
+		want  string
+	}{
+		{
+			name:  "empty",
+			query: object.Query{},
+			want:  `{job="audit"} | json verb="verb", requestURI="requestURI"`,
+		},
+		{
+			name:  "named object",
+			query: object.Query{Verbs: []string{"create", "delete"}, URIContains: []string{"/namespaces/default/"}, Contains: "web"},
+			want:  `{job="audit"} |= "web" | json verb="verb", requestURI="requestURI" | verb=~"create|delete" | requestURI=~".*/namespaces/default/.*"`,
+		},
+		{
+			name: "alternatives",
+			query: object.Query{URIs: []object.URIMatch{
+				{Contains: []string{"nodes"}, Excludes: []string{"csi"}},
+				{Contains: []string{"pods", "binding"}, Verbs: []string{"create"}},
+			}},
+			want: `{job="audit"} | json verb="verb", requestURI="requestURI" | (requestURI=~".*nodes.*" and requestURI!~".*csi.*") or (requestURI=~".*pods.*" and requestURI=~".*binding.*" and verb="create")`,
+		},
+		{
+			name: "alternative that skips the name",
+			query: object.Query{URIs: []object.URIMatch{
+				{Contains: []string{"nodes"}},
+				{Contains: []string{"pods"}, Verbs: []string{"delete"}, SkipContains: true},
+			}, Contains: "node-a.b"},
+			want: `{job="audit"} |~ "node-a\\.b|delete" | json verb="verb", requestURI="requestURI" | regexp "(?P<contains>node-a\\.b)" | (requestURI=~".*nodes.*" and contains!="") or (requestURI=~".*pods.*" and verb="delete")`,
+		},
+		{
+			name:  "describe node",
+			query: object.NodeParser{}.DescribeQuery(types.NamespacedName{Name: "node-a"}),
+			want: `{job="audit"} |~ "node-a|delete" | json verb="verb", requestURI="requestURI" | regexp "(?P<contains>node-a)" | verb=~"create|update|patch|apply|delete" | ` +
+				`(requestURI=~".*nodes.*" and requestURI!~".*csi.*" and requestURI!~".*cni.*" and contains!="") or ` +
+				`(requestURI=~".*pods.*" and requestURI=~".*binding.*" and contains!="") or ` +
+				`(requestURI=~".*pods.*" and verb="delete")`,
+		},
+		{
+			name:  "regexp metacharacters",
+			query: object.Query{URIContains: []string{"/apis/networking.k8s.io/"}, Contains: `say "hi"`},
+			want:  `{job="audit"} |= "say \"hi\"" | json verb="verb", requestURI="requestURI" | requestURI=~".*/apis/networking\\.k8s\\.io/.*"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := logQL(`{job="audit"}`, tc.query); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
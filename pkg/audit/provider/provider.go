@@ -2,13 +2,98 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"iter"
 	"time"
 
 	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
 	"github.com/joinnis/kubereplay/pkg/object"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
+// Provider streams the audit events relevant to an object. Events are produced lazily as the
+// sequence is consumed, so that memory stays bounded regardless of how large the underlying log is.
+// An *object.ParseError only affects a single event and is followed by the rest of the sequence,
+// while any other error ends it.
+//
+// cmdType is "get" or "describe" for the events of the named object, or "list" for the events of
+// every object in its namespace, which the parser must implement object.Lister for. The sequence
+// only yields an error for any other command type.
 type Provider interface {
-	GetEvents(ctx context.Context, parser object.ObjectParser, cmdType string, start, end time.Time, nn types.NamespacedName) ([]auditmodel.Event, error)
+	GetEvents(ctx context.Context, parser object.ObjectParser, cmdType string, start, end time.Time, nn types.NamespacedName) iter.Seq2[auditmodel.Event, error]
+	// Close releases what the provider holds on to between queries, once it's no longer queried
+	Close() error
+}
+
+// queryFor returns the query that a parser needs for a command type, for the providers that push
+// filtering down to a query engine, along with the filter for the providers that filter the events
+// themselves
+func queryFor(parser object.ObjectParser, cmdType string, nn types.NamespacedName) (object.Query, object.Filter, error) {
+	switch cmdType {
+	case "get":
+		return parser.GetQuery(nn), parser.GetFilter(nn), nil
+	case "describe":
+		return parser.DescribeQuery(nn), parser.DescribeFilter(nn), nil
+	case "list":
+		lister, ok := parser.(object.Lister)
+		if !ok {
+			return object.Query{}, nil, fmt.Errorf("listing %s isn't supported", parser.ObjectType())
+		}
+		return lister.ListQuery(nn.Namespace), lister.ListFilter(nn.Namespace), nil
+	}
+	return object.Query{}, nil, fmt.Errorf("invalid command type %q", cmdType)
+}
+
+// failedEvents returns a sequence that only yields err
+func failedEvents(err error) iter.Seq2[auditmodel.Event, error] {
+	return func(yield func(auditmodel.Event, error) bool) {
+		yield(auditmodel.Event{}, err)
+	}
+}
+
+// uniqueEvents drops the events that a query engine returns more than once, either because they're
+// returned by overlapping queries or because an audit policy logs a request once per stage. Each
+// request is yielded once, as its ResponseComplete event when that's logged. Events of the other
+// stages are held back until the end of the sequence and only yielded for the requests that never
+// completed, as the latest stage that was logged, like a Panic with the request's failed status.
+func uniqueEvents(events iter.Seq2[auditmodel.Event, error]) iter.Seq2[auditmodel.Event, error] {
+	return func(yield func(auditmodel.Event, error) bool) {
+		completed := sets.New[string]()
+		var incomplete []auditmodel.Event
+		incompleteIndex := map[string]int{}
+		for e, err := range events {
+			if err != nil {
+				if !yield(e, err) {
+					return
+				}
+				continue
+			}
+			if completed.Has(e.AuditID) {
+				continue
+			}
+			// Events logged without a stage are taken as complete, as there's nothing better to wait for
+			if e.Stage != "ResponseComplete" && e.Stage != "" {
+				if i, ok := incompleteIndex[e.AuditID]; !ok {
+					incompleteIndex[e.AuditID] = len(incomplete)
+					incomplete = append(incomplete, e)
+				} else if e.StageTimestamp.After(incomplete[i].StageTimestamp.Time) {
+					incomplete[i] = e
+				}
+				continue
+			}
+			completed.Insert(e.AuditID)
+			if !yield(e, nil) {
+				return
+			}
+		}
+		for _, e := range incomplete {
+			if completed.Has(e.AuditID) {
+				continue
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
 }
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func stageEvent(auditID, stage string, seconds int) auditmodel.Event {
+	at := metav1.NewTime(time.Date(2025, 9, 15, 16, 0, seconds, 0, time.UTC))
+	return auditmodel.Event{AuditID: auditID, Stage: stage, RequestReceivedTimestamp: at, StageTimestamp: at}
+}
+
+func eventsOf(events ...auditmodel.Event) iter.Seq2[auditmodel.Event, error] {
+	return func(yield func(auditmodel.Event, error) bool) {
+		for _, e := range events {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestUniqueEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		events []auditmodel.Event
+		want   []string
+	}{
+		{
+			name:   "stages of the same request",
+			events: []auditmodel.Event{stageEvent("a", "RequestReceived", 0), stageEvent("a", "ResponseComplete", 1), stageEvent("b", "ResponseComplete", 2)},
+			want:   []string{"a/ResponseComplete", "b/ResponseComplete"},
+		},
+		{
+			name:   "complete stage returned first",
+			events: []auditmodel.Event{stageEvent("a", "ResponseComplete", 1), stageEvent("a", "RequestReceived", 0)},
+			want:   []string{"a/ResponseComplete"},
+		},
+		{
+			name:   "returned by overlapping queries",
+			events: []auditmodel.Event{stageEvent("a", "ResponseComplete", 0), stageEvent("b", "ResponseComplete", 1), stageEvent("a", "ResponseComplete", 0)},
+			want:   []string{"a/ResponseComplete", "b/ResponseComplete"},
+		},
+		{
+			name:   "request that never completed",
+			events: []auditmodel.Event{stageEvent("a", "RequestReceived", 0), stageEvent("a", "Panic", 1), stageEvent("b", "ResponseComplete", 2)},
+			want:   []string{"b/ResponseComplete", "a/Panic"},
+		},
+		{
+			name:   "events without a stage",
+			events: []auditmodel.Event{stageEvent("a", "", 0), stageEvent("a", "", 0), stageEvent("b", "", 1)},
+			want:   []string{"a/", "b/"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for e, err := range uniqueEvents(eventsOf(tc.events...)) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got = append(got, e.AuditID+"/"+e.Stage)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUniqueEventsPassesErrorsThrough(t *testing.T) {
+	parseErr := &object.ParseError{AuditID: "b", Err: errors.New("unexpected end of JSON input")}
+	events := func(yield func(auditmodel.Event, error) bool) {
+		_ = yield(stageEvent("a", "ResponseComplete", 0), nil) &&
+			yield(auditmodel.Event{}, parseErr) &&
+			yield(stageEvent("c", "ResponseComplete", 1), nil)
+	}
+	var got []string
+	for e, err := range uniqueEvents(events) {
+		if err != nil {
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, e.AuditID)
+	}
+	if want := []string{"a", "error", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetEventsRejectsInvalidCommands(t *testing.T) {
+	file, err := NewFile(test.WriteFile(t, "audit.log", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loki, err := NewLokiFromClient(http.DefaultClient, "http://loki:3100", `{job="audit"}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// None of the providers gets as far as querying
+	providers := map[string]Provider{"file": file, "loki": loki, "cloudwatch": NewCloudWatchFromClient(nil, "audit", 0)}
+	for _, tc := range []struct {
+		name    string
+		parser  object.ObjectParser
+		cmdType string
+		wantErr string
+	}{
+		{name: "unknown command type", parser: object.PodParser{}, cmdType: "watch", wantErr: `invalid command type "watch"`},
+		{name: "list of a resource that can't be listed", parser: object.NodeParser{}, cmdType: "list", wantErr: "listing Node isn't supported"},
+	} {
+		for name, p := range providers {
+			t.Run(tc.name+" from "+name, func(t *testing.T) {
+				var errs []error
+				for _, err := range p.GetEvents(context.Background(), tc.parser, tc.cmdType, time.Time{}, time.Now(), types.NamespacedName{Name: "web"}) {
+					errs = append(errs, err)
+				}
+				if len(errs) != 1 || errs[0] == nil || errs[0].Error() != tc.wantErr {
+					t.Errorf("got %v, want only the error %q", errs, tc.wantErr)
+				}
+			})
+		}
+	}
+}
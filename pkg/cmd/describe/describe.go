@@ -3,67 +3,222 @@ package describe
 import (
 	"context"
 	"fmt"
-	"time"
+	"os"
+	"strings"
 
-	"github.com/joinnis/kubereplay/pkg/audit/provider"
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
 	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/printer"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/types"
 )
 
 var Cmd = &cobra.Command{
-	Use:   "describe",
+	Use:   "describe <resource>[.<group>] <name>",
 	Short: "Describe audit log events for Kubernetes resources",
-	Long: `Describe audit log events for Kubernetes resources from local files or CloudWatch Logs.
+	Long: `Describe audit log events for Kubernetes resources from local files, CloudWatch Logs or Grafana
+Loki.
 
 Supported resources:
-  pod    Describe events for a specific pod
-  node   Describe events for a specific node
+  pod         Describe events for a specific pod
+  node        Describe events for a specific node
+  nodeclaim   Describe events for a specific Karpenter NodeClaim
+  nodepool    Describe events for a specific Karpenter NodePool
+  deployment, replicaset, statefulset, daemonset, job, cronjob
+              Describe the rollouts, scale changes and owned objects of a specific workload
+
+Any other resource, including custom resources, is described from its audit events when it's given
+by its plural name and API group, like ingresses.networking.k8s.io. The namespace defaults to
+default and is ignored for nodes, nodeclaims, nodepools and built-in cluster-scoped resources like
+clusterroles.rbac.authorization.k8s.io. Pass --namespace "" for cluster-scoped custom resources.
 
 Additional Flags:
   --start        Duration value from the current time to start querying the audit logs
   --end          Duration value from the current time to finish querying the audit logs
 
 Data sources:
-  --audit-log    Local audit log file path
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
   --log-group    AWS CloudWatch log group name
   --region       AWS region for CloudWatch log group
   --account      AWS account ID for cross-account access
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Incarnations:
+  --uid          UID of the incarnation to show when an object has been re-created with the same name
+  --incarnation  1-based index of the incarnation to show, in order of creation
+
+Output:
+  -o, --output   json, yaml, name, jsonpath=<template>, go-template=<template> or
+                 custom-columns=<header>:<jsonpath>[,...]. The structured formats render the data that
+                 the description is made from, like the CreationTime, BindTime and EvictionTime of a
+                 pod, along with its latest state.
+  --no-headers   Leave the header row out of the custom-columns table
+  --show-managed-fields  Keep the managedFields of the latest state in the structured formats
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
 
 Examples:
   # Get pod events from local file
   kubereplay describe pod my-pod -n kube-system -f /var/log/audit.log
 
   # Get pod events from CloudWatch
-  kubereplay describe pod my-pod -n default -g /aws/eks/my-cluster/audit -r us-west-2`,
+  kubereplay describe pod my-pod -n default -g /aws/eks/my-cluster/audit -r us-west-2
+
+  # Get the lifecycle of a node and the pods that ran on it
+  kubereplay describe node i-0123456789 -g /aws/eks/my-cluster/audit -r us-west-2
+
+  # Show the rollout history of a deployment and the ReplicaSets that each rollout produced
+  kubereplay describe deployment my-deployment -n default -f /var/log/audit.log
+
+  # Describe any other resource by its plural name and API group
+  kubereplay describe ingresses.networking.k8s.io my-ingress -n default -f /var/log/audit.log`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDescribeCmd(cmd, args[0], args[1])
+	},
+}
+
+// workloadSummaries lists what the description of each workload kind works out
+var workloadSummaries = map[object.ObjectType][]string{
+	object.ObjectTypeDeployment: {
+		"Rollouts: each change to the pod template, who made it and the ReplicaSet it produced",
+		"Scale changes and who made them",
+		"Conditions like Available and Progressing, and pauses",
+		"The ReplicaSets it owns and how many pods each created",
+	},
+	object.ObjectTypeReplicaSet: {
+		"The Deployment that controls it",
+		"Scale changes and who made them",
+		"The pods it owns, found through their ownerReferences",
+	},
+	object.ObjectTypeStatefulSet: {
+		"Changes to the pod template and who made them",
+		"Scale changes and who made them",
+		"The pods it owns, including pods re-created with the same name",
+	},
+	object.ObjectTypeDaemonSet: {
+		"Changes to the pod template and who made them",
+		"The pods it owns, found through their ownerReferences",
+	},
+	object.ObjectTypeJob: {
+		"Conditions like Complete and Failed, and suspends",
+		"The CronJob that controls it",
+		"The pods it owns, found through their ownerReferences",
+	},
+	object.ObjectTypeCronJob: {
+		"Changes to the job template and who made them",
+		"Suspends and resumes",
+		"The Jobs it launched, found through their ownerReferences",
+	},
+}
+
+func init() {
+	Cmd.Flags().StringP("namespace", "n", "default", "Namespace of the object, ignored for known cluster-scoped resources")
+	printer.AddFlags(Cmd)
+	addDescribeFlags(Cmd)
+	for _, kind := range object.WorkloadTypes() {
+		Cmd.AddCommand(workloadCmd(kind))
+	}
+}
+
+// workloadCmd returns the subcommand that describes a workload of the given kind
+func workloadCmd(kind object.ObjectType) *cobra.Command {
+	resource := strings.ToLower(string(kind))
+	summary := lo.CoalesceSliceOrEmpty(workloadSummaries[kind], []string{"Changes to it and who made them", "The objects it owns"})
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s <%s-name>", resource, resource),
+		Short: fmt.Sprintf("Describe the history of a %s", kind),
+		Long: fmt.Sprintf(`Describe the history of a specific %[1]s from Kubernetes audit logs.
+
+This command analyzes audit logs to work out:
+  - %[3]s
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Describe a %[1]s from local audit log
+  kubereplay describe %[2]s my-%[2]s -n default -f /var/log/audit.log
+
+  # Describe a %[1]s from CloudWatch (requires AWS credentials)
+  kubereplay describe %[2]s my-%[2]s -n default -g /aws/eks/prod-cluster/audit -r us-west-2`, kind, resource, strings.Join(summary, "\n  - ")),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runDescribeCmd(cmd, resource, args[0])
+		},
+	}
+	cmd.Flags().StringP("namespace", "n", "default", fmt.Sprintf("Namespace of the %s", kind))
+	addDescribeFlags(cmd)
+	return cmd
+}
+
+// addDescribeFlags registers the flags that every command describing a single object takes, other
+// than its namespace
+func addDescribeFlags(cmd *cobra.Command) {
+	options.AddFlags(cmd)
+	cmd.Flags().StringP("uid", "", "", "UID of the incarnation to show when the object has been re-created with the same name")
+	cmd.Flags().IntP("incarnation", "", 0, "1-based index of the incarnation to show, in order of creation")
+}
+
+// runDescribeCmd describes the named object of a resource with the flags registered by addDescribeFlags
+func runDescribeCmd(cmd *cobra.Command, resource, name string) {
+	ctx := context.Background()
+	namespace, _ := cmd.Flags().GetString("namespace")
+	uid, _ := cmd.Flags().GetString("uid")
+	incarnation, _ := cmd.Flags().GetInt("incarnation")
+
+	opts, err := options.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	out, err := printer.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	parser := object.NewObjectParserFrom(resource)
+	if err := RunDescribe(ctx, parser, opts, out, object.NamespacedName(parser, namespace, name), uid, incarnation); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
 }
 
-func RunDescribe(ctx context.Context, cmd *cobra.Command, startTime, endTime time.Time, name, namespace, auditLogPath, logGroup, region string) error {
-	nn := types.NamespacedName{Namespace: namespace, Name: name}
-
-	var err error
-	var auditProvider provider.Provider
-	if logGroup != "" {
-		auditProvider, err = provider.NewCloudWatch(logGroup, region)
-		if err != nil {
-			return fmt.Errorf("initializing cloudwatch provider, %w", err)
-		}
-
-	} else {
-		auditProvider, err = provider.NewFile(auditLogPath)
-		if err != nil {
-			return fmt.Errorf("initializing file provider, %w", err)
-		}
+func RunDescribe(ctx context.Context, parser object.ObjectParser, opts options.Options, out printer.Printer, nn types.NamespacedName, uid string, incarnation int) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
 	}
-	auditEvents, err := auditProvider.GetEvents(ctx, object.NewObjectParserFrom(cmd.Name()), cmd.Parent().Name(), startTime, endTime, nn)
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	parsedEvents := opts.Events(ctx, auditProvider, parser, "describe", startTime, endTime, nn)
+	objs, err := parser.Coalesce(nn, parsedEvents)
 	if err != nil {
 		return fmt.Errorf("parsing events, %w", err)
 	}
-	parsedEvents := object.ParseEvents(auditEvents)
-	if len(parsedEvents) == 0 {
+	if len(objs) == 0 {
 		fmt.Printf("No events found for: %s\n", nn)
 		return nil
 	}
-	fmt.Println(object.NewObjectParserFrom(cmd.Name()).Coalesce(nn, parsedEvents).Describe())
-	return nil
+	if len(objs) > 1 && uid == "" && incarnation == 0 {
+		fmt.Printf("Found %d incarnations of %s, select one with --uid or --incarnation\n\n", len(objs), nn)
+		fmt.Print(object.FormatIncarnations(objs))
+		return nil
+	}
+	obj, err := object.SelectIncarnation(objs, types.UID(uid), incarnation)
+	if err != nil {
+		return err
+	}
+	// The structured formats render the data that the description is made from
+	return out.PrintObject(os.Stdout, printer.Item{Resource: printer.ResourceOf(parser, obj.Snapshot()), Name: nn.Name, Data: obj}, printer.View{
+		Text: func() string { return obj.Describe() + "\n" },
+	})
 }
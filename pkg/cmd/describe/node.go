@@ -0,0 +1,40 @@
+package describe
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var nodeCmd = &cobra.Command{
+	Use:   "node <node-name>",
+	Short: "Get audit log events for a node",
+	Long: `Get audit log events for a specific node from Kubernetes audit logs.
+
+This command analyzes audit logs to extract the node lifecycle including:
+  - Node creation and deletion
+  - Ready and NotReady transitions
+  - Taint and cordon changes
+  - Pods that were bound to the node, with their bind and delete times
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Analyze node from local audit log
+  kubereplay describe node i-123456789 -f /var/log/audit.log
+
+  # Analyze node from CloudWatch (requires AWS credentials)
+  kubereplay describe node i-123456789 -g /aws/eks/prod-cluster/audit -r us-west-2
+
+Pods are shown as deleted by the first delete of a pod with their namespace and name after they were
+bound to the node, unless the delete logged another pod UID.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDescribeCmd(cmd, cmd.Name(), args[0])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(nodeCmd)
+	addDescribeFlags(nodeCmd)
+}
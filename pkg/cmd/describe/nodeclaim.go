@@ -0,0 +1,39 @@
+package describe
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var nodeClaimCmd = &cobra.Command{
+	Use:   "nodeclaim <nodeclaim-name>",
+	Short: "Get audit log events for a Karpenter NodeClaim",
+	Long: `Get audit log events for a specific Karpenter NodeClaim from Kubernetes audit logs.
+
+This command analyzes audit logs to extract the NodeClaim lifecycle including:
+  - NodeClaim creation and deletion, including deletion on expiry
+  - Launch, registration and initialization of its node
+  - Drift and disruption, with the disruption reason
+  - The node that it registered as, and the pods that Karpenter launched it for
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Analyze NodeClaim from local audit log
+  kubereplay describe nodeclaim default-x8zvc -f /var/log/audit.log
+
+  # Analyze NodeClaim from CloudWatch (requires AWS credentials)
+  kubereplay describe nodeclaim default-x8zvc -g /aws/eks/prod-cluster/audit -r us-west-2
+
+Follow a pod to its capacity with describe pod, which shows the NodeClaims that the pod was nominated to.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDescribeCmd(cmd, cmd.Name(), args[0])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(nodeClaimCmd)
+	addDescribeFlags(nodeClaimCmd)
+}
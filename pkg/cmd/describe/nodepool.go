@@ -0,0 +1,37 @@
+package describe
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var nodePoolCmd = &cobra.Command{
+	Use:   "nodepool <nodepool-name>",
+	Short: "Get audit log events for a Karpenter NodePool",
+	Long: `Get audit log events for a specific Karpenter NodePool from Kubernetes audit logs.
+
+This command analyzes audit logs to extract the NodePool lifecycle including:
+  - NodePool creation and deletion
+  - Changes to its spec
+  - Ready and NotReady transitions
+  - NodeClaims that were launched from it, with their create and delete times
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Analyze NodePool from local audit log
+  kubereplay describe nodepool default -f /var/log/audit.log
+
+  # Analyze NodePool from CloudWatch (requires AWS credentials)
+  kubereplay describe nodepool default -g /aws/eks/prod-cluster/audit -r us-west-2`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDescribeCmd(cmd, cmd.Name(), args[0])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(nodePoolCmd)
+	addDescribeFlags(nodePoolCmd)
+}
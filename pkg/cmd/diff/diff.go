@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "diff <resource>[.<group>] <name>",
+	Short: "Show how a Kubernetes resource changed between two points in time",
+	Long: `Show how a Kubernetes resource changed between two points in time, reconstructed from audit log
+events from local files, CloudWatch Logs or Grafana Loki.
+
+Any resource is diffed by its plural or kind name and API group, like pod, node, deployment,
+nodeclaim or ingresses.networking.k8s.io. The namespace defaults to default and is ignored for
+nodes, nodeclaims, nodepools and built-in cluster-scoped resources like
+clusterroles.rbac.authorization.k8s.io. Pass --namespace "" for cluster-scoped custom resources.
+
+Additional Flags:
+  --from         RFC3339 time of the state to diff from
+  --to           RFC3339 time of the state to diff to, defaults to the end of the query window
+  --revisions    Diff every pair of consecutive revisions in the query window instead
+  --field-paths  Only list the field paths that changed instead of a unified diff
+  --max-lookback Maximum lookback from --from and --to when searching for the state of the resource
+  --start        Duration value from the current time to start querying the audit logs, measured
+                 back from --from and --to when they're set
+  --end          Duration value from the current time to finish querying the audit logs
+
+Data sources:
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
+  --log-group    AWS CloudWatch log group name
+  --region       AWS region for CloudWatch log group
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
+
+Examples:
+  # Diff a node between two times
+  kubereplay diff node i-0123456789 -g /aws/eks/my-cluster/audit --from 2025-09-15T15:00:00Z --to 2025-09-15T16:00:00Z
+
+  # List the fields that changed on a node since a time
+  kubereplay diff node i-0123456789 -g /aws/eks/my-cluster/audit --from 2025-09-15T15:00:00Z --field-paths
+
+  # Diff every consecutive revision of a pod
+  kubereplay diff pod my-pod -n default -f /var/log/audit.log --revisions
+
+  # Diff a custom resource by its plural name and API group
+  kubereplay diff nodeclaims.karpenter.sh my-nodeclaim -f /var/log/audit.log --revisions`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		namespace, _ := cmd.Flags().GetString("namespace")
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		revisions, _ := cmd.Flags().GetBool("revisions")
+		fieldPaths, _ := cmd.Flags().GetBool("field-paths")
+		maxLookback, _ := cmd.Flags().GetDuration("max-lookback")
+
+		opts, err := options.FromFlags(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		var fromTime, toTime time.Time
+		if from != "" {
+			if fromTime, err = time.Parse(time.RFC3339, from); err != nil {
+				fmt.Printf("Error: Invalid --from time, %v\n", err)
+				return
+			}
+		}
+		if to != "" {
+			if toTime, err = time.Parse(time.RFC3339, to); err != nil {
+				fmt.Printf("Error: Invalid --to time, %v\n", err)
+				return
+			}
+		}
+
+		parser := object.NewObjectParserFrom(args[0])
+		if err := RunDiff(ctx, parser, opts, object.NamespacedName(parser, namespace, args[1]), fromTime, toTime, revisions, fieldPaths, maxLookback); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	Cmd.Flags().StringP("namespace", "n", "default", "Namespace of the object, ignored for known cluster-scoped resources")
+	options.AddFlags(Cmd)
+	Cmd.Flags().StringP("from", "", "", "Time of the state to diff from in RFC3339 format")
+	Cmd.Flags().StringP("to", "", "", "Time of the state to diff to in RFC3339 format, defaults to the end of the query window")
+	Cmd.Flags().BoolP("revisions", "", false, "Diff every pair of consecutive revisions in the query window")
+	Cmd.Flags().BoolP("field-paths", "", false, "Only list the field paths that changed")
+	Cmd.Flags().DurationP("max-lookback", "", time.Hour*24*7, "Maximum lookback from --from and --to when no state is found inside --start")
+	Cmd.MarkFlagsOneRequired("from", "revisions")
+	Cmd.MarkFlagsMutuallyExclusive("from", "revisions")
+	Cmd.MarkFlagsMutuallyExclusive("to", "revisions")
+}
+
+func RunDiff(ctx context.Context, parser object.ObjectParser, opts options.Options, nn types.NamespacedName, from, to time.Time, revisions, fieldPaths bool, maxLookback time.Duration) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	if revisions {
+		return runDiffRevisions(parser, nn, opts.Events(ctx, auditProvider, parser, "get", startTime, endTime, nn), fieldPaths)
+	}
+
+	if to.IsZero() {
+		to = endTime
+	}
+	stateAt := func(t time.Time) (client.Object, error) {
+		// Like get --at, the lookback is measured back from t and the window carries on past it
+		events := func(startTime time.Time) iter.Seq2[object.ParsedEvent, error] {
+			return opts.Events(ctx, auditProvider, parser, "get", startTime, lo.Latest(endTime, t), nn)
+		}
+		state, _, err := object.AtWithLookback(parser, nn, events, t, t.Add(-opts.Start), maxLookback, os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing events, %w", err)
+		}
+		if state.Object == nil {
+			return nil, nil
+		}
+		return state.Object.Snapshot(), nil
+	}
+	a, err := stateAt(from)
+	if err != nil {
+		return err
+	}
+	b, err := stateAt(to)
+	if err != nil {
+		return err
+	}
+	if a == nil && b == nil {
+		fmt.Printf("No state found for %s at %s or %s\n", nn, formatTime(from), formatTime(to))
+		return nil
+	}
+	printDiff(a, b, fmt.Sprintf("%s at %s", nn, formatTime(from)), fmt.Sprintf("%s at %s", nn, formatTime(to)), fieldPaths)
+	return nil
+}
+
+// runDiffRevisions diffs each revision of the object against the revision before it
+func runDiffRevisions(parser object.ObjectParser, nn types.NamespacedName, events iter.Seq2[object.ParsedEvent, error], fieldPaths bool) error {
+	revisions, err := object.History(nn, parser.ObjectType(), events)
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("No events found for: %s\n", nn)
+		return nil
+	}
+	for i := 1; i < len(revisions); i++ {
+		prev, cur := revisions[i-1], revisions[i]
+		fmt.Printf("Revision %d -> %d at %s by %s (%s)\n", i, i+1, formatTime(cur.Timestamp), cur.User,
+			strings.Join(lo.Compact([]string{cur.Verb, cur.Subresource}), " "))
+		if prev.UID != cur.UID {
+			fmt.Printf("New incarnation %s\n\n", cur.UID)
+			continue
+		}
+		printDiff(prev.Object, cur.Object, fmt.Sprintf("revision %d", i), fmt.Sprintf("revision %d", i+1), fieldPaths)
+		fmt.Println()
+	}
+	return nil
+}
+
+func printDiff(a, b client.Object, fromName, toName string, fieldPaths bool) {
+	if fieldPaths {
+		paths := object.ChangedPaths(a, b, 0)
+		if len(paths) == 0 {
+			fmt.Println("No changes")
+		}
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+		return
+	}
+	diff := object.UnifiedDiff(a, b, fromName, toName)
+	if diff == "" {
+		fmt.Println("No changes")
+	}
+	fmt.Print(diff)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// podChange is a create or update of the pod default/web that sets its app label
+func podChange(verb string, at time.Time, app string) test.Event {
+	return test.Event{
+		Verb: verb, Resource: "pods", Namespace: "default", Name: "web", Time: at,
+		ResponseObject: fmt.Sprintf(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"default","uid":"uid-1","labels":{"app":%q}}}`, app),
+	}
+}
+
+func TestRunDiffFromWithoutLookback(t *testing.T) {
+	from := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, tc := range []struct {
+		name    string
+		changes []test.Event
+		want    []string
+	}{
+		{
+			name:    "changed after --from",
+			changes: []test.Event{podChange("create", from.Add(-10*time.Minute), "a"), podChange("update", from.Add(10*time.Minute), "b")},
+			want:    []string{"-    app: a", "+    app: b"},
+		},
+		{
+			name:    "created after --from",
+			changes: []test.Event{podChange("create", from.Add(10*time.Minute), "a")},
+			want:    []string{"+    app: a"},
+		},
+		{
+			name:    "changed before --max-lookback",
+			changes: []test.Event{podChange("create", from.Add(-2*time.Hour), "a")},
+			want:    []string{"No state found"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Options{AuditLogPaths: []string{test.WriteAuditLog(t, tc.changes...)}, LogFormat: "kubernetes", Start: 0}
+			output := test.CaptureOutput(t, func() error {
+				return RunDiff(context.Background(), object.NewObjectParserFrom("pod"), opts, types.NamespacedName{Namespace: "default", Name: "web"}, from, time.Time{}, false, false, time.Hour)
+			})
+			for _, want := range tc.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffCmdTakesAnyResource(t *testing.T) {
+	from := time.Now().Add(-time.Hour).Truncate(time.Second)
+	path := test.WriteAuditLog(t, podChange("create", from.Add(-10*time.Minute), "a"), podChange("update", from.Add(10*time.Minute), "b"))
+	for _, resource := range []string{"pod", "pods"} {
+		t.Run(resource, func(t *testing.T) {
+			Cmd.SetArgs([]string{resource, "web", "-n", "default", "-f", path, "--from", from.Format(time.RFC3339), "--field-paths"})
+			output := test.CaptureOutput(t, Cmd.Execute)
+			if !strings.Contains(output, "metadata.labels.app") {
+				t.Errorf("output doesn't contain the changed label:\n%s", output)
+			}
+		})
+	}
+}
@@ -3,33 +3,72 @@ package get
 import (
 	"context"
 	"fmt"
+	"iter"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/joinnis/kubereplay/pkg/audit/provider"
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
 	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/printer"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
 )
 
 var Cmd = &cobra.Command{
-	Use:   "get",
+	Use:   "get <resource>[.<group>] <name>",
 	Short: "Get Kubernetes resources from audit log events",
-	Long: `Get Kubernetes resources from audit log events from local files or CloudWatch Logs.
+	Long: `Get Kubernetes resources from audit log events from local files, CloudWatch Logs or Grafana Loki.
 
 Supported resources:
-  pod    Get a specific pod
-  node   Get a specific node
+  pod         Get a specific pod, or list pods when no name is given
+  node        Get a specific node
+  nodeclaim   Get a specific Karpenter NodeClaim
+  nodepool    Get a specific Karpenter NodePool
+  deployment, replicaset, statefulset, daemonset, job, cronjob
+              Get a specific workload
+
+Any other resource, including custom resources, is reconstructed from its audit events when it's
+given by its plural name and API group, like configmaps or ingresses.networking.k8s.io. The
+namespace defaults to default and is ignored for nodes, nodeclaims, nodepools and built-in
+cluster-scoped resources like clusterroles.rbac.authorization.k8s.io. Pass --namespace "" for
+cluster-scoped custom resources.
 
 Additional Flags:
-  --at           Exact time in RFC3339 time to get state for the resource
+  --at           Exact time in RFC3339 time to get state for the resource. The --start lookback is
+                 measured back from this time, and is extended up to --max-lookback when no state
+                 is found inside it
+  --max-lookback Maximum lookback from --at when searching for the state of the resource
+  --revision     Revision of the resource to get, as numbered by the history command
   --start        Duration value from the current time to start querying the audit logs
   --end          Duration value from the current time to finish querying the audit logs
 
 Data sources:
-  --audit-log    Local audit log file path
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
   --log-group    AWS CloudWatch log group name
   --region       AWS region for CloudWatch log group
   --account      AWS account ID for cross-account access
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Incarnations:
+  --uid          UID of the incarnation to show when an object has been re-created with the same name
+  --incarnation  1-based index of the incarnation to show, in order of creation
+
+Output:
+  -o, --output   yaml (default), json, wide, name, jsonpath=<template>, go-template=<template> or
+                 custom-columns=<header>:<jsonpath>[,...]. Lists of pods are rendered as a List.
+  --no-headers   Leave the header row out of the pod table and the wide and custom-columns tables
+  --show-managed-fields  Keep the managedFields of objects in the structured formats
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
 
 Examples:
   # Get pod from local file
@@ -39,33 +78,188 @@ Examples:
   kubereplay get pod my-pod -n default -g /aws/eks/my-cluster/audit -r us-west-2
   
   # Get node from Cloudwatch at time 2025-09-15T15:56:21
-  kubereplay get node i-0123456789 -g /aws/eks/my-cluster/audit --at 2025-09-15T15:56:21`,
+  kubereplay get node i-0123456789 -g /aws/eks/my-cluster/audit --at 2025-09-15T15:56:21
+
+  # Get any other resource by its plural name and API group
+  kubereplay get ingresses.networking.k8s.io my-ingress -n default -f /var/log/audit.log
+  kubereplay get clusterroles.rbac.authorization.k8s.io my-role -f /var/log/audit.log`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runGetCmd(cmd, args[0], args[1])
+	},
 }
 
-func RunGet(ctx context.Context, cmd *cobra.Command, startTime, endTime time.Time, nn types.NamespacedName, auditLogPath, logGroup, region string) error {
-	var err error
-	var auditProvider provider.Provider
-	if logGroup != "" {
-		auditProvider, err = provider.NewCloudWatch(logGroup, region)
-		if err != nil {
-			return fmt.Errorf("initializing cloudwatch provider, %w", err)
-		}
+func init() {
+	Cmd.Flags().StringP("namespace", "n", "default", "Namespace of the object, ignored for known cluster-scoped resources")
+	printer.AddFlags(Cmd)
+	addGetFlags(Cmd)
+	for _, kind := range object.WorkloadTypes() {
+		Cmd.AddCommand(workloadCmd(kind))
+	}
+}
+
+// workloadCmd returns the subcommand that gets a workload of the given kind
+func workloadCmd(kind object.ObjectType) *cobra.Command {
+	resource := strings.ToLower(string(kind))
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s <%s-name>", resource, resource),
+		Short: fmt.Sprintf("Get the state of a %s", kind),
+		Long: fmt.Sprintf(`Get the state of a specific %[1]s from Kubernetes audit logs.
 
-	} else {
-		auditProvider, err = provider.NewFile(auditLogPath)
-		if err != nil {
-			return fmt.Errorf("initializing file provider, %w", err)
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Get a %[1]s from local audit log
+  kubereplay get %[2]s my-%[2]s -n default -f /var/log/audit.log
+
+  # Get a %[1]s from CloudWatch (requires AWS credentials)
+  kubereplay get %[2]s my-%[2]s -n default -g /aws/eks/prod-cluster/audit -r us-west-2`, kind, resource),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runGetCmd(cmd, resource, args[0])
+		},
+	}
+	cmd.Flags().StringP("namespace", "n", "default", fmt.Sprintf("Namespace of the %s", kind))
+	addGetFlags(cmd)
+	return cmd
+}
+
+// addGetFlags registers the flags that every command getting a single object takes, other than its namespace
+func addGetFlags(cmd *cobra.Command) {
+	options.AddFlags(cmd)
+	cmd.Flags().StringP("uid", "", "", "UID of the incarnation to show when the object has been re-created with the same name")
+	cmd.Flags().IntP("incarnation", "", 0, "1-based index of the incarnation to show, in order of creation")
+	cmd.Flags().IntP("revision", "", 0, "Revision to get, as numbered by the history command")
+	cmd.Flags().StringP("at", "", "", "Time to query the object state, the --start lookback is measured back from it")
+	cmd.Flags().DurationP("max-lookback", "", time.Hour*24*7, "Maximum lookback from --at when no state is found inside --start")
+	cmd.MarkFlagsMutuallyExclusive("at", "uid", "revision")
+	cmd.MarkFlagsMutuallyExclusive("at", "incarnation", "revision")
+}
+
+// runGetCmd gets the named object of a resource with the flags registered by addGetFlags. Commands
+// of cluster-scoped resources don't register --namespace, which leaves it empty.
+func runGetCmd(cmd *cobra.Command, resource, name string) {
+	ctx := context.Background()
+	namespace, _ := cmd.Flags().GetString("namespace")
+	uid, _ := cmd.Flags().GetString("uid")
+	incarnation, _ := cmd.Flags().GetInt("incarnation")
+	revision, _ := cmd.Flags().GetInt("revision")
+	at, _ := cmd.Flags().GetString("at")
+	maxLookback, _ := cmd.Flags().GetDuration("max-lookback")
+
+	opts, err := options.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	out, err := printer.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	var atTime time.Time
+	if at != "" {
+		if atTime, err = time.Parse(time.RFC3339, at); err != nil {
+			fmt.Printf("Error: Invalid --at time, %v\n", err)
+			return
 		}
 	}
-	auditEvents, err := auditProvider.GetEvents(ctx, object.NewObjectParserFrom(cmd.Name()), cmd.Parent().Name(), startTime, endTime, nn)
+
+	parser := object.NewObjectParserFrom(resource)
+	if err := RunGet(ctx, parser, opts, out, object.NamespacedName(parser, namespace, name), uid, incarnation, revision, atTime, maxLookback); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func RunGet(ctx context.Context, parser object.ObjectParser, opts options.Options, out printer.Printer, nn types.NamespacedName, uid string, incarnation, revision int, at time.Time, maxLookback time.Duration) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	if !at.IsZero() {
+		// The lookback is measured back from --at, and the window carries on past it so that it's
+		// possible to tell whether the object was created after it
+		startTime = at.Add(-opts.Start)
+		endTime = lo.Latest(endTime, at)
+	}
+	events := func(startTime time.Time) iter.Seq2[object.ParsedEvent, error] {
+		return opts.Events(ctx, auditProvider, parser, "get", startTime, endTime, nn)
+	}
+	switch {
+	case !at.IsZero():
+		return runGetAt(parser, out, nn, events, startTime, at, maxLookback)
+	case revision != 0:
+		return runGetRevision(parser, out, nn, events(startTime), revision)
+	}
+
+	objs, err := parser.Coalesce(nn, events(startTime))
 	if err != nil {
 		return fmt.Errorf("parsing events, %w", err)
 	}
-	parsedEvents := object.ParseEvents(auditEvents)
-	if len(parsedEvents) == 0 {
+	if len(objs) == 0 {
 		fmt.Printf("No events found for: %s\n", nn)
 		return nil
 	}
-	fmt.Println(object.NewObjectParserFrom(cmd.Name()).Coalesce(nn, parsedEvents).Get())
+	if len(objs) > 1 && uid == "" && incarnation == 0 {
+		fmt.Printf("Found %d incarnations of %s, select one with --uid or --incarnation\n\n", len(objs), nn)
+		fmt.Print(object.FormatIncarnations(objs))
+		return nil
+	}
+	obj, err := object.SelectIncarnation(objs, types.UID(uid), incarnation)
+	if err != nil {
+		return err
+	}
+	return printObject(parser, out, nn, obj)
+}
+
+// printObject prints the latest state of an object, which is rendered as YAML by default
+func printObject(parser object.ObjectParser, out printer.Printer, nn types.NamespacedName, obj object.Object) error {
+	return out.PrintObject(os.Stdout, printer.Item{Resource: printer.ResourceOf(parser, obj.Snapshot()), Name: nn.Name, Data: obj.Snapshot()}, printer.View{
+		Text: func() string { return obj.Get() + "\n" },
+		Wide: func() string { return object.FormatWide(nn, obj) },
+	})
+}
+
+// runGetRevision prints a single revision from the object's history, as numbered by the history command
+func runGetRevision(parser object.ObjectParser, out printer.Printer, nn types.NamespacedName, events iter.Seq2[object.ParsedEvent, error], revision int) error {
+	revisions, err := object.History(nn, parser.ObjectType(), events)
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("No events found for: %s\n", nn)
+		return nil
+	}
+	if revision < 1 || revision > len(revisions) {
+		return fmt.Errorf("revision %d is out of range, found %d revision(s)", revision, len(revisions))
+	}
+	snapshot := revisions[revision-1].Object
+	return out.PrintObject(os.Stdout, printer.Item{Resource: printer.ResourceOf(parser, snapshot), Name: nn.Name, Data: snapshot}, printer.View{
+		Text: func() string { return string(lo.Must(yaml.Marshal(object.WithoutManagedFields(snapshot)))) + "\n" },
+	})
+}
+
+// runGetAt prints the object as it existed at the given time
+func runGetAt(parser object.ObjectParser, out printer.Printer, nn types.NamespacedName, events func(time.Time) iter.Seq2[object.ParsedEvent, error], startTime, at time.Time, maxLookback time.Duration) error {
+	state, startTime, err := object.AtWithLookback(parser, nn, events, at, startTime, maxLookback, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	switch {
+	case state.Object != nil && state.Object.Snapshot() != nil:
+		return printObject(parser, out, nn, state.Object)
+	case !state.DeletionTime.IsZero():
+		fmt.Printf("%s was deleted at %s\n", nn, state.DeletionTime.UTC().Format(time.RFC3339))
+	case !state.CreationTime.IsZero():
+		fmt.Printf("%s did not exist yet at %s, it was created at %s\n", nn, at.UTC().Format(time.RFC3339), state.CreationTime.UTC().Format(time.RFC3339))
+	case state.Object != nil:
+		fmt.Printf("No snapshot found for %s between %s and %s\n", nn, startTime.UTC().Format(time.RFC3339), at.UTC().Format(time.RFC3339))
+	default:
+		fmt.Printf("No events found for: %s\n", nn)
+	}
 	return nil
 }
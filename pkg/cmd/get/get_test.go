@@ -0,0 +1,45 @@
+package get
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/printer"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRunGetAtWithoutLookback(t *testing.T) {
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, tc := range []struct {
+		name    string
+		created time.Time
+		want    string
+	}{
+		{name: "created before --at", created: at.Add(-10 * time.Minute), want: "uid: uid-1"},
+		{name: "created after --at", created: at.Add(10 * time.Minute), want: "did not exist yet"},
+		{name: "created before --max-lookback", created: at.Add(-2 * time.Hour), want: "No events found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := test.WriteAuditLog(t, test.Event{
+				Verb: "create", Resource: "pods", Namespace: "default", Name: "web", Time: tc.created,
+				ResponseObject: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"default","uid":"uid-1"}}`,
+			})
+			opts := options.Options{AuditLogPaths: []string{path}, LogFormat: "kubernetes", Start: 0}
+			out, err := printer.New("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := test.CaptureOutput(t, func() error {
+				return RunGet(context.Background(), object.NewObjectParserFrom("pod"), opts, out, types.NamespacedName{Namespace: "default", Name: "web"}, "", 0, 0, at, time.Hour)
+			})
+			if !strings.Contains(output, tc.want) {
+				t.Errorf("output doesn't contain %q:\n%s", tc.want, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package get
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/printer"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RunListPods lists the pods in a namespace, or across every namespace when it's empty, that had
+// events in the time window and match the filter
+func RunListPods(ctx context.Context, opts options.Options, out printer.Printer, namespace string, filter object.PodFilter) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	parsedEvents := opts.Events(ctx, auditProvider, object.PodParser{}, "list", startTime, endTime, types.NamespacedName{Namespace: namespace})
+	pods, err := object.ListPods(parsedEvents, filter)
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	if len(pods) == 0 {
+		fmt.Println("No pods found")
+		return nil
+	}
+	items := lo.Map(pods, func(p object.Pod, _ int) printer.Item {
+		return printer.Item{Resource: "pod", Name: p.NamespaceName.Name, Data: p.Pod}
+	})
+	return out.PrintList(os.Stdout, items, printer.View{
+		Text:  func() string { return object.FormatPods(pods, namespace == "", false) },
+		Wide:  func() string { return object.FormatPods(pods, namespace == "", true) },
+		Table: true,
+	})
+}
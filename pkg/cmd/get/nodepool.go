@@ -0,0 +1,33 @@
+package get
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var nodePoolCmd = &cobra.Command{
+	Use:   "nodepool <nodepool-name>",
+	Short: "Get audit log events for a Karpenter NodePool",
+	Long: `Get audit log events for a specific Karpenter NodePool from Kubernetes audit logs.
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Get NodePool from local audit log
+  kubereplay get nodepool default -f /var/log/audit.log
+
+  # Get NodePool from CloudWatch (requires AWS credentials)
+  kubereplay get nodepool default -g /aws/eks/prod-cluster/audit -r us-west-2
+
+Output includes timestamps, event types, descriptions, and node information where applicable.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runGetCmd(cmd, cmd.Name(), args[0])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(nodePoolCmd)
+	addGetFlags(nodePoolCmd)
+}
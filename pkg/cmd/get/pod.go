@@ -3,20 +3,34 @@ package get
 import (
 	"context"
 	"fmt"
-	"time"
 
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/printer"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/types"
 )
 
 var podCmd = &cobra.Command{
-	Use:   "pod <pod-name>",
-	Short: "Get audit log events for a pod",
+	Use:     "pod [pod-name]",
+	Aliases: []string{"pods"},
+	Short:   "Get audit log events for a pod, or list pods",
 	Long: `Get audit log events for a specific pod from Kubernetes audit logs.
 
+Without a pod name, every pod with events in the time window is listed in a table of its node and
+when it was created, bound and deleted, along with the last phase it was logged in. Each
+incarnation of a re-created pod gets its own row.
+
+List Flags:
+  -A, --all-namespaces   List pods across every namespace instead of --namespace
+  -l, --selector         Label selector, matched against the latest logged state of each pod
+  --field-selector       Field selector on metadata.name, metadata.namespace, spec.nodeName or
+                         status.phase. spec.nodeName also matches the node a pod was bound to.
+  --evicted              Only list pods that were evicted
+  --deleted              Only list pods that were deleted
+
 Data Sources:
-  Use either --audit-log for local files or --log-group for AWS CloudWatch Logs.
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
   Exactly one must be specified.
 
 Examples:
@@ -26,47 +40,74 @@ Examples:
   # Get pod from CloudWatch (requires AWS credentials)
   kubereplay get pod nginx-pod -n kube-system -g /aws/eks/prod-cluster/audit -r us-west-2
 
+  # List every pod that was on a node
+  kubereplay get pods -A --field-selector spec.nodeName=i-123456789 -f /var/log/audit.log
+
+  # List the pods in a namespace that were evicted in the last 6 hours
+  kubereplay get pods -n web --evicted --start 6h -f /var/log/audit.log
+
 Output includes timestamps, event types, descriptions, and node information where applicable.`,
-	Args: cobra.ExactArgs(1),
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
-		name := args[0]
-		namespace, _ := cmd.Flags().GetString("namespace")
-		auditLogPath, _ := cmd.Flags().GetString("audit-log")
-		logGroup, _ := cmd.Flags().GetString("log-group")
-		region, _ := cmd.Flags().GetString("region")
-		start, _ := cmd.Flags().GetDuration("start")
-		end, _ := cmd.Flags().GetDuration("end")
-		at, _ := cmd.Flags().GetString("at")
-
-		if auditLogPath == "" && logGroup == "" {
-			fmt.Println("Error: Either --audit-log or --log-group must be specified")
+		if len(args) == 0 {
+			runListPods(ctx, cmd)
 			return
 		}
-
-		if auditLogPath != "" && logGroup != "" {
-			fmt.Println("Error: Cannot specify both --audit-log and --log-group")
+		if changed := lo.Filter(listFlags, func(f string, _ int) bool { return cmd.Flags().Changed(f) }); len(changed) > 0 {
+			fmt.Printf("Error: --%s only applies when listing pods\n", changed[0])
 			return
 		}
-		startTime := time.Now().Add(-start)
-		endTime := time.Now().Add(-end)
-		if at != "" {
-			endTime = lo.Must(time.Parse(time.RFC3339, at))
-		}
-
-		if err := RunGet(ctx, cmd, startTime, endTime, types.NamespacedName{Namespace: namespace, Name: name}, auditLogPath, logGroup, region); err != nil {
-			fmt.Printf("Error: %v\n", err)
-		}
+		runGetCmd(cmd, cmd.Name(), args[0])
 	},
 }
 
 func init() {
 	Cmd.AddCommand(podCmd)
 	podCmd.Flags().StringP("namespace", "n", "default", "Namespace of the pod")
-	podCmd.Flags().StringP("audit-log", "f", "", "Path to audit log file")
-	podCmd.Flags().StringP("log-group", "g", "", "AWS CloudWatch log group name")
-	podCmd.Flags().StringP("region", "r", "", "AWS region for CloudWatch log group")
-	podCmd.Flags().DurationP("start", "", time.Hour*24, "Start time for log parsing in time.Duration string format")
-	podCmd.Flags().DurationP("end", "", 0, "End time for log parsing in time.Duration string format")
-	podCmd.Flags().StringP("at", "", "", "Time to query the object state")
+	addGetFlags(podCmd)
+	podCmd.Flags().BoolP("all-namespaces", "A", false, "List pods across every namespace")
+	podCmd.Flags().StringP("selector", "l", "", "Label selector to list pods by")
+	podCmd.Flags().StringP("field-selector", "", "", "Field selector to list pods by")
+	podCmd.Flags().BoolP("evicted", "", false, "Only list pods that were evicted")
+	podCmd.Flags().BoolP("deleted", "", false, "Only list pods that were deleted")
+}
+
+// listFlags only apply when listing pods, and objectFlags only apply to a named pod
+var (
+	listFlags   = []string{"all-namespaces", "selector", "field-selector", "evicted", "deleted"}
+	objectFlags = []string{"uid", "incarnation", "revision", "at"}
+)
+
+func runListPods(ctx context.Context, cmd *cobra.Command) {
+	if changed := lo.Filter(objectFlags, func(f string, _ int) bool { return cmd.Flags().Changed(f) }); len(changed) > 0 {
+		fmt.Printf("Error: --%s needs a pod name\n", changed[0])
+		return
+	}
+	namespace, _ := cmd.Flags().GetString("namespace")
+	allNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
+	selector, _ := cmd.Flags().GetString("selector")
+	fieldSelector, _ := cmd.Flags().GetString("field-selector")
+	evicted, _ := cmd.Flags().GetBool("evicted")
+	deleted, _ := cmd.Flags().GetBool("deleted")
+
+	opts, err := options.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	out, err := printer.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	filter, err := object.NewPodFilter(selector, fieldSelector, evicted, deleted)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if err := RunListPods(ctx, opts, out, lo.Ternary(allNamespaces, "", namespace), filter); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
 }
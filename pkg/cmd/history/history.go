@@ -0,0 +1,109 @@
+package history
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "history <resource>[.<group>] <name>",
+	Short: "List every recorded revision of a Kubernetes resource from audit log events",
+	Long: `List every revision of a Kubernetes resource that can be reconstructed from audit log events
+from local files, CloudWatch Logs or Grafana Loki.
+
+Each revision shows when it was logged, the verb and subresource of the request that produced it,
+the user that made the request, the resulting resourceVersion and a summary of the fields that
+changed since the previous revision. Use "kubereplay get <resource> <name> --revision N" to get the
+full state of a single revision.
+
+Supported resources:
+  pod    List the revisions of a specific pod
+  node   List the revisions of a specific node
+
+Any other resource, including workloads, NodeClaims, NodePools and custom resources, is given by
+its plural or kind name and API group, like deployment, configmaps or ingresses.networking.k8s.io.
+The namespace defaults to default and is ignored for nodes, nodeclaims, nodepools and built-in
+cluster-scoped resources like clusterroles.rbac.authorization.k8s.io. Pass --namespace "" for
+cluster-scoped custom resources.
+
+Additional Flags:
+  --start        Duration value from the current time to start querying the audit logs
+  --end          Duration value from the current time to finish querying the audit logs
+
+Data sources:
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
+  --log-group    AWS CloudWatch log group name
+  --region       AWS region for CloudWatch log group
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
+
+Examples:
+  # List the revisions of a pod from a local file
+  kubereplay history pod my-pod -n kube-system -f /var/log/audit.log
+
+  # Get the third revision of the pod
+  kubereplay get pod my-pod -n kube-system -f /var/log/audit.log --revision 3
+
+  # List the revisions of any other resource by its plural name and API group
+  kubereplay history deployment my-deployment -n default -f /var/log/audit.log
+  kubereplay history ingresses.networking.k8s.io my-ingress -n default -f /var/log/audit.log`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runHistoryCmd(cmd, args[0], args[1])
+	},
+}
+
+func init() {
+	Cmd.Flags().StringP("namespace", "n", "default", "Namespace of the object, ignored for known cluster-scoped resources")
+	options.AddFlags(Cmd)
+}
+
+// runHistoryCmd lists the revisions of the named object of a resource with the flags registered by
+// options.AddFlags
+func runHistoryCmd(cmd *cobra.Command, resource, name string) {
+	ctx := context.Background()
+	namespace, _ := cmd.Flags().GetString("namespace")
+
+	opts, err := options.FromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	parser := object.NewObjectParserFrom(resource)
+	if err := RunHistory(ctx, parser, opts, object.NamespacedName(parser, namespace, name)); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+func RunHistory(ctx context.Context, parser object.ObjectParser, opts options.Options, nn types.NamespacedName) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	// Revisions are the same snapshots that get reconstructs the object from
+	revisions, err := object.History(nn, parser.ObjectType(), opts.Events(ctx, auditProvider, parser, "get", startTime, endTime, nn))
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("No events found for: %s\n", nn)
+		return nil
+	}
+	fmt.Print(object.FormatHistory(revisions))
+	return nil
+}
@@ -0,0 +1,39 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRunHistoryOfAnyResource(t *testing.T) {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var events []test.Event
+	for i, value := range []string{"a", "b", "c"} {
+		events = append(events, test.Event{
+			Verb: lo.Ternary(i == 0, "create", "update"), Resource: "configmaps", Namespace: "default", Name: "settings", Time: start.Add(time.Duration(i) * time.Minute),
+			ResponseObject: fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"default","uid":"uid-1","resourceVersion":"%d"},"data":{"value":%q}}`, i+1, value),
+		})
+	}
+	opts := options.Options{AuditLogPaths: []string{test.WriteAuditLog(t, events...)}, LogFormat: "kubernetes", Start: 2 * time.Hour}
+	output := test.CaptureOutput(t, func() error {
+		return RunHistory(context.Background(), object.NewObjectParserFrom("configmaps"), opts, types.NamespacedName{Namespace: "default", Name: "settings"})
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want a header and 3 revisions:\n%s", len(lines), output)
+	}
+	for i, want := range []string{"created", "data.value", "data.value"} {
+		if fields := strings.Fields(lines[i+1]); fields[0] != fmt.Sprint(i+1) || fields[len(fields)-1] != want {
+			t.Errorf("revision %d is %q, want changes %q", i+1, lines[i+1], want)
+		}
+	}
+}
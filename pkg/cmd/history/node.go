@@ -0,0 +1,32 @@
+package history
+
+import (
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/spf13/cobra"
+)
+
+var nodeCmd = &cobra.Command{
+	Use:   "node <node-name>",
+	Short: "List the revisions of a node",
+	Long: `List every revision of a specific node that's recorded in Kubernetes audit logs.
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # List node revisions from local audit log
+  kubereplay history node i-123456789 -f /var/log/audit.log
+
+  # List node revisions from CloudWatch (requires AWS credentials)
+  kubereplay history node i-123456789 -g /aws/eks/prod-cluster/audit -r us-west-2`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runHistoryCmd(cmd, cmd.Name(), args[0])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(nodeCmd)
+	options.AddFlags(nodeCmd)
+}
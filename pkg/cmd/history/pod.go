@@ -0,0 +1,33 @@
+package history
+
+import (
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/spf13/cobra"
+)
+
+var podCmd = &cobra.Command{
+	Use:   "pod <pod-name>",
+	Short: "List the revisions of a pod",
+	Long: `List every revision of a specific pod that's recorded in Kubernetes audit logs.
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # List pod revisions from local audit log
+  kubereplay history pod nginx-pod -n default -f /var/log/audit.log
+
+  # List pod revisions from CloudWatch (requires AWS credentials)
+  kubereplay history pod nginx-pod -n kube-system -g /aws/eks/prod-cluster/audit -r us-west-2`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runHistoryCmd(cmd, cmd.Name(), args[0])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(podCmd)
+	podCmd.Flags().StringP("namespace", "n", "default", "Namespace of the pod")
+	options.AddFlags(podCmd)
+}
@@ -0,0 +1,37 @@
+package latency
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "latency",
+	Short: "Measure how long Kubernetes resources took to get through their lifecycle from audit log events",
+	Long: `Measure how long Kubernetes resources took to get through their lifecycle from audit log events
+from local files, CloudWatch Logs or Grafana Loki.
+
+Supported resources:
+  pods   Measure how long pods took to be scheduled and to become ready
+
+Additional Flags:
+  --start        Duration value from the current time to start querying the audit logs
+  --end          Duration value from the current time to finish querying the audit logs
+
+Data sources:
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
+  --log-group    AWS CloudWatch log group name
+  --region       AWS region for CloudWatch log group
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
+
+Examples:
+  # Get the scheduling and startup latency percentiles of every pod, per node
+  kubereplay latency pods -A --group-by node -f /var/log/audit.log`,
+}
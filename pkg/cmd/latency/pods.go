@@ -0,0 +1,106 @@
+package latency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var podsCmd = &cobra.Command{
+	Use:     "pods",
+	Aliases: []string{"pod"},
+	Short:   "Measure how long pods took to be scheduled and to become ready",
+	Long: `Measure how long each pod with events in the time window took to be scheduled and to start.
+
+Three latencies are measured for each incarnation of a pod:
+  SCHEDULING   From the pod's creation to its binding to a node
+  STARTUP      From the binding to the first status update that has the pod's Ready condition true
+  END-TO-END   From the pod's creation to it first becoming ready
+
+A latency is left out when one of its events isn't in the time window, like for pods that were
+created before --start. The per-pod table is followed by the p50, p90 and p99 of each latency,
+grouped by the pods' namespace, node or controlling owner.
+
+Flags:
+  -A, --all-namespaces   Measure pods across every namespace instead of --namespace
+  -l, --selector         Label selector, matched against the latest logged state of each pod
+  --field-selector       Field selector on metadata.name, metadata.namespace, spec.nodeName or
+                         status.phase
+  --group-by             namespace, node or owner
+
+Data Sources:
+  Use either --audit-log for local files, --log-group for AWS CloudWatch Logs or --loki-url for Grafana Loki.
+  Exactly one must be specified.
+
+Examples:
+  # Measure the pods of an app from a local audit log
+  kubereplay latency pods -n default -l app=web -f /var/log/audit.log
+
+  # Compare the latencies of the pods owned by each ReplicaSet, Job and StatefulSet
+  kubereplay latency pods -A --group-by owner --start 6h -g /aws/eks/prod-cluster/audit -r us-west-2`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		namespace, _ := cmd.Flags().GetString("namespace")
+		allNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
+		selector, _ := cmd.Flags().GetString("selector")
+		fieldSelector, _ := cmd.Flags().GetString("field-selector")
+		groupBy, _ := cmd.Flags().GetString("group-by")
+
+		if !lo.Contains(object.LatencyGroups, groupBy) {
+			fmt.Printf("Error: Invalid --group-by %q, must be one of %s\n", groupBy, strings.Join(object.LatencyGroups, ", "))
+			return
+		}
+		opts, err := options.FromFlags(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		filter, err := object.NewPodFilter(selector, fieldSelector, false, false)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if err := RunPodLatency(ctx, opts, lo.Ternary(allNamespaces, "", namespace), filter, groupBy); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	Cmd.AddCommand(podsCmd)
+	podsCmd.Flags().StringP("namespace", "n", "default", "Namespace of the pods")
+	options.AddFlags(podsCmd)
+	podsCmd.Flags().BoolP("all-namespaces", "A", false, "Measure pods across every namespace")
+	podsCmd.Flags().StringP("selector", "l", "", "Label selector of the pods to measure")
+	podsCmd.Flags().StringP("field-selector", "", "", "Field selector of the pods to measure")
+	podsCmd.Flags().StringP("group-by", "", "namespace", fmt.Sprintf("What to group the latency percentiles by, one of (%s)", strings.Join(object.LatencyGroups, ", ")))
+}
+
+// RunPodLatency measures the pods in a namespace, or across every namespace when it's empty, that
+// had events in the time window and match the filter
+func RunPodLatency(ctx context.Context, opts options.Options, namespace string, filter object.PodFilter, groupBy string) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	pods, err := object.ListPods(opts.Events(ctx, auditProvider, object.PodParser{}, "list", startTime, endTime, types.NamespacedName{Namespace: namespace}), filter)
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	if len(pods) == 0 {
+		fmt.Println("No pods found")
+		return nil
+	}
+	fmt.Print(object.FormatLatencies(lo.Map(pods, func(p object.Pod, _ int) object.PodLatency { return object.NewPodLatency(p) }), namespace == "", groupBy))
+	return nil
+}
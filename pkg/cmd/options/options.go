@@ -0,0 +1,132 @@
+package options
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/audit/provider"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Options holds the flags shared by every command that reads audit events
+type Options struct {
+	AuditLogPaths []string
+	LogFormat     string
+	LogGroup      string
+	Region        string
+	QueryTimeout  time.Duration
+	LokiURL       string
+	LokiSelector  string
+	LokiOrgID     string
+	Start         time.Duration
+	End           time.Duration
+	Strict        bool
+}
+
+// LogFormats are the formats that --audit-log files can be written in
+var LogFormats = []string{"kubernetes", "gke", "aks"}
+
+// AddFlags registers the data source, time window and error handling flags on cmd
+func AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("audit-log", "f", nil, "Path to an audit log file, directory or glob, or - for stdin. Can be repeated, and gzip and zstd files are decompressed")
+	cmd.Flags().StringP("log-format", "", "kubernetes", fmt.Sprintf("Format of the --audit-log files, one of (%s)", strings.Join(LogFormats, ", ")))
+	cmd.Flags().StringP("log-group", "g", "", "AWS CloudWatch log group name")
+	cmd.Flags().StringP("region", "r", "", "AWS region for CloudWatch log group")
+	cmd.Flags().DurationP("query-timeout", "", time.Minute*5, "Maximum time to wait for a CloudWatch Logs Insights query")
+	cmd.Flags().StringP("loki-url", "", "", "Grafana Loki URL to query the audit logs from")
+	cmd.Flags().StringP("loki-selector", "", `{job=~".*audit.*"}`, "LogQL stream selector of the audit log streams in Loki")
+	cmd.Flags().StringP("loki-org-id", "", "", "Tenant of a multi-tenant Loki, sent as X-Scope-OrgID")
+	cmd.Flags().DurationP("start", "", time.Hour*24, "Start time for log parsing in time.Duration string format")
+	cmd.Flags().DurationP("end", "", 0, "End time for log parsing in time.Duration string format")
+	cmd.Flags().BoolP("strict", "", false, "Fail on audit events that can't be parsed instead of skipping them")
+}
+
+// FromFlags reads the flags registered by AddFlags, checking that exactly one data source is set
+func FromFlags(cmd *cobra.Command) (Options, error) {
+	o := Options{}
+	o.AuditLogPaths, _ = cmd.Flags().GetStringArray("audit-log")
+	o.LogFormat, _ = cmd.Flags().GetString("log-format")
+	o.LogGroup, _ = cmd.Flags().GetString("log-group")
+	o.Region, _ = cmd.Flags().GetString("region")
+	o.QueryTimeout, _ = cmd.Flags().GetDuration("query-timeout")
+	o.LokiURL, _ = cmd.Flags().GetString("loki-url")
+	o.LokiSelector, _ = cmd.Flags().GetString("loki-selector")
+	o.LokiOrgID, _ = cmd.Flags().GetString("loki-org-id")
+	o.Start, _ = cmd.Flags().GetDuration("start")
+	o.End, _ = cmd.Flags().GetDuration("end")
+	o.Strict, _ = cmd.Flags().GetBool("strict")
+
+	sources := lo.Count([]bool{len(o.AuditLogPaths) > 0, o.LogGroup != "", o.LokiURL != ""}, true)
+	if sources == 0 {
+		return Options{}, errors.New("either --audit-log, --log-group or --loki-url must be specified")
+	}
+	if sources > 1 {
+		return Options{}, errors.New("only one of --audit-log, --log-group and --loki-url can be specified")
+	}
+	if !slices.Contains(LogFormats, o.LogFormat) {
+		return Options{}, fmt.Errorf("invalid --log-format %q, must be one of %s", o.LogFormat, strings.Join(LogFormats, ", "))
+	}
+	return o, nil
+}
+
+// Window returns the time window to query, relative to now
+func (o Options) Window() (time.Time, time.Time) {
+	now := time.Now()
+	return now.Add(-o.Start), now.Add(-o.End)
+}
+
+// Provider creates the provider for the configured data source
+func (o Options) Provider() (provider.Provider, error) {
+	if o.LokiURL != "" {
+		auditProvider, err := provider.NewLoki(o.LokiURL, o.LokiSelector, o.LokiOrgID)
+		if err != nil {
+			return nil, fmt.Errorf("initializing loki provider, %w", err)
+		}
+		return auditProvider, nil
+	}
+	if o.LogGroup != "" {
+		auditProvider, err := provider.NewCloudWatch(o.LogGroup, o.Region, o.QueryTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("initializing cloudwatch provider, %w", err)
+		}
+		return auditProvider, nil
+	}
+	switch o.LogFormat {
+	case "gke":
+		auditProvider, err := provider.NewGKE(o.AuditLogPaths...)
+		if err != nil {
+			return nil, fmt.Errorf("initializing gke provider, %w", err)
+		}
+		return auditProvider, nil
+	case "aks":
+		auditProvider, err := provider.NewAKS(o.AuditLogPaths...)
+		if err != nil {
+			return nil, fmt.Errorf("initializing aks provider, %w", err)
+		}
+		return auditProvider, nil
+	}
+	auditProvider, err := provider.NewFile(o.AuditLogPaths...)
+	if err != nil {
+		return nil, fmt.Errorf("initializing file provider, %w", err)
+	}
+	return auditProvider, nil
+}
+
+// Events streams the parsed events for nn between startTime and endTime. Unless --strict is set,
+// events that can't be parsed are skipped with a warning.
+func (o Options) Events(ctx context.Context, auditProvider provider.Provider, parser object.ObjectParser, cmdType string, startTime, endTime time.Time, nn types.NamespacedName) iter.Seq2[object.ParsedEvent, error] {
+	parsedEvents := object.ParseEvents(auditProvider.GetEvents(ctx, parser, cmdType, startTime, endTime, nn))
+	if !o.Strict {
+		parsedEvents = object.SkipParseErrors(parsedEvents, os.Stderr)
+	}
+	return parsedEvents
+}
@@ -0,0 +1,168 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "timeline [<resource>/[<namespace>/]<name>...]",
+	Short: "Merge the audit log events of several Kubernetes resources into one timeline",
+	Long: `Merge the audit log events of several Kubernetes resources from local files, CloudWatch Logs or
+Grafana Loki into one chronological timeline.
+
+Each row shows when the event happened, the time since the previous row, the object, the event,
+the user that made the request and details like the node that a pod was bound to.
+
+Objects are given as <resource>/<namespace>/<name>, or as <resource>/<name> for objects in
+--namespace and for known cluster-scoped objects. Resources are named like the get and describe
+commands take them. Pods can also be selected with --selector and --field-selector, from --namespace
+or from every namespace with --all-namespaces.
+
+Additional Flags:
+  --start        Duration value from the current time to start querying the audit logs
+  --end          Duration value from the current time to finish querying the audit logs
+
+Data sources:
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
+  --log-group    AWS CloudWatch log group name
+  --region       AWS region for CloudWatch log group
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
+
+Examples:
+  # Interleave a pod, the node it ran on and the NodeClaim that was launched for it
+  kubereplay timeline pod/default/my-pod node/i-0123456789 nodeclaim/default-x7k2p -f /var/log/audit.log
+
+  # Merge the events of every pod of an app during an incident
+  kubereplay timeline -l app=web -n default --start 2h -g /aws/eks/my-cluster/audit -r us-west-2
+
+  # Follow a Deployment's rollout through its pods from Loki
+  kubereplay timeline deployment/default/web -l app=web -n default --loki-url http://loki:3100`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		namespace, _ := cmd.Flags().GetString("namespace")
+		allNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
+		selector, _ := cmd.Flags().GetString("selector")
+		fieldSelector, _ := cmd.Flags().GetString("field-selector")
+
+		if len(args) == 0 && selector == "" && fieldSelector == "" {
+			fmt.Println("Error: Either objects or a --selector or --field-selector must be given")
+			return
+		}
+		refs, err := parseRefs(args, namespace)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		var filter *object.PodFilter
+		if selector != "" || fieldSelector != "" {
+			f, err := object.NewPodFilter(selector, fieldSelector, false, false)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			filter = &f
+		}
+
+		opts, err := options.FromFlags(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if err := RunTimeline(ctx, opts, refs, filter, lo.Ternary(allNamespaces, "", namespace)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	Cmd.Flags().StringP("namespace", "n", "default", "Namespace of objects given without one, and of the pods to select")
+	Cmd.Flags().BoolP("all-namespaces", "A", false, "Select pods across every namespace")
+	Cmd.Flags().StringP("selector", "l", "", "Label selector of the pods to add to the timeline")
+	Cmd.Flags().StringP("field-selector", "", "", "Field selector of the pods to add to the timeline")
+	options.AddFlags(Cmd)
+}
+
+// Ref is an object given on the command line
+type Ref struct {
+	Parser        object.ObjectParser
+	NamespaceName types.NamespacedName
+}
+
+// parseRefs parses objects given as <resource>/<namespace>/<name>, or as <resource>/<name> for
+// objects in the default namespace and cluster-scoped objects. A namespace that's given for a
+// cluster-scoped object is dropped.
+func parseRefs(args []string, namespace string) ([]Ref, error) {
+	var refs []Ref
+	for _, arg := range args {
+		parts := strings.Split(arg, "/")
+		if len(parts) < 2 || len(parts) > 3 || lo.Contains(parts, "") {
+			return nil, fmt.Errorf("invalid object %q, expected <resource>/[<namespace>/]<name>", arg)
+		}
+		ref := Ref{Parser: object.NewObjectParserFrom(parts[0])}
+		switch {
+		case len(parts) == 3:
+			ref.NamespaceName = object.NamespacedName(ref.Parser, parts[1], parts[2])
+		default:
+			ref.NamespaceName = object.NamespacedName(ref.Parser, namespace, parts[1])
+		}
+		if !lo.ContainsBy(refs, func(r Ref) bool {
+			return r.Parser.ObjectType() == ref.Parser.ObjectType() && r.NamespaceName == ref.NamespaceName
+		}) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// RunTimeline prints the merged events of the objects, and of the pods that match the filter in the
+// namespace, or in every namespace when it's empty
+func RunTimeline(ctx context.Context, opts options.Options, refs []Ref, filter *object.PodFilter, namespace string) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	var events []object.ParsedEvent
+	for _, ref := range refs {
+		// Describe queries also find the events about an object, like Karpenter's nominations of a pod
+		refEvents, err := object.EventsOf(ref.Parser.ObjectType(), ref.NamespaceName, opts.Events(ctx, auditProvider, ref.Parser, "describe", startTime, endTime, ref.NamespaceName))
+		if err != nil {
+			return fmt.Errorf("parsing events, %w", err)
+		}
+		events = append(events, refEvents...)
+	}
+	if filter != nil {
+		podEvents, err := object.PodEvents(opts.Events(ctx, auditProvider, object.PodParser{}, "list", startTime, endTime, types.NamespacedName{Namespace: namespace}), *filter)
+		if err != nil {
+			return fmt.Errorf("parsing events, %w", err)
+		}
+		// Pods that were also given by name already have their events
+		events = append(events, lo.Reject(podEvents, func(e object.ParsedEvent, _ int) bool {
+			return lo.ContainsBy(refs, func(r Ref) bool { return r.Parser.ObjectType() == e.ObjectType && r.NamespaceName == e.NamespaceName })
+		})...)
+	}
+	if len(events) == 0 {
+		fmt.Println("No events found")
+		return nil
+	}
+	fmt.Print(object.FormatTimeline(events))
+	return nil
+}
@@ -0,0 +1,128 @@
+package timeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseRefs(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "namespaced object", args: []string{"pod/kube-system/coredns"}, want: []string{"Pod kube-system/coredns"}},
+		{name: "namespaced object in --namespace", args: []string{"pod/web"}, want: []string{"Pod prod/web"}},
+		{name: "cluster-scoped object", args: []string{"node/node-a"}, want: []string{"Node node-a"}},
+		{name: "cluster-scoped object given with a namespace", args: []string{"nodeclaim/default/default-x7k2p"}, want: []string{"NodeClaim default-x7k2p"}},
+		{
+			name: "the same object given twice",
+			args: []string{"node/node-a", "node/default/node-a", "pod/prod/web", "pod/web"},
+			want: []string{"Node node-a", "Pod prod/web"},
+		},
+		{name: "missing name", args: []string{"pod"}, wantErr: true},
+		{name: "empty namespace", args: []string{"pod//web"}, wantErr: true},
+		{name: "too many parts", args: []string{"pod/prod/web/extra"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			refs, err := parseRefs(tc.args, "prod")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range refs {
+				got = append(got, string(r.Parser.ObjectType())+" "+strings.TrimPrefix(r.NamespaceName.String(), "/"))
+			}
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunTimeline(t *testing.T) {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	path := test.WriteAuditLog(t,
+		test.Event{
+			Verb: "create", Resource: "pods", Namespace: "default", Name: "web", Time: at(0),
+			ResponseObject: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","namespace":"default","uid":"uid-1","labels":{"app":"web"}}}`,
+		},
+		test.Event{
+			Verb: "create", Resource: "nodes", Name: "node-a", Time: at(1),
+			ResponseObject: `{"apiVersion":"v1","kind":"Node","metadata":{"name":"node-a","uid":"node-uid"}}`,
+		},
+		test.Event{
+			Verb: "create", Resource: "pods", Subresource: "binding", Namespace: "default", Name: "web", Time: at(2),
+			RequestObject:  `{"kind":"Binding","apiVersion":"v1","metadata":{"name":"web"},"target":{"kind":"Node","name":"node-a"}}`,
+			ResponseObject: `{"kind":"Status","apiVersion":"v1","status":"Success"}`,
+		},
+		test.Event{
+			Verb: "create", Resource: "pods", Namespace: "default", Name: "api", Time: at(3),
+			ResponseObject: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"api","namespace":"default","uid":"uid-2","labels":{"app":"web"}}}`,
+		},
+		test.Event{
+			Verb: "create", Resource: "pods", Namespace: "default", Name: "db", Time: at(4),
+			ResponseObject: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"db","namespace":"default","uid":"uid-3","labels":{"app":"db"}}}`,
+		},
+	)
+	opts := options.Options{AuditLogPaths: []string{path}, LogFormat: "kubernetes", Start: 2 * time.Hour}
+	refs, err := parseRefs([]string{"pod/web", "node/node-a"}, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := object.NewPodFilter("app=web", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := test.CaptureOutput(t, func() error {
+		return RunTimeline(context.Background(), opts, refs, &filter, "default")
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	// The pod web is both given by name and selected, and its events are only shown once
+	want := []struct{ object, event string }{
+		{"pod/default/web", object.EventTypePodCreated},
+		{"node/node-a", object.EventTypeNodeCreated},
+		{"pod/default/web", object.EventTypePodBound},
+		{"pod/default/api", object.EventTypePodCreated},
+	}
+	if len(lines) != len(want)+1 {
+		t.Fatalf("got %d lines, want a header and %d events:\n%s", len(lines), len(want), output)
+	}
+	for i, w := range want {
+		fields := strings.Fields(lines[i+1])
+		if fields[2] != w.object || fields[3] != w.event {
+			t.Errorf("row %d is %q, want %s %s", i+1, lines[i+1], w.object, w.event)
+		}
+	}
+	if fields := strings.Fields(lines[2]); fields[1] != "+1m0s" {
+		t.Errorf("got delta %q on the second row, want +1m0s", fields[1])
+	}
+	if !strings.Contains(lines[3], "NodeName=node-a") {
+		t.Errorf("binding row %q doesn't show the node", lines[3])
+	}
+}
+
+func TestRunTimelineWithoutEvents(t *testing.T) {
+	opts := options.Options{AuditLogPaths: []string{test.WriteAuditLog(t)}, LogFormat: "kubernetes", Start: time.Hour}
+	output := test.CaptureOutput(t, func() error {
+		return RunTimeline(context.Background(), opts, []Ref{{Parser: object.PodParser{}, NamespaceName: types.NamespacedName{Namespace: "default", Name: "web"}}}, nil, "default")
+	})
+	if !strings.Contains(output, "No events found") {
+		t.Errorf("got %q, want No events found", output)
+	}
+}
@@ -0,0 +1,110 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/joinnis/kubereplay/pkg/cmd/options"
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "tree <resource>[.<group>] <name>",
+	Short: "Show the objects related to a Kubernetes resource through ownerReferences and bindings",
+	Long: `Show the objects related to a Kubernetes resource from audit log events from local files,
+CloudWatch Logs or Grafana Loki, as a tree annotated with when each object was created and deleted.
+
+The tree walks ownerReferences in both directions. It starts at the top-most owner of the object,
+goes down to the object and then through everything that the object owns, like a CronJob's Jobs
+and their Pods or a Deployment's ReplicaSets and their Pods. Pods are followed to the Node that
+they were bound to, and the Node to its owners, like the Karpenter NodeClaim it was launched for.
+
+The events of each related object are queried as it's found, so large trees run several queries.
+
+Resources are named like the get and describe commands take them: pod, node, nodeclaim, nodepool,
+deployment, replicaset, statefulset, daemonset, job, cronjob, or any other resource by its plural
+name and API group. The namespace defaults to default and is ignored for nodes, nodeclaims,
+nodepools and built-in cluster-scoped resources like clusterroles.rbac.authorization.k8s.io. Pass
+--namespace "" for cluster-scoped custom resources.
+
+A cluster-scoped object is looked up without a namespace, and its tree starts at its top-most
+owner and goes down through what it owns like any other, so a node's tree runs from its NodePool
+through its NodeClaim. Bindings are only followed from pods, so the pods that ran on a node are
+not listed under it.
+
+Additional Flags:
+  --start        Duration value from the current time to start querying the audit logs
+  --end          Duration value from the current time to finish querying the audit logs
+
+Data sources:
+  --audit-log    Local audit log file, directory or glob, or - for stdin. Repeatable, and gzip
+                 and zstd files are decompressed
+  --log-format   Format of the --audit-log files: kubernetes, gke for exported Cloud Logging entries,
+                 or aks for exported kube-audit diagnostic logs
+  --log-group    AWS CloudWatch log group name
+  --region       AWS region for CloudWatch log group
+  --query-timeout  Maximum time to wait for a CloudWatch Logs Insights query
+  --loki-url     Grafana Loki URL, with --loki-selector for the audit log streams and
+                 --loki-org-id for the tenant of a multi-tenant Loki
+
+Error handling:
+  --strict       Fail on audit events that can't be parsed instead of skipping them with a warning
+
+Examples:
+  # Show a deployment's ReplicaSets, their pods and the nodes the pods ran on
+  kubereplay tree deployment my-deployment -n default -f /var/log/audit.log
+
+  # Show the Job and CronJob that a pod came from, and the NodeClaim of its node
+  kubereplay tree pod backup-28312345-x7k2p -n default -g /aws/eks/my-cluster/audit -r us-west-2`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		resource, name := args[0], args[1]
+		namespace, _ := cmd.Flags().GetString("namespace")
+
+		opts, err := options.FromFlags(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		parser := object.NewObjectParserFrom(resource)
+		if err := RunTree(ctx, parser, opts, object.NamespacedName(parser, namespace, name)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	Cmd.Flags().StringP("namespace", "n", "default", "Namespace of the object, ignored for known cluster-scoped resources")
+	options.AddFlags(Cmd)
+}
+
+func RunTree(ctx context.Context, parser object.ObjectParser, opts options.Options, nn types.NamespacedName) error {
+	auditProvider, err := opts.Provider()
+	if err != nil {
+		return err
+	}
+	defer auditProvider.Close()
+	startTime, endTime := opts.Window()
+	trees, err := object.Tree(parser, nn, func(parser object.ObjectParser, cmdType string, nn types.NamespacedName) iter.Seq2[object.ParsedEvent, error] {
+		return opts.Events(ctx, auditProvider, parser, cmdType, startTime, endTime, nn)
+	})
+	if err != nil {
+		return fmt.Errorf("parsing events, %w", err)
+	}
+	if len(trees) == 0 {
+		fmt.Printf("No events found for: %s\n", nn)
+		return nil
+	}
+	for i, tree := range trees {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(object.FormatTree(tree))
+	}
+	return nil
+}
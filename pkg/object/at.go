@@ -0,0 +1,86 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// State is the state of an object at a point in time
+type State struct {
+	// Object is the incarnation of the object that existed at the time, if any. Its snapshot is the
+	// latest one logged at or before the time.
+	Object Object
+	// CreationTime is when the object was created after the time, if it didn't exist yet
+	CreationTime time.Time
+	// DeletionTime is when the object was deleted, if it had already been deleted by the time
+	DeletionTime time.Time
+}
+
+// At reconstructs the state of the named object at time t. Events after t only serve to tell
+// whether an object that didn't exist at t was created later on.
+func At(parser ObjectParser, nn types.NamespacedName, events iter.Seq2[ParsedEvent, error], t time.Time) (State, error) {
+	// creations holds when each incarnation of the object was created after t
+	creations := map[types.UID]time.Time{}
+	objs, err := parser.Coalesce(nn, func(yield func(ParsedEvent, error) bool) {
+		for e, err := range events {
+			if err == nil && e.Timestamp.After(t) {
+				if e.ObjectType == parser.ObjectType() && e.NamespaceName == nn && e.Verb == "create" && e.Subresource == "" {
+					if created, ok := creations[e.UID]; !ok || e.Timestamp.Before(created) {
+						creations[e.UID] = e.Timestamp
+					}
+				}
+				continue
+			}
+			if !yield(e, err) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return State{}, err
+	}
+	// Incarnations that were already around at t weren't created after it, however late their
+	// create was logged
+	for _, obj := range objs {
+		delete(creations, obj.Incarnation().UID)
+	}
+	createdAfter := lo.MinBy(lo.Values(creations), func(a, b time.Time) bool { return a.Before(b) })
+	if len(objs) == 0 {
+		return State{CreationTime: createdAfter}, nil
+	}
+	// Incarnations are ordered by creation, so only the latest one can still have existed at t
+	latest := objs[len(objs)-1]
+	if deleted := latest.Incarnation().DeletionTime; !deleted.IsZero() {
+		return State{CreationTime: createdAfter, DeletionTime: deleted}, nil
+	}
+	return State{Object: latest}, nil
+}
+
+// AtWithLookback reconstructs the state of the named object at time t from the events returned by
+// events for a window starting at startTime. When the window doesn't hold a snapshot of the object,
+// its lookback from t is doubled until one is found or the lookback reaches maxLookback, with a
+// note written to w each time. A window that starts at t or later is extended to a lookback of a
+// minute first. It returns the start of the last window that was searched.
+func AtWithLookback(parser ObjectParser, nn types.NamespacedName, events func(startTime time.Time) iter.Seq2[ParsedEvent, error], t, startTime time.Time, maxLookback time.Duration, w io.Writer) (State, time.Time, error) {
+	for {
+		state, err := At(parser, nn, events(startTime), t)
+		if err != nil {
+			return State{}, startTime, err
+		}
+		if state.Object != nil && state.Object.Snapshot() != nil || !state.DeletionTime.IsZero() || !state.CreationTime.IsZero() {
+			return state, startTime, nil
+		}
+		lookback := max(t.Sub(startTime), 0)
+		if lookback >= maxLookback {
+			return state, startTime, nil
+		}
+		lookback = min(max(lookback*2, time.Minute), maxLookback)
+		fmt.Fprintf(w, "No snapshot found for %s, extending lookback to %s\n", nn, lookback)
+		startTime = t.Add(-lookback)
+	}
+}
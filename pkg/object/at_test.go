@@ -0,0 +1,165 @@
+package object
+
+import (
+	"io"
+	"iter"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	atTime = time.Date(2025, 9, 15, 16, 0, 0, 0, time.UTC)
+	atNN   = types.NamespacedName{Namespace: "default", Name: "web"}
+)
+
+func podEvent(verb string, uid types.UID, at time.Time, withObject bool) ParsedEvent {
+	e := ParsedEvent{
+		Timestamp:     at,
+		NamespaceName: atNN,
+		UID:           uid,
+		Verb:          verb,
+		ObjectType:    ObjectTypePod,
+		Event:         map[string]EventType{"create": EventTypePodCreated, "update": EventTypePodUpdated, "delete": EventTypePodDeleted}[verb],
+	}
+	if withObject {
+		e.Object = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: atNN.Namespace, Name: atNN.Name, UID: uid}}
+	}
+	return e
+}
+
+func seqOf(events ...ParsedEvent) iter.Seq2[ParsedEvent, error] {
+	return func(yield func(ParsedEvent, error) bool) {
+		for _, e := range events {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		events       []ParsedEvent
+		wantSnapshot bool
+		wantCreated  time.Time
+		wantDeleted  time.Time
+	}{
+		{
+			name:         "snapshot before the time",
+			events:       []ParsedEvent{podEvent("create", "a", atTime.Add(-time.Hour), true), podEvent("update", "a", atTime.Add(time.Hour), true)},
+			wantSnapshot: true,
+		},
+		{
+			name:        "created after the time",
+			events:      []ParsedEvent{podEvent("create", "a", atTime.Add(time.Hour), true)},
+			wantCreated: atTime.Add(time.Hour),
+		},
+		{
+			name: "create of another kind with the same name",
+			events: []ParsedEvent{
+				func() ParsedEvent {
+					e := podEvent("create", "a", atTime.Add(time.Hour), false)
+					e.ObjectType = ObjectTypeNode
+					return e
+				}(),
+			},
+		},
+		{
+			name: "deleted and re-created after the time",
+			events: []ParsedEvent{
+				podEvent("create", "a", atTime.Add(-2*time.Hour), true),
+				podEvent("delete", "a", atTime.Add(-time.Hour), false),
+				podEvent("create", "b", atTime.Add(time.Hour), true),
+			},
+			wantCreated: atTime.Add(time.Hour),
+			wantDeleted: atTime.Add(-time.Hour),
+		},
+		{
+			name: "late create of an incarnation that already existed",
+			events: []ParsedEvent{
+				podEvent("update", "a", atTime.Add(-time.Hour), false),
+				podEvent("create", "a", atTime.Add(time.Hour), true),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := At(PodParser{}, atNN, seqOf(tc.events...), atTime)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := state.Object != nil && state.Object.Snapshot() != nil; got != tc.wantSnapshot {
+				t.Errorf("snapshot found = %v, want %v", got, tc.wantSnapshot)
+			}
+			if !state.CreationTime.Equal(tc.wantCreated) {
+				t.Errorf("creation time = %s, want %s", state.CreationTime, tc.wantCreated)
+			}
+			if !state.DeletionTime.Equal(tc.wantDeleted) {
+				t.Errorf("deletion time = %s, want %s", state.DeletionTime, tc.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestAtWithLookback(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		startTime   time.Time
+		maxLookback time.Duration
+		events      []ParsedEvent
+		wantStart   time.Time
+	}{
+		{
+			name:        "no lookback",
+			startTime:   atTime,
+			maxLookback: 4 * time.Minute,
+			wantStart:   atTime.Add(-4 * time.Minute),
+		},
+		{
+			name:        "window starting after the time",
+			startTime:   atTime.Add(time.Hour),
+			maxLookback: time.Minute,
+			wantStart:   atTime.Add(-time.Minute),
+		},
+		{
+			name:        "no max lookback",
+			startTime:   atTime,
+			maxLookback: 0,
+			wantStart:   atTime,
+		},
+		{
+			name:        "snapshot found by doubling",
+			startTime:   atTime.Add(-time.Hour),
+			maxLookback: 24 * time.Hour,
+			events:      []ParsedEvent{podEvent("create", "a", atTime.Add(-3*time.Hour), true)},
+			wantStart:   atTime.Add(-4 * time.Hour),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			searched := 0
+			events := func(startTime time.Time) iter.Seq2[ParsedEvent, error] {
+				if searched++; searched > 100 {
+					t.Fatalf("lookback didn't converge, last window started at %s", startTime)
+				}
+				return func(yield func(ParsedEvent, error) bool) {
+					for _, e := range tc.events {
+						if !e.Timestamp.Before(startTime) && !yield(e, nil) {
+							return
+						}
+					}
+				}
+			}
+			_, startTime, err := AtWithLookback(PodParser{}, atNN, events, atTime, tc.startTime, tc.maxLookback, io.Discard)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !startTime.Equal(tc.wantStart) {
+				t.Errorf("last window started at %s, want %s", startTime, tc.wantStart)
+			}
+		})
+	}
+}
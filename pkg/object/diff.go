@@ -0,0 +1,38 @@
+package object
+
+import (
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// UnifiedDiff renders a unified diff between the YAML of two objects, either of which may be nil
+// when the object didn't exist. It's empty when the objects are the same.
+func UnifiedDiff(a, b client.Object, fromName, toName string) string {
+	return lo.Must(difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(toYAML(a)),
+		B:        difflib.SplitLines(toYAML(b)),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	}))
+}
+
+func toYAML(obj client.Object) string {
+	if obj == nil {
+		return ""
+	}
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(obj))))
+}
+
+// WithoutManagedFields returns a copy of obj without its managedFields, which are left out of the
+// output unless they're asked for, like kubectl does. It's nil when obj is.
+func WithoutManagedFields(obj client.Object) client.Object {
+	if obj == nil || len(obj.GetManagedFields()) == 0 {
+		return obj
+	}
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.SetManagedFields(nil)
+	return obj
+}
@@ -0,0 +1,63 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// errObjectNotLogged is wrapped by the errors of events that were logged without the object they're
+// about, like every event of the Metadata level. Those aren't malformed, and audit policies log
+// plenty of them, so they're only counted when they're skipped.
+var errObjectNotLogged = errors.New("doesn't include the object")
+
+// ParseError is returned for an audit event that couldn't be parsed. It only affects that event, so
+// callers can choose to skip it and carry on with the rest of the stream.
+type ParseError struct {
+	AuditID string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	if e.AuditID == "" {
+		return fmt.Sprintf("parsing event, %s", e.Err)
+	}
+	return fmt.Sprintf("parsing event %s, %s", e.AuditID, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// SkipParseErrors drops every *ParseError from events, writing a warning for each to w along with
+// a count of the skipped events once the sequence has been consumed. Events that were logged without
+// their object are only counted. Any other error is passed through.
+func SkipParseErrors[T any](events iter.Seq2[T, error], w io.Writer) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		skipped, notLogged := 0, 0
+		defer func() {
+			if skipped > 0 {
+				fmt.Fprintf(w, "Warning: skipped %d event(s) that couldn't be parsed, use --strict to fail on them instead\n", skipped)
+			}
+			if notLogged > 0 {
+				fmt.Fprintf(w, "Warning: skipped %d event(s) that were logged without their object, like events at the Metadata level\n", notLogged)
+			}
+		}()
+		for e, err := range events {
+			var parseErr *ParseError
+			switch {
+			case errors.Is(err, errObjectNotLogged):
+				notLogged++
+				continue
+			case errors.As(err, &parseErr):
+				skipped++
+				fmt.Fprintf(w, "Warning: %s\n", parseErr)
+				continue
+			}
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package object
+
+import (
+	"bytes"
+	"iter"
+	"strings"
+	"testing"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+)
+
+func TestSkipParseErrors(t *testing.T) {
+	event := func(auditID, level, verb, response string) auditmodel.Event {
+		return auditmodel.Event{
+			AuditID:        auditID,
+			Level:          level,
+			Verb:           verb,
+			ObjectRef:      &auditmodel.ObjectReference{Resource: "pods", Namespace: "default", Name: "web"},
+			ResponseObject: []byte(response),
+		}
+	}
+	events := []auditmodel.Event{
+		event("created", "RequestResponse", "create", `{"kind":"Pod","metadata":{"namespace":"default","name":"web"}}`),
+		event("metadata-update", "Metadata", "update", ""),
+		event("metadata-patch", "Metadata", "patch", ""),
+		event("malformed", "RequestResponse", "update", `{"kind":"Pod","metadata":"web"}`),
+	}
+	var source iter.Seq2[auditmodel.Event, error] = func(yield func(auditmodel.Event, error) bool) {
+		for _, e := range events {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+	warnings := &bytes.Buffer{}
+	var got []ParsedEvent
+	for pe, err := range SkipParseErrors(ParseEvents(source), warnings) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, pe)
+	}
+	if len(got) != 1 || got[0].Event != EventTypePodCreated {
+		t.Errorf("got events %v, want the create", got)
+	}
+	lines := strings.Split(strings.TrimSpace(warnings.String()), "\n")
+	want := []string{
+		"Warning: parsing event malformed, ",
+		"Warning: skipped 1 event(s) that couldn't be parsed",
+		"Warning: skipped 2 event(s) that were logged without their object",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got warnings\n%s\nwant %d lines", warnings, len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(lines[i], w) {
+			t.Errorf("got warning %q, want it to start with %q", lines[i], w)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package object
+
+import (
+	"regexp"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	EventTypePodNominated = "PodNominated"
+)
+
+// nominationTarget matches the capacity that Karpenter nominated a pod for in the message of its
+// Nominated event, like "Pod should schedule on: nodeclaim/default-x8zvc, node/ip-10-0-1-2"
+var nominationTarget = regexp.MustCompile(`\b(nodeclaim|machine|node)/([^\s,]+)`)
+
+// EventParser extracts the Kubernetes Events that are recorded against other objects. The extracted
+// events are attributed to the object that they're about, so that they're coalesced along with the
+// rest of the object's events.
+type EventParser struct{}
+
+// Extract handles the creation of core/v1 and events.k8s.io/v1 Events. Updates to an Event only
+// bump its count, so they don't add anything.
+func (EventParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	if event.Verb != "create" {
+		return ParsedEvent{}, nil
+	}
+	raw := lo.Ternary(isEmptyObject(event.ResponseObject), event.RequestObject, event.ResponseObject)
+	var regarding v1.ObjectReference
+	var reason, message string
+	if event.ObjectRef.APIGroup == eventsv1.GroupName {
+		var e eventsv1.Event
+		if err := decodeObject(event, raw, &e); err != nil {
+			return ParsedEvent{}, err
+		}
+		regarding, reason, message = e.Regarding, e.Reason, e.Note
+	} else {
+		var e v1.Event
+		if err := decodeObject(event, raw, &e); err != nil {
+			return ParsedEvent{}, err
+		}
+		regarding, reason, message = e.InvolvedObject, e.Reason, e.Message
+	}
+
+	pe := ParsedEvent{
+		Timestamp:            event.RequestReceivedTimestamp.Time,
+		NamespaceName:        types.NamespacedName{Namespace: regarding.Namespace, Name: regarding.Name},
+		UID:                  regarding.UID,
+		AdditionalProperties: map[string]string{"Message": message},
+	}
+	switch {
+	case regarding.Kind == ObjectTypePod && reason == "Nominated":
+		pe.ObjectType = ObjectTypePod
+		pe.Event = EventTypePodNominated
+		for _, m := range nominationTarget.FindAllStringSubmatch(message, -1) {
+			switch m[1] {
+			case "nodeclaim", "machine":
+				pe.AdditionalProperties["NodeClaim"] = m[2]
+			case "node":
+				pe.AdditionalProperties["Node"] = m[2]
+			}
+		}
+	default:
+		return ParsedEvent{}, nil
+	}
+	return pe, nil
+}
@@ -0,0 +1,142 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// nominatedEvent is Karpenter's Nominated event for the pod web, recorded through either the
+// core/v1 or the events.k8s.io/v1 API
+func nominatedEvent(apiGroup, message string, at time.Time) test.Event {
+	object := fmt.Sprintf(`{"kind":"Event","apiVersion":"v1","metadata":{"namespace":"default","name":"web.1"},"involvedObject":{"kind":"Pod","namespace":"default","name":"web","uid":"uid-1"},"reason":"Nominated","message":%q}`, message)
+	if apiGroup != "" {
+		object = fmt.Sprintf(`{"kind":"Event","apiVersion":"events.k8s.io/v1","metadata":{"namespace":"default","name":"web.1"},"regarding":{"kind":"Pod","namespace":"default","name":"web","uid":"uid-1"},"reason":"Nominated","note":%q}`, message)
+	}
+	return test.Event{Verb: "create", APIGroup: apiGroup, Resource: "events", Namespace: "default", Name: "web.1", Time: at, ResponseObject: object}
+}
+
+func TestEventParser(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		event         test.Event
+		wantEvent     EventType
+		wantNodeClaim string
+		wantNode      string
+	}{
+		{
+			name:          "nomination to a NodeClaim",
+			event:         nominatedEvent("", "Pod should schedule on: nodeclaim/default-x8zvc", atTime),
+			wantEvent:     EventTypePodNominated,
+			wantNodeClaim: "default-x8zvc",
+		},
+		{
+			name:          "nomination to a NodeClaim and its node",
+			event:         nominatedEvent("", "Pod should schedule on: nodeclaim/default-x8zvc, node/ip-10-0-1-2", atTime),
+			wantEvent:     EventTypePodNominated,
+			wantNodeClaim: "default-x8zvc",
+			wantNode:      "ip-10-0-1-2",
+		},
+		{
+			name:          "nomination recorded through events.k8s.io",
+			event:         nominatedEvent("events.k8s.io", "Pod should schedule on: machine/default-x8zvc", atTime),
+			wantEvent:     EventTypePodNominated,
+			wantNodeClaim: "default-x8zvc",
+		},
+		{
+			name: "another reason",
+			event: test.Event{
+				Verb: "create", Resource: "events", Namespace: "default", Name: "web.2", Time: atTime,
+				ResponseObject: `{"kind":"Event","apiVersion":"v1","involvedObject":{"kind":"Pod","namespace":"default","name":"web"},"reason":"Scheduled"}`,
+			},
+		},
+		{
+			name: "update that bumps the count",
+			event: func() test.Event {
+				e := nominatedEvent("", "Pod should schedule on: nodeclaim/default-x8zvc", atTime)
+				e.Verb = "update"
+				return e
+			}(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for e, err := range test.AuditEvents(t, tc.event) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				pe, err := EventParser{}.Extract(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pe.Event != tc.wantEvent || pe.AdditionalProperties["NodeClaim"] != tc.wantNodeClaim || pe.AdditionalProperties["Node"] != tc.wantNode {
+					t.Errorf("got %q to NodeClaim %q and Node %q, want %q to NodeClaim %q and Node %q",
+						pe.Event, pe.AdditionalProperties["NodeClaim"], pe.AdditionalProperties["Node"], tc.wantEvent, tc.wantNodeClaim, tc.wantNode)
+				}
+				if tc.wantEvent != "" && (pe.NamespaceName != atNN || pe.UID != "uid-1") {
+					t.Errorf("got the event attributed to %s with UID %q, want %s with UID uid-1", pe.NamespaceName, pe.UID, atNN)
+				}
+			}
+		})
+	}
+}
+
+func TestPodNominations(t *testing.T) {
+	minute := func(m int) time.Time { return atTime.Add(time.Duration(m) * time.Minute) }
+	nodeClaim := func(verb, name, conditions string, minutes int) test.Event {
+		return test.Event{
+			Verb: verb, APIGroup: "karpenter.sh", Resource: "nodeclaims", Name: name, Time: minute(minutes),
+			Subresource:    map[string]string{"patch": "status"}[verb],
+			RequestObject:  `{}`,
+			ResponseObject: fmt.Sprintf(`{"kind":"NodeClaim","apiVersion":"karpenter.sh/v1","metadata":{"name":%q,"uid":"nc-%s"},"status":{"conditions":[%s]}}`, name, name, conditions),
+		}
+	}
+	launched := func(transitioned string) string {
+		return fmt.Sprintf(`{"type":"Launched","status":"True","reason":"Launched","message":"","lastTransitionTime":%q}`, transitioned)
+	}
+	events := []test.Event{
+		{
+			Verb: "create", Resource: "pods", Namespace: "default", Time: minute(0),
+			ResponseObject: `{"kind":"Pod","apiVersion":"v1","metadata":{"namespace":"default","name":"web","uid":"uid-1"}}`,
+		},
+		nominatedEvent("", "Pod should schedule on: nodeclaim/default-aaaaa", minute(1)),
+		nodeClaim("create", "default-aaaaa", "", 1),
+		// The status is written after the condition transitioned
+		nodeClaim("patch", "default-aaaaa", launched(minute(2).Format(time.RFC3339)), 3),
+		nodeClaim("patch", "default-aaaaa", launched(minute(2).Format(time.RFC3339)), 4),
+		// Karpenter nominates the pod again once the NodeClaim is deleted
+		nominatedEvent("events.k8s.io", "Pod should schedule on: nodeclaim/default-bbbbb", minute(5)),
+		nodeClaim("create", "default-bbbbb", "", 5),
+		// A NodeClaim that the pod wasn't nominated to
+		nodeClaim("patch", "default-ccccc", launched(minute(1).Format(time.RFC3339)), 5),
+		// An existing node
+		nominatedEvent("", "Pod should schedule on: node/ip-10-0-1-2", minute(6)),
+	}
+	objs, err := PodParser{}.Coalesce(atNN, ParseEvents(test.AuditEvents(t, events...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("got %d pods, want 1", len(objs))
+	}
+	want := []Nomination{
+		{Timestamp: minute(1), NodeClaim: "default-aaaaa", LaunchTime: minute(2)},
+		// The second NodeClaim never launched
+		{Timestamp: minute(5), NodeClaim: "default-bbbbb"},
+		{Timestamp: minute(6), Node: "ip-10-0-1-2"},
+	}
+	got := objs[0].(Pod).Nominations
+	if len(got) != len(want) {
+		t.Fatalf("got %d nominations, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) || got[i].NodeClaim != want[i].NodeClaim || got[i].Node != want[i].Node || !got[i].LaunchTime.Equal(want[i].LaunchTime) {
+			t.Errorf("nomination %d: got %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+	if got := objs[0].(Pod).UID; got != types.UID("uid-1") {
+		t.Errorf("got UID %q, want uid-1", got)
+	}
+}
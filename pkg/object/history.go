@@ -0,0 +1,139 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Revision is a single snapshot of an object reconstructed from the audit log
+type Revision struct {
+	Timestamp       time.Time
+	UID             types.UID
+	Verb            string
+	Subresource     string
+	User            string
+	ResourceVersion string
+	Object          client.Object
+	// Changes are the field paths that changed since the previous revision of the same incarnation,
+	// or nil for the first revision of an incarnation
+	Changes []string
+}
+
+// History returns every revision of the named object that's logged in events, oldest first
+func History(nn types.NamespacedName, objectType ObjectType, events iter.Seq2[ParsedEvent, error]) ([]Revision, error) {
+	var revisions []Revision
+	for e, err := range events {
+		if err != nil {
+			return nil, err
+		}
+		if e.ObjectType != objectType || e.NamespaceName != nn || e.Object == nil {
+			continue
+		}
+		revisions = append(revisions, Revision{
+			Timestamp:       e.Timestamp,
+			UID:             e.UID,
+			Verb:            e.Verb,
+			Subresource:     e.Subresource,
+			User:            e.User,
+			ResourceVersion: e.Object.GetResourceVersion(),
+			Object:          e.Object,
+		})
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Timestamp.Before(revisions[j].Timestamp)
+	})
+	previous := map[types.UID]client.Object{}
+	for i, r := range revisions {
+		if prev, ok := previous[r.UID]; ok {
+			revisions[i].Changes = ChangedPaths(prev, r.Object, 2)
+		}
+		previous[r.UID] = r.Object
+	}
+	return revisions, nil
+}
+
+// Summary describes what changed in the revision
+func (r Revision) Summary() string {
+	switch {
+	case r.Changes == nil && r.Verb == "create":
+		return "created"
+	case r.Changes == nil:
+		return "first seen"
+	case len(r.Changes) == 0:
+		return "no changes"
+	case len(r.Changes) > 3:
+		return fmt.Sprintf("%s and %d more", strings.Join(r.Changes[:3], ", "), len(r.Changes)-3)
+	}
+	return strings.Join(r.Changes, ", ")
+}
+
+// FormatHistory renders a table of revisions, numbered from 1
+func FormatHistory(revisions []Revision) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tTIMESTAMP\tVERB\tSUBRESOURCE\tUSER\tRESOURCEVERSION\tCHANGES")
+	for i, r := range revisions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, formatTime(r.Timestamp), r.Verb,
+			lo.CoalesceOrEmpty(r.Subresource, "-"), r.User, lo.CoalesceOrEmpty(r.ResourceVersion, "-"), r.Summary())
+	}
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+// ignoredPaths change on every write, so they're left out when comparing objects
+var ignoredPaths = sets.New("metadata.resourceVersion", "metadata.managedFields")
+
+// ChangedPaths returns the sorted field paths that differ between two objects, either of which may
+// be nil. Paths are followed down to maxDepth levels, or all the way to the leaves when maxDepth is
+// 0. Lists of the same length are compared element by element, and lists that changed length are
+// reported as a whole.
+func ChangedPaths(a, b client.Object, maxDepth int) []string {
+	paths := []string{}
+	changedPaths("", toUnstructured(a), toUnstructured(b), 1, maxDepth, &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+func toUnstructured(obj client.Object) map[string]interface{} {
+	if obj == nil {
+		return map[string]interface{}{}
+	}
+	return lo.Must(runtime.DefaultUnstructuredConverter.ToUnstructured(obj))
+}
+
+func changedPaths(path string, a, b interface{}, depth, maxDepth int, paths *[]string) {
+	if ignoredPaths.Has(path) || reflect.DeepEqual(a, b) {
+		return
+	}
+	if maxDepth == 0 || depth <= maxDepth {
+		switch av := a.(type) {
+		case map[string]interface{}:
+			if bv, ok := b.(map[string]interface{}); ok {
+				for _, k := range lo.Union(lo.Keys(av), lo.Keys(bv)) {
+					changedPaths(lo.Ternary(path == "", k, path+"."+k), av[k], bv[k], depth+1, maxDepth, paths)
+				}
+				return
+			}
+		case []interface{}:
+			if bv, ok := b.([]interface{}); ok && len(av) == len(bv) {
+				for i := range av {
+					changedPaths(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i], depth+1, maxDepth, paths)
+				}
+				return
+			}
+		}
+	}
+	*paths = append(*paths, path)
+}
@@ -0,0 +1,215 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Incarnation identifies one lifetime of an object. An object that's deleted and created again with
+// the same name, like a StatefulSet pod or a recycled node name, has one incarnation per UID.
+type Incarnation struct {
+	UID          types.UID
+	CreationTime time.Time
+	DeletionTime time.Time
+}
+
+// lifetime is the state that every kind of object tracks for each of its incarnations: which
+// incarnation it is, when it was created, last updated and deleted, and when the latest snapshot of
+// it was logged
+type lifetime struct {
+	NamespaceName   types.NamespacedName
+	UID             types.UID
+	CreationTime    time.Time `json:",omitzero"`
+	LastUpdatedTime time.Time `json:",omitzero"`
+	DeletionTime    time.Time `json:",omitzero"`
+
+	// snapshotTime is when the latest snapshot was logged. Events aren't guaranteed to arrive in
+	// order, so a snapshot is only replaced by newer ones.
+	snapshotTime time.Time
+}
+
+func (l lifetime) Incarnation() Incarnation {
+	return Incarnation{UID: l.UID, CreationTime: l.CreationTime, DeletionTime: l.DeletionTime}
+}
+
+// observe records the times of the creation, update and deletion events of the incarnation, and
+// reports whether the event carries a snapshot that's newer than the latest one
+func (l *lifetime) observe(e ParsedEvent, created, updated, deleted EventType) bool {
+	switch e.Event {
+	case created:
+		if l.CreationTime.IsZero() || e.Timestamp.Before(l.CreationTime) {
+			l.CreationTime = e.Timestamp
+		}
+	case updated:
+		l.LastUpdatedTime = lo.Latest(l.LastUpdatedTime, e.Timestamp)
+	case deleted:
+		l.DeletionTime = lo.Latest(l.DeletionTime, e.Timestamp)
+	}
+	if e.Object == nil || e.Timestamp.Before(l.snapshotTime) {
+		return false
+	}
+	l.snapshotTime = e.Timestamp
+	return true
+}
+
+// incarnationState is the state that Coalesce builds up for a single incarnation of an object
+type incarnationState interface {
+	apply(ParsedEvent)
+	// adopt gives the state the UID of its incarnation, for states that were started from events
+	// that didn't identify it
+	adopt(types.UID)
+}
+
+func (l *lifetime) adopt(uid types.UID) {
+	l.UID = uid
+}
+
+// incarnations folds the events of a single object into one state per incarnation as they're
+// streamed. Events that don't identify their incarnation, like bindings, are applied to the latest
+// incarnation that was seen before them, or to the first incarnation when they're earlier than all
+// of them. Until an incarnation is seen, they're applied to a state that's adopted by the first one.
+type incarnations[T incarnationState] struct {
+	nn         types.NamespacedName
+	objectType ObjectType
+	newState   func(types.UID) T
+	states     map[types.UID]T
+	firstSeen  map[types.UID]time.Time
+}
+
+func newIncarnations[T incarnationState](nn types.NamespacedName, objectType ObjectType, newState func(types.UID) T) *incarnations[T] {
+	return &incarnations[T]{nn: nn, objectType: objectType, newState: newState, states: map[types.UID]T{}, firstSeen: map[types.UID]time.Time{}}
+}
+
+func (i *incarnations[T]) add(e ParsedEvent) {
+	if e.ObjectType != i.objectType || e.NamespaceName.String() != i.nn.String() {
+		return
+	}
+	if e.UID == "" {
+		i.stateAt(e.Timestamp).apply(e)
+		return
+	}
+	if _, ok := i.states[e.UID]; !ok {
+		if pending, ok := i.states[""]; ok {
+			delete(i.states, "")
+			pending.adopt(e.UID)
+			i.states[e.UID] = pending
+		} else {
+			i.states[e.UID] = i.newState(e.UID)
+		}
+	}
+	if t, ok := i.firstSeen[e.UID]; !ok || e.Timestamp.Before(t) {
+		i.firstSeen[e.UID] = e.Timestamp
+	}
+	i.states[e.UID].apply(e)
+}
+
+// stateAt returns the state of the latest incarnation that was seen at ts
+func (i *incarnations[T]) stateAt(ts time.Time) T {
+	if len(i.firstSeen) == 0 {
+		if _, ok := i.states[""]; !ok {
+			i.states[""] = i.newState("")
+		}
+		return i.states[""]
+	}
+	var latest, first types.UID
+	for uid, t := range i.firstSeen {
+		if first == "" || t.Before(i.firstSeen[first]) {
+			first = uid
+		}
+		if !t.After(ts) && (latest == "" || t.After(i.firstSeen[latest])) {
+			latest = uid
+		}
+	}
+	return i.states[lo.CoalesceOrEmpty(latest, first)]
+}
+
+// list returns the state of every incarnation, ordered by when each incarnation was first seen
+func (i *incarnations[T]) list() []T {
+	uids := lo.Keys(i.states)
+	sort.Slice(uids, func(a, b int) bool {
+		return i.firstSeen[uids[a]].Before(i.firstSeen[uids[b]])
+	})
+	return lo.Map(uids, func(uid types.UID, _ int) T { return i.states[uid] })
+}
+
+// coalesceIncarnations folds the events for nn into one state per incarnation, ordered by when each
+// incarnation was first seen
+func coalesceIncarnations[T incarnationState](nn types.NamespacedName, objectType ObjectType, events iter.Seq2[ParsedEvent, error], newState func(types.UID) T) ([]T, error) {
+	incarnations := newIncarnations(nn, objectType, newState)
+	for e, err := range events {
+		if err != nil {
+			return nil, err
+		}
+		incarnations.add(e)
+	}
+	return incarnations.list(), nil
+}
+
+// uidOf returns the UID of the object that an event refers to. It's taken from the objectRef when
+// it's set there, and otherwise from the response, which is either the object or, for some
+// deletes, a Status that carries the UID in its details.
+func uidOf(event auditmodel.Event) types.UID {
+	if event.ObjectRef != nil && event.ObjectRef.UID != "" {
+		return types.UID(event.ObjectRef.UID)
+	}
+	var response struct {
+		Metadata struct {
+			UID types.UID `json:"uid"`
+		} `json:"metadata"`
+		Details struct {
+			UID types.UID `json:"uid"`
+		} `json:"details"`
+	}
+	if isEmptyObject(event.ResponseObject) || json.Unmarshal(event.ResponseObject, &response) != nil {
+		return ""
+	}
+	return lo.CoalesceOrEmpty(response.Metadata.UID, response.Details.UID)
+}
+
+// SelectIncarnation picks a single incarnation out of objs, either by its UID or by its 1-based
+// position in the order that the incarnations were created. With neither set, objs must only hold
+// a single incarnation.
+func SelectIncarnation(objs []Object, uid types.UID, n int) (Object, error) {
+	switch {
+	case uid != "":
+		obj, ok := lo.Find(objs, func(o Object) bool { return o.Incarnation().UID == uid })
+		if !ok {
+			return nil, fmt.Errorf("no incarnation with uid %s", uid)
+		}
+		return obj, nil
+	case n != 0:
+		if n < 1 || n > len(objs) {
+			return nil, fmt.Errorf("incarnation %d is out of range, found %d incarnation(s)", n, len(objs))
+		}
+		return objs[n-1], nil
+	case len(objs) != 1:
+		return nil, fmt.Errorf("found %d incarnations, select one with --uid or --incarnation", len(objs))
+	}
+	return objs[0], nil
+}
+
+// FormatIncarnations renders a table of the incarnations of an object
+func FormatIncarnations(objs []Object) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "INCARNATION\tUID\tCREATED\tDELETED")
+	for i, o := range objs {
+		inc := o.Incarnation()
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, lo.CoalesceOrEmpty(string(inc.UID), "<unknown>"), formatTime(inc.CreationTime), formatTime(inc.DeletionTime))
+	}
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+func formatTime(t time.Time) string {
+	return lo.Ternary(t.IsZero(), "N/A", t.UTC().Format(time.RFC3339))
+}
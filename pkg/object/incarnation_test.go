@@ -0,0 +1,184 @@
+package object
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func boundEvent(node string, at time.Time) ParsedEvent {
+	return ParsedEvent{
+		Timestamp:            at,
+		NamespaceName:        atNN,
+		ObjectType:           ObjectTypePod,
+		Event:                EventTypePodBound,
+		AdditionalProperties: map[string]string{"NodeName": node},
+	}
+}
+
+func TestCoalesceIncarnations(t *testing.T) {
+	minute := func(m int) time.Time { return atTime.Add(time.Duration(m) * time.Minute) }
+	type want struct {
+		uid     types.UID
+		node    string
+		created time.Time
+		deleted time.Time
+	}
+	for _, tc := range []struct {
+		name   string
+		events []ParsedEvent
+		want   []want
+	}{
+		{
+			name: "deleted and created again with the same name",
+			events: []ParsedEvent{
+				podEvent("create", "a", minute(0), true),
+				podEvent("delete", "a", minute(5), true),
+				podEvent("create", "b", minute(6), true),
+			},
+			want: []want{{uid: "a", created: minute(0), deleted: minute(5)}, {uid: "b", created: minute(6)}},
+		},
+		{
+			name: "incarnations ordered by when they're first seen rather than by arrival",
+			events: []ParsedEvent{
+				podEvent("create", "b", minute(6), true),
+				podEvent("delete", "a", minute(5), true),
+				podEvent("create", "a", minute(0), true),
+			},
+			want: []want{{uid: "a", created: minute(0), deleted: minute(5)}, {uid: "b", created: minute(6)}},
+		},
+		{
+			name: "events without a UID applied to the incarnation seen before them",
+			events: []ParsedEvent{
+				podEvent("create", "a", minute(0), true),
+				boundEvent("node-a", minute(1)),
+				podEvent("delete", "a", minute(5), true),
+				podEvent("create", "b", minute(6), true),
+				boundEvent("node-b", minute(7)),
+			},
+			want: []want{{uid: "a", node: "node-a", created: minute(0), deleted: minute(5)}, {uid: "b", node: "node-b", created: minute(6)}},
+		},
+		{
+			name: "events without a UID that arrive after a later incarnation",
+			events: []ParsedEvent{
+				podEvent("create", "a", minute(0), true),
+				podEvent("create", "b", minute(6), true),
+				boundEvent("node-b", minute(7)),
+				boundEvent("node-a", minute(1)),
+			},
+			want: []want{{uid: "a", node: "node-a", created: minute(0)}, {uid: "b", node: "node-b", created: minute(6)}},
+		},
+		{
+			name: "events without a UID before every incarnation",
+			events: []ParsedEvent{
+				podEvent("create", "a", minute(2), true),
+				boundEvent("node-a", minute(1)),
+			},
+			want: []want{{uid: "a", node: "node-a", created: minute(2)}},
+		},
+		{
+			name: "events without a UID adopted by the first incarnation",
+			events: []ParsedEvent{
+				boundEvent("node-a", minute(1)),
+				podEvent("update", "a", minute(2), true),
+			},
+			want: []want{{uid: "a", node: "node-a"}},
+		},
+		{
+			name:   "only events without a UID",
+			events: []ParsedEvent{boundEvent("node-a", minute(1))},
+			want:   []want{{node: "node-a"}},
+		},
+		{
+			name: "events of other objects",
+			events: []ParsedEvent{
+				podEvent("create", "a", minute(0), true),
+				func() ParsedEvent {
+					e := podEvent("create", "c", minute(1), true)
+					e.NamespaceName = types.NamespacedName{Namespace: "default", Name: "api"}
+					return e
+				}(),
+				func() ParsedEvent {
+					e := podEvent("create", "d", minute(1), true)
+					e.ObjectType = ObjectTypeNode
+					return e
+				}(),
+			},
+			want: []want{{uid: "a", created: minute(0)}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pods, err := coalesceIncarnations(atNN, ObjectTypePod, seqOf(tc.events...), newPodState(atNN))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pods) != len(tc.want) {
+				t.Fatalf("got %d incarnations, want %d", len(pods), len(tc.want))
+			}
+			for i, w := range tc.want {
+				got := pods[i]
+				if got.UID != w.uid || got.NodeName != w.node || !got.CreationTime.Equal(w.created) || !got.DeletionTime.Equal(w.deleted) {
+					t.Errorf("incarnation %d: got UID %q on %q created at %s deleted at %s, want UID %q on %q created at %s deleted at %s",
+						i+1, got.UID, got.NodeName, got.CreationTime, got.DeletionTime, w.uid, w.node, w.created, w.deleted)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectIncarnation(t *testing.T) {
+	objs := []Object{
+		Pod{lifetime: lifetime{NamespaceName: atNN, UID: "a"}},
+		Pod{lifetime: lifetime{NamespaceName: atNN, UID: "b"}},
+	}
+	for _, tc := range []struct {
+		name    string
+		objs    []Object
+		uid     types.UID
+		n       int
+		wantUID types.UID
+		wantErr string
+	}{
+		{name: "by uid", objs: objs, uid: "b", wantUID: "b"},
+		{name: "by position", objs: objs, n: 1, wantUID: "a"},
+		{name: "last position", objs: objs, n: 2, wantUID: "b"},
+		{name: "uid over position", objs: objs, uid: "a", n: 2, wantUID: "a"},
+		{name: "unknown uid", objs: objs, uid: "c", wantErr: "no incarnation with uid c"},
+		{name: "position past the last", objs: objs, n: 3, wantErr: "incarnation 3 is out of range, found 2 incarnation(s)"},
+		{name: "negative position", objs: objs, n: -1, wantErr: "incarnation -1 is out of range"},
+		{name: "several incarnations without a selection", objs: objs, wantErr: "found 2 incarnations, select one with --uid or --incarnation"},
+		{name: "no incarnations", wantErr: "found 0 incarnations"},
+		{name: "single incarnation", objs: objs[:1], wantUID: "a"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obj, err := SelectIncarnation(tc.objs, tc.uid, tc.n)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := obj.Incarnation().UID; got != tc.wantUID {
+				t.Errorf("got incarnation %q, want %q", got, tc.wantUID)
+			}
+		})
+	}
+}
+
+func TestFormatIncarnations(t *testing.T) {
+	got := FormatIncarnations([]Object{
+		Pod{lifetime: lifetime{NamespaceName: atNN, UID: "a", CreationTime: atTime, DeletionTime: atTime.Add(5 * time.Minute)}},
+		Pod{lifetime: lifetime{NamespaceName: atNN, CreationTime: atTime.Add(6 * time.Minute)}},
+	})
+	want := "INCARNATION   UID         CREATED                DELETED\n" +
+		"1             a           2025-09-15T16:00:00Z   2025-09-15T16:05:00Z\n" +
+		"2             <unknown>   2025-09-15T16:06:00Z   N/A\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
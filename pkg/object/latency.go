@@ -0,0 +1,136 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LatencyGroups are what pod latencies can be grouped by for their percentiles
+var LatencyGroups = []string{"namespace", "node", "owner"}
+
+// PodLatency is how long a pod took to be scheduled and to start. Each duration is nil when one of
+// the events that it's measured between wasn't logged in the time window.
+type PodLatency struct {
+	Pod Pod
+	// Scheduling is the time from the pod's creation to its binding to a node
+	Scheduling *time.Duration
+	// Startup is the time from the pod's binding to it first becoming ready
+	Startup *time.Duration
+	// EndToEnd is the time from the pod's creation to it first becoming ready
+	EndToEnd *time.Duration
+}
+
+func NewPodLatency(p Pod) PodLatency {
+	return PodLatency{
+		Pod:        p,
+		Scheduling: between(p.CreationTime, p.BindTime),
+		Startup:    between(p.BindTime, p.ReadyTime),
+		EndToEnd:   between(p.CreationTime, p.ReadyTime),
+	}
+}
+
+func between(from, to time.Time) *time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return nil
+	}
+	return lo.ToPtr(to.Sub(from))
+}
+
+// group returns the namespace, node or controller of the pod that its latencies are grouped by
+func (l PodLatency) group(by string) string {
+	switch by {
+	case "node":
+		return lo.CoalesceOrEmpty(l.Pod.node(), "<none>")
+	case "owner":
+		if l.Pod.Pod == nil {
+			return "<unknown>"
+		}
+		if owner := metav1.GetControllerOf(l.Pod.Pod); owner != nil {
+			return strings.ToLower(owner.Kind) + "/" + owner.Name
+		}
+		return "<none>"
+	default:
+		return l.Pod.NamespaceName.Namespace
+	}
+}
+
+// percentile returns the percentile of sorted durations, interpolated linearly between the two
+// durations that it falls between
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower, upper := int(math.Floor(rank)), int(math.Ceil(rank))
+	return sorted[lower] + time.Duration(math.Round(float64(sorted[upper]-sorted[lower])*(rank-float64(lower))))
+}
+
+// FormatLatencies renders a table of the latencies of each pod, with their namespace when they were
+// listed across namespaces, followed by the p50, p90 and p99 of each latency in each group. Pods
+// that are missing a latency aren't counted in its percentiles.
+func FormatLatencies(latencies []PodLatency, withNamespace bool, groupBy string) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	header := []string{"NAME", "NODE", "CREATED", "SCHEDULING", "STARTUP", "END-TO-END"}
+	if withNamespace {
+		header = slices.Insert(header, 0, "NAMESPACE")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, l := range latencies {
+		row := []string{l.Pod.NamespaceName.Name, lo.CoalesceOrEmpty(l.Pod.node(), "-"), formatTime(l.Pod.CreationTime), formatDuration(l.Scheduling), formatDuration(l.Startup), formatDuration(l.EndToEnd)}
+		if withNamespace {
+			row = slices.Insert(row, 0, l.Pod.NamespaceName.Namespace)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	lo.Must0(w.Flush())
+
+	fmt.Fprintln(buf)
+	w = tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintf(w, "%s\tLATENCY\tPODS\tP50\tP90\tP99\tMAX\n", strings.ToUpper(groupBy))
+	grouped := lo.GroupBy(latencies, func(l PodLatency) string { return l.group(groupBy) })
+	groups := lo.Keys(grouped)
+	sort.Strings(groups)
+	for _, g := range groups {
+		for _, m := range []struct {
+			name     string
+			duration func(PodLatency) *time.Duration
+		}{
+			{"scheduling", func(l PodLatency) *time.Duration { return l.Scheduling }},
+			{"startup", func(l PodLatency) *time.Duration { return l.Startup }},
+			{"end-to-end", func(l PodLatency) *time.Duration { return l.EndToEnd }},
+		} {
+			durations := lo.FilterMap(grouped[g], func(l PodLatency, _ int) (time.Duration, bool) {
+				d := m.duration(l)
+				return lo.FromPtr(d), d != nil
+			})
+			if len(durations) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t0\t-\t-\t-\t-\n", g, m.name)
+				continue
+			}
+			slices.Sort(durations)
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", g, m.name, len(durations),
+				formatDuration(lo.ToPtr(percentile(durations, 50))),
+				formatDuration(lo.ToPtr(percentile(durations, 90))),
+				formatDuration(lo.ToPtr(percentile(durations, 99))),
+				formatDuration(lo.ToPtr(durations[len(durations)-1])),
+			)
+		}
+	}
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+// formatDuration prints a duration rounded to milliseconds, or "-" when it's unknown
+func formatDuration(d *time.Duration) string {
+	if d == nil {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,127 @@
+package object
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPercentile(t *testing.T) {
+	seconds := func(s ...int) []time.Duration {
+		var durations []time.Duration
+		for _, n := range s {
+			durations = append(durations, time.Duration(n)*time.Second)
+		}
+		return durations
+	}
+	for _, tc := range []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{name: "p50 of 1 sample", sorted: seconds(7), p: 50, want: 7 * time.Second},
+		{name: "p99 of 1 sample", sorted: seconds(7), p: 99, want: 7 * time.Second},
+		{name: "p0 of 2 samples", sorted: seconds(1, 3), p: 0, want: time.Second},
+		{name: "p50 of 2 samples", sorted: seconds(1, 3), p: 50, want: 2 * time.Second},
+		{name: "p90 of 2 samples", sorted: seconds(1, 3), p: 90, want: 2800 * time.Millisecond},
+		{name: "p100 of 2 samples", sorted: seconds(1, 3), p: 100, want: 3 * time.Second},
+		{name: "p50 of an odd number of samples", sorted: seconds(1, 2, 3, 4, 100), p: 50, want: 3 * time.Second},
+		{name: "p50 of an even number of samples", sorted: seconds(1, 2, 3, 4), p: 50, want: 2500 * time.Millisecond},
+		{name: "p90 of N samples", sorted: seconds(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11), p: 90, want: 10 * time.Second},
+		{name: "p99 of N samples", sorted: seconds(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11), p: 99, want: 10900 * time.Millisecond},
+		{name: "p99 pulled toward an outlier", sorted: seconds(1, 1, 1, 101), p: 99, want: 98 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := percentile(tc.sorted, tc.p); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewPodLatency(t *testing.T) {
+	minute := func(m int) time.Time { return atTime.Add(time.Duration(m) * time.Minute) }
+	for _, tc := range []struct {
+		name                        string
+		created, bound, ready       time.Time
+		wantScheduling, wantStartup *time.Duration
+		wantEndToEnd                *time.Duration
+	}{
+		{
+			name: "every timestamp", created: minute(0), bound: minute(1), ready: minute(3),
+			wantScheduling: lo.ToPtr(time.Minute), wantStartup: lo.ToPtr(2 * time.Minute), wantEndToEnd: lo.ToPtr(3 * time.Minute),
+		},
+		{
+			name: "never bound", created: minute(0),
+		},
+		{
+			name: "bound but never ready", created: minute(0), bound: minute(1),
+			wantScheduling: lo.ToPtr(time.Minute),
+		},
+		{
+			name: "ready without a logged binding", created: minute(0), ready: minute(3),
+			wantEndToEnd: lo.ToPtr(3 * time.Minute),
+		},
+		{
+			name: "created before the time window", bound: minute(1), ready: minute(3),
+			wantStartup: lo.ToPtr(2 * time.Minute),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewPodLatency(Pod{lifetime: lifetime{NamespaceName: atNN, CreationTime: tc.created}, BindTime: tc.bound, ReadyTime: tc.ready})
+			for _, d := range []struct {
+				name      string
+				got, want *time.Duration
+			}{
+				{"scheduling", l.Scheduling, tc.wantScheduling},
+				{"startup", l.Startup, tc.wantStartup},
+				{"end-to-end", l.EndToEnd, tc.wantEndToEnd},
+			} {
+				if formatDuration(d.got) != formatDuration(d.want) {
+					t.Errorf("got %s latency %s, want %s", d.name, formatDuration(d.got), formatDuration(d.want))
+				}
+			}
+		})
+	}
+}
+
+func TestFormatLatencies(t *testing.T) {
+	pod := func(name, node string, created, bound, ready time.Duration) PodLatency {
+		at := func(d time.Duration) time.Time { return lo.Ternary(d < 0, time.Time{}, atTime.Add(d)) }
+		return NewPodLatency(Pod{
+			Pod:       &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}},
+			lifetime:  lifetime{NamespaceName: types.NamespacedName{Namespace: "default", Name: name}, CreationTime: at(created)},
+			NodeName:  node,
+			BindTime:  at(bound),
+			ReadyTime: at(ready),
+		})
+	}
+	got := FormatLatencies([]PodLatency{
+		pod("web-1", "node-a", 0, time.Second, 3*time.Second),
+		pod("web-2", "node-a", 0, 3*time.Second, 7*time.Second),
+		// Neither bound nor ready, so it's only listed
+		pod("web-3", "", 0, -1, -1),
+	}, false, "namespace")
+	for _, want := range []string{
+		"web-3   -        2025-09-15T16:00:00Z   -            -         -\n",
+		"default     scheduling   2      2s    2.8s   2.98s   3s\n",
+		"default     startup      2      3s    3.8s   3.98s   4s\n",
+		"default     end-to-end   2      5s    6.6s   6.96s   7s\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, got)
+		}
+	}
+
+	// A group without any pod that was bound has no percentiles
+	got = FormatLatencies([]PodLatency{pod("web-3", "", 0, -1, -1)}, false, "node")
+	if want := "<none>   scheduling   0      -     -     -     -\n"; !strings.Contains(got, want) {
+		t.Errorf("output doesn't contain %q:\n%s", want, got)
+	}
+}
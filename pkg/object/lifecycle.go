@@ -0,0 +1,114 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Transition is a change in the lifecycle of an object
+type Transition struct {
+	Timestamp time.Time
+	Event     string
+	Details   string
+}
+
+func sortTransitions(transitions []Transition) {
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].Timestamp.Before(transitions[j].Timestamp)
+	})
+}
+
+// FormatTransitions renders a table of the lifecycle of an object
+func FormatTransitions(transitions []Transition) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "TIMESTAMP\tEVENT\tDETAILS")
+	for _, t := range transitions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", formatTime(t.Timestamp), t.Event, lo.CoalesceOrEmpty(t.Details, "-"))
+	}
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+// withLifetime returns the transitions of an incarnation along with its creation and deletion, in
+// order of time
+func withLifetime(l lifetime, transitions []Transition, deleted Transition) []Transition {
+	var all []Transition
+	if !l.CreationTime.IsZero() {
+		all = append(all, Transition{Timestamp: l.CreationTime, Event: "Created"})
+	}
+	all = append(all, transitions...)
+	if !l.DeletionTime.IsZero() {
+		deleted.Timestamp, deleted.Event = l.DeletionTime, "Deleted"
+		all = append(all, deleted)
+	}
+	sortTransitions(all)
+	return all
+}
+
+// reorderWindow is how many events a reorderBuffer holds back before folding the oldest of them
+const reorderWindow = 32
+
+// reorderBuffer puts the events of an incarnation in order of time as they're streamed, so that each
+// snapshot can be folded into the incarnation's history against the one before it without holding on
+// to all of them. Providers don't all stream events in order, so the latest reorderWindow events are
+// held back, and an event that's older than one that was already folded is left out of the history.
+// Events without a snapshot, like writes to the scale subresource, come before a snapshot logged at
+// the same time, which already shows them.
+type reorderBuffer struct {
+	pending []ParsedEvent
+	// folded is when the latest event that was folded was logged
+	folded time.Time
+}
+
+func (b *reorderBuffer) push(e ParsedEvent, fold func(ParsedEvent)) {
+	if !b.folded.IsZero() && e.Timestamp.Before(b.folded) {
+		return
+	}
+	i := sort.Search(len(b.pending), func(i int) bool {
+		p := b.pending[i]
+		return e.Timestamp.Before(p.Timestamp) || (e.Timestamp.Equal(p.Timestamp) && e.Object == nil && p.Object != nil)
+	})
+	b.pending = slices.Insert(b.pending, i, e)
+	if len(b.pending) > reorderWindow {
+		b.pop(fold)
+	}
+}
+
+// flush folds the events that are still held back
+func (b *reorderBuffer) flush(fold func(ParsedEvent)) {
+	for len(b.pending) > 0 {
+		b.pop(fold)
+	}
+}
+
+func (b *reorderBuffer) pop(fold func(ParsedEvent)) {
+	e := b.pending[0]
+	b.pending = slices.Delete(b.pending, 0, 1)
+	b.folded = e.Timestamp
+	fold(e)
+}
+
+// divertEvents passes the events through, except for the events of objectType, which are handed to
+// divert one at a time as the sequence is consumed. Objects whose description relies on other
+// objects share the stream with those objects' events, and fold them into their own state.
+func divertEvents(events iter.Seq2[ParsedEvent, error], objectType ObjectType, divert func(ParsedEvent)) iter.Seq2[ParsedEvent, error] {
+	return func(yield func(ParsedEvent, error) bool) {
+		for e, err := range events {
+			if err == nil && e.ObjectType == objectType {
+				divert(e)
+				continue
+			}
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	at := func(minutes int) time.Time { return atTime.Add(time.Duration(minutes) * time.Minute) }
+	scaled := func(minutes int) ParsedEvent {
+		return ParsedEvent{Timestamp: at(minutes), Event: EventTypeScaled}
+	}
+	snapshot := func(minutes int) ParsedEvent {
+		return podEvent("update", "uid-1", at(minutes), true)
+	}
+	// Events that fill the window after an event, so that it is folded before an older one arrives
+	late := make([]ParsedEvent, reorderWindow)
+	for i := range late {
+		late[i] = snapshot(10 + i)
+	}
+	for _, tc := range []struct {
+		name   string
+		events []ParsedEvent
+		want   []string
+	}{
+		{
+			name:   "out of order",
+			events: []ParsedEvent{snapshot(2), snapshot(0), snapshot(1)},
+			want:   []string{"0 snapshot", "1 snapshot", "2 snapshot"},
+		},
+		{
+			name:   "scale logged at the time of a snapshot",
+			events: []ParsedEvent{snapshot(1), scaled(1), snapshot(0)},
+			want:   []string{"0 snapshot", "1 scale", "1 snapshot"},
+		},
+		{
+			name:   "older than a folded event",
+			events: append(append([]ParsedEvent{snapshot(1)}, late...), snapshot(2), snapshot(0)),
+			want: append([]string{"1 snapshot", "2 snapshot"}, lo.Map(late, func(e ParsedEvent, _ int) string {
+				return fmt.Sprintf("%d snapshot", int(e.Timestamp.Sub(atTime).Minutes()))
+			})...),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			fold := func(e ParsedEvent) {
+				got = append(got, fmt.Sprintf("%d %s", int(e.Timestamp.Sub(atTime).Minutes()), lo.Ternary(e.Object == nil, "scale", "snapshot")))
+			}
+			b := &reorderBuffer{}
+			for _, e := range tc.events {
+				b.push(e, fold)
+			}
+			if len(b.pending) > reorderWindow {
+				t.Errorf("holds back %d events, more than %d", len(b.pending), reorderWindow)
+			}
+			b.flush(fold)
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("folded %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// podFields are the fields that pods can be listed by, like kubectl's field selectors
+var podFields = sets.New("metadata.name", "metadata.namespace", "spec.nodeName", "status.phase")
+
+// PodFilter selects the pods to list. Selectors match the latest logged state of a pod, except for
+// spec.nodeName, which also matches the node that the pod was bound to.
+type PodFilter struct {
+	LabelSelector labels.Selector
+	FieldSelector fields.Selector
+	// Evicted only keeps pods that were evicted
+	Evicted bool
+	// Deleted only keeps pods that were deleted
+	Deleted bool
+}
+
+// NewPodFilter parses the label and field selectors of a PodFilter. Field selectors are limited to
+// the fields that can be worked out from the audit log.
+func NewPodFilter(labelSelector, fieldSelector string, evicted, deleted bool) (PodFilter, error) {
+	ls, err := labels.Parse(labelSelector)
+	if err != nil {
+		return PodFilter{}, fmt.Errorf("parsing label selector, %w", err)
+	}
+	fs, err := fields.ParseSelector(fieldSelector)
+	if err != nil {
+		return PodFilter{}, fmt.Errorf("parsing field selector, %w", err)
+	}
+	for _, r := range fs.Requirements() {
+		if !podFields.Has(r.Field) {
+			return PodFilter{}, fmt.Errorf("field selector %q isn't supported, supported fields are %v", r.Field, sets.List(podFields))
+		}
+	}
+	return PodFilter{LabelSelector: ls, FieldSelector: fs, Evicted: evicted, Deleted: deleted}, nil
+}
+
+func (f PodFilter) Matches(p Pod) bool {
+	if f.Evicted && p.EvictionTime.IsZero() || f.Deleted && p.DeletionTime.IsZero() {
+		return false
+	}
+	if f.LabelSelector != nil && !f.LabelSelector.Empty() && (p.Pod == nil || !f.LabelSelector.Matches(labels.Set(p.Pod.Labels))) {
+		return false
+	}
+	return f.FieldSelector == nil || f.FieldSelector.Matches(fields.Set{
+		"metadata.name":      p.NamespaceName.Name,
+		"metadata.namespace": p.NamespaceName.Namespace,
+		"spec.nodeName":      p.node(),
+		"status.phase":       string(p.phase()),
+	})
+}
+
+// ListPods reconstructs every pod in a stream of events and returns the incarnations that match
+// the filter, ordered by namespace, name and creation. Events are folded into the state of their pod
+// as they're streamed, so only the latest state of each pod is held in memory.
+func ListPods(events iter.Seq2[ParsedEvent, error], filter PodFilter) ([]Pod, error) {
+	pods, _, err := listPods(events, filter, false)
+	return pods, err
+}
+
+// PodEvents returns the events of the pod incarnations that match the filter. Events without a UID
+// are kept when any incarnation of their pod matches. Whether a pod matches is only known from its
+// latest state, so the events of every pod are held until the stream ends, without the objects that
+// they logged.
+func PodEvents(events iter.Seq2[ParsedEvent, error], filter PodFilter) ([]ParsedEvent, error) {
+	pods, grouped, err := listPods(events, filter, true)
+	if err != nil {
+		return nil, err
+	}
+	uids := sets.New(lo.Map(pods, func(p Pod, _ int) types.UID { return p.UID })...)
+	var matched []ParsedEvent
+	for _, nn := range lo.Uniq(lo.Map(pods, func(p Pod, _ int) types.NamespacedName { return p.NamespaceName })) {
+		matched = append(matched, lo.Filter(grouped[nn], func(e ParsedEvent, _ int) bool { return e.UID == "" || uids.Has(e.UID) })...)
+	}
+	return matched, nil
+}
+
+// listPods returns the pods that match the filter, along with the events of every pod by name when
+// withEvents is set
+func listPods(events iter.Seq2[ParsedEvent, error], filter PodFilter, withEvents bool) ([]Pod, map[types.NamespacedName][]ParsedEvent, error) {
+	byName := map[types.NamespacedName]*incarnations[*Pod]{}
+	grouped := map[types.NamespacedName][]ParsedEvent{}
+	for e, err := range events {
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.ObjectType != ObjectTypePod {
+			continue
+		}
+		if _, ok := byName[e.NamespaceName]; !ok {
+			byName[e.NamespaceName] = newIncarnations(e.NamespaceName, ObjectTypePod, newPodState(e.NamespaceName))
+		}
+		byName[e.NamespaceName].add(e)
+		if withEvents {
+			// The logged objects are most of the size of an event, and aren't needed to show it
+			e.Object, e.Patch = nil, nil
+			grouped[e.NamespaceName] = append(grouped[e.NamespaceName], e)
+		}
+	}
+	names := lo.Keys(byName)
+	sort.Slice(names, func(i, j int) bool { return names[i].String() < names[j].String() })
+	var pods []Pod
+	for _, nn := range names {
+		for _, p := range byName[nn].list() {
+			if filter.Matches(*p) {
+				pods = append(pods, *p)
+			}
+		}
+	}
+	return pods, grouped, nil
+}
+
+// FormatPods renders a table of pods, with their namespace when they were listed across namespaces.
+// The wide table adds each pod's UID and when it was last updated and evicted.
+func FormatPods(pods []Pod, withNamespace, wide bool) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	header := []string{"NAME", "NODE", "CREATED", "BOUND", "DELETED", "LAST-PHASE"}
+	if withNamespace {
+		header = slices.Insert(header, 0, "NAMESPACE")
+	}
+	if wide {
+		header = append(header, "UID", "LAST-UPDATED", "EVICTED")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, p := range pods {
+		row := []string{p.NamespaceName.Name, lo.CoalesceOrEmpty(p.node(), "-"), formatTime(p.CreationTime), formatTime(p.BindTime), formatTime(p.DeletionTime), lo.CoalesceOrEmpty(string(p.phase()), "-")}
+		if withNamespace {
+			row = slices.Insert(row, 0, p.NamespaceName.Namespace)
+		}
+		if wide {
+			row = append(row, lo.CoalesceOrEmpty(string(p.UID), "N/A"), formatTime(p.LastUpdatedTime), formatTime(p.EvictionTime))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+// FormatWide renders a one row table of an object. Pods get the wide table of get pods, and every
+// other object its UID and when it was created and deleted.
+func FormatWide(nn types.NamespacedName, obj Object) string {
+	if p, ok := obj.(Pod); ok {
+		return FormatPods([]Pod{p}, false, true)
+	}
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUID\tCREATED\tDELETED")
+	incarnation := obj.Incarnation()
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", nn.Name, lo.CoalesceOrEmpty(string(incarnation.UID), "N/A"), formatTime(incarnation.CreationTime), formatTime(incarnation.DeletionTime))
+	lo.Must0(w.Flush())
+	return buf.String()
+}
@@ -0,0 +1,189 @@
+package object
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// listedPodEvent is an event of the pod name in the default namespace, logged with its labels and phase
+func listedPodEvent(verb, name string, uid types.UID, minutes int, labels map[string]string, phase v1.PodPhase) ParsedEvent {
+	nn := types.NamespacedName{Namespace: "default", Name: name}
+	return ParsedEvent{
+		Timestamp:     atTime.Add(time.Duration(minutes) * time.Minute),
+		NamespaceName: nn,
+		UID:           uid,
+		Verb:          verb,
+		ObjectType:    ObjectTypePod,
+		Event:         map[string]EventType{"create": EventTypePodCreated, "update": EventTypePodUpdated, "delete": EventTypePodDeleted}[verb],
+		Object: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: nn.Namespace, Name: name, UID: uid, Labels: labels},
+			Status:     v1.PodStatus{Phase: phase},
+		},
+	}
+}
+
+func listedPodBound(name, node string, minutes int) ParsedEvent {
+	return ParsedEvent{
+		Timestamp:            atTime.Add(time.Duration(minutes) * time.Minute),
+		NamespaceName:        types.NamespacedName{Namespace: "default", Name: name},
+		ObjectType:           ObjectTypePod,
+		Event:                EventTypePodBound,
+		AdditionalProperties: map[string]string{"NodeName": node},
+	}
+}
+
+func TestNewPodFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		labelSelector string
+		fieldSelector string
+		wantErr       string
+	}{
+		{name: "empty"},
+		{name: "label selector", labelSelector: "app=web,tier in (frontend,backend),!canary"},
+		{name: "supported fields", fieldSelector: "metadata.name=web,metadata.namespace=default,spec.nodeName=node-a,status.phase!=Running"},
+		{name: "invalid label selector", labelSelector: "app in (web", wantErr: "parsing label selector"},
+		{name: "invalid field selector", fieldSelector: "spec.nodeName", wantErr: "parsing field selector"},
+		{name: "unsupported field", fieldSelector: "spec.restartPolicy=Always", wantErr: `field selector "spec.restartPolicy" isn't supported`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewPodFilter(tc.labelSelector, tc.fieldSelector, false, false)
+			if tc.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestListPods(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	events := []ParsedEvent{
+		listedPodEvent("create", "web-1", "uid-1", 0, web, v1.PodPending),
+		listedPodBound("web-1", "node-a", 1),
+		listedPodEvent("update", "web-1", "uid-1", 2, web, v1.PodRunning),
+		listedPodEvent("create", "web-2", "uid-2", 0, web, v1.PodPending),
+		listedPodBound("web-2", "node-b", 1),
+		listedPodEvent("update", "web-2", "uid-2", 2, web, v1.PodRunning),
+		// The pod is relabeled out of the app and back into it
+		listedPodEvent("create", "relabeled-in", "uid-3", 0, nil, v1.PodPending),
+		listedPodEvent("update", "relabeled-in", "uid-3", 2, web, v1.PodPending),
+		listedPodEvent("update", "relabeled-out", "uid-4", 2, map[string]string{"app": "api"}, v1.PodPending),
+		listedPodEvent("create", "relabeled-out", "uid-4", 0, web, v1.PodPending),
+		listedPodEvent("create", "db", "uid-5", 0, map[string]string{"app": "db"}, v1.PodFailed),
+		listedPodBound("db", "node-a", 1),
+		listedPodEvent("delete", "db", "uid-5", 3, map[string]string{"app": "db"}, v1.PodFailed),
+		// The pod is re-created with the same name, and only its second incarnation is running
+		listedPodEvent("create", "db", "uid-6", 4, map[string]string{"app": "db"}, v1.PodRunning),
+		listedPodBound("db", "node-b", 5),
+	}
+	for _, tc := range []struct {
+		name          string
+		labelSelector string
+		fieldSelector string
+		deleted       bool
+		want          []string
+	}{
+		{
+			name: "every pod",
+			want: []string{"db uid-5", "db uid-6", "relabeled-in uid-3", "relabeled-out uid-4", "web-1 uid-1", "web-2 uid-2"},
+		},
+		{
+			name:          "label selector on the latest labels",
+			labelSelector: "app=web",
+			want:          []string{"relabeled-in uid-3", "web-1 uid-1", "web-2 uid-2"},
+		},
+		{
+			name:          "label selector without a label",
+			labelSelector: "!app",
+		},
+		{
+			name:          "set-based label selector",
+			labelSelector: "app in (api,db)",
+			want:          []string{"db uid-5", "db uid-6", "relabeled-out uid-4"},
+		},
+		{
+			name:          "node from the binding",
+			fieldSelector: "spec.nodeName=node-a",
+			want:          []string{"db uid-5", "web-1 uid-1"},
+		},
+		{
+			name:          "node of an incarnation",
+			fieldSelector: "spec.nodeName=node-b",
+			want:          []string{"db uid-6", "web-2 uid-2"},
+		},
+		{
+			name:          "pods that were never bound",
+			fieldSelector: "spec.nodeName=",
+			want:          []string{"relabeled-in uid-3", "relabeled-out uid-4"},
+		},
+		{
+			name:          "phase of the latest state",
+			fieldSelector: "status.phase=Running",
+			want:          []string{"db uid-6", "web-1 uid-1", "web-2 uid-2"},
+		},
+		{
+			name:          "labels and fields",
+			labelSelector: "app=db",
+			fieldSelector: "status.phase!=Running",
+			want:          []string{"db uid-5"},
+		},
+		{
+			name:    "deleted",
+			deleted: true,
+			want:    []string{"db uid-5"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := NewPodFilter(tc.labelSelector, tc.fieldSelector, false, tc.deleted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pods, err := ListPods(seqOf(events...), filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := lo.Map(pods, func(p Pod, _ int) string { return p.NamespaceName.Name + " " + string(p.UID) })
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPodEvents(t *testing.T) {
+	filter, err := NewPodFilter("", "spec.nodeName=node-b", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := PodEvents(seqOf(
+		listedPodEvent("create", "db", "uid-5", 0, nil, v1.PodPending),
+		listedPodBound("db", "node-a", 1),
+		listedPodEvent("delete", "db", "uid-5", 3, nil, v1.PodPending),
+		listedPodEvent("create", "db", "uid-6", 4, nil, v1.PodPending),
+		listedPodBound("db", "node-b", 5),
+		listedPodEvent("create", "web", "uid-1", 0, nil, v1.PodPending),
+	), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The events of the first incarnation are dropped, except for the binding that doesn't have a UID
+	got := lo.Map(events, func(e ParsedEvent, _ int) string { return string(e.Event) + " " + string(e.UID) })
+	want := []string{"PodBound ", "PodCreated uid-6", "PodBound "}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, e := range events {
+		if e.Object != nil {
+			t.Errorf("%s event at %s kept its object", e.Event, e.Timestamp)
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package object
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"iter"
+	"slices"
 	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
 
@@ -20,90 +26,289 @@ const (
 )
 
 type Node struct {
-	Node            *v1.Node
-	NamespaceName   types.NamespacedName
-	CreationTime    time.Time
-	LastUpdatedTime time.Time
-	DeletionTime    time.Time
+	Node *v1.Node
+	lifetime
+
+	// Lifecycle is the node's lifecycle, oldest first
+	Lifecycle []Transition
+	// Pods are the pods that were bound to the node, in order of binding
+	Pods []BoundPod
+
+	// reorder holds back the latest snapshots to fold them into transitions in order
+	reorder reorderBuffer
+	// prev is the latest snapshot that was folded into transitions
+	prev        *v1.Node
+	transitions []Transition
+}
+
+// BoundPod is a pod that was bound to a node
+type BoundPod struct {
+	NamespaceName types.NamespacedName
+	UID           types.UID
+	BindTime      time.Time `json:",omitzero"`
+	DeletionTime  time.Time `json:",omitzero"`
 }
 
 func (n Node) Describe() string {
-	panic("implement me")
+	pods := &bytes.Buffer{}
+	w := tabwriter.NewWriter(pods, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "POD\tUID\tBOUND\tDELETED")
+	for _, p := range n.Pods {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.NamespaceName, lo.CoalesceOrEmpty(string(p.UID), "N/A"), formatTime(p.BindTime), formatTime(p.DeletionTime))
+	}
+	lo.Must0(w.Flush())
+
+	return fmt.Sprintf(`
+%s
+%s
+UID: %s
+NodeClaim: %s
+
+CreationTime: %s
+LastUpdatedTime: %s
+DeletionTime: %s
+
+Lifecycle
+---------
+%s
+Pods
+----
+%s`,
+		n.NamespaceName.Name,
+		strings.Repeat("-", len(n.NamespaceName.Name)),
+		lo.Ternary(n.UID == "", "N/A", string(n.UID)),
+		lo.CoalesceOrEmpty(n.NodeClaim(), "N/A"),
+		formatTime(n.CreationTime),
+		formatTime(n.LastUpdatedTime),
+		formatTime(n.DeletionTime),
+		FormatTransitions(n.Lifecycle),
+		pods.String(),
+	)
+}
+
+// NodeClaim returns the name of the Karpenter NodeClaim that launched the node, if any
+func (n Node) NodeClaim() string {
+	if n.Node == nil {
+		return ""
+	}
+	owner, _ := lo.Find(n.Node.OwnerReferences, func(o metav1.OwnerReference) bool { return o.Kind == "NodeClaim" })
+	return owner.Name
 }
 
 func (e Node) Get() string {
-	return string(lo.Must(yaml.Marshal(e.Node)))
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(e.Snapshot()))))
 }
 
-type NodeParser struct{}
+func (n Node) Snapshot() client.Object {
+	if n.Node == nil {
+		return nil
+	}
+	return n.Node
+}
 
-func (NodeParser) Coalesce(nn types.NamespacedName, events []ParsedEvent) Object {
-	n := Node{NamespaceName: nn}
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].Timestamp.Before(events[j].Timestamp)
-	})
-	for _, e := range events {
-		if e.ObjectType != ObjectTypeNode {
-			continue
+func (n *Node) apply(e ParsedEvent) {
+	if e.Object != nil {
+		n.reorder.push(e, n.fold)
+	}
+	if n.observe(e, EventTypeNodeCreated, EventTypeNodeUpdated, EventTypeNodeDeleted) {
+		n.Node = e.Object.(*v1.Node)
+	}
+}
+
+// fold adds the changes to the node's Ready condition, taints and cordon since the previous snapshot
+// to its transitions
+func (n *Node) fold(e ParsedEvent) {
+	prev, node := lo.CoalesceOrEmpty(n.prev, &v1.Node{}), e.Object.(*v1.Node)
+	prevReady, ready := readyCondition(prev), readyCondition(node)
+	if ready != nil && (prevReady == nil || prevReady.Status != ready.Status) {
+		// The condition knows when it transitioned, which can be well before the status was written
+		ts := lo.Ternary(ready.LastTransitionTime.IsZero(), e.Timestamp, ready.LastTransitionTime.Time)
+		n.transitions = append(n.transitions, Transition{
+			Timestamp: ts,
+			Event:     lo.Ternary(ready.Status == v1.ConditionTrue, "Ready", "NotReady"),
+			Details:   strings.Join(lo.Compact([]string{ready.Reason, ready.Message}), ": "),
+		})
+	}
+	prevTaints := lo.Map(prev.Spec.Taints, func(t v1.Taint, _ int) string { return t.ToString() })
+	taints := lo.Map(node.Spec.Taints, func(t v1.Taint, _ int) string { return t.ToString() })
+	added, removed := lo.Difference(taints, prevTaints)
+	for _, t := range added {
+		n.transitions = append(n.transitions, Transition{Timestamp: e.Timestamp, Event: "Tainted", Details: t})
+	}
+	for _, t := range removed {
+		n.transitions = append(n.transitions, Transition{Timestamp: e.Timestamp, Event: "Untainted", Details: t})
+	}
+	if prev.Spec.Unschedulable != node.Spec.Unschedulable {
+		n.transitions = append(n.transitions, Transition{Timestamp: e.Timestamp, Event: lo.Ternary(node.Spec.Unschedulable, "Cordoned", "Uncordoned")})
+	}
+	n.prev = node
+}
+
+// lifecycle returns the transitions of the node from its creation, deletion and the changes that
+// were folded in from its snapshots
+func (n *Node) lifecycle() []Transition {
+	n.reorder.flush(n.fold)
+	return withLifetime(n.lifetime, n.transitions, Transition{})
+}
+
+func readyCondition(node *v1.Node) *v1.NodeCondition {
+	cond, ok := lo.Find(node.Status.Conditions, func(c v1.NodeCondition) bool { return c.Type == v1.NodeReady })
+	if !ok {
+		return nil
+	}
+	return &cond
+}
+
+// podBindings matches the bindings of pods to a node with the deletes of those pods as the events
+// are streamed, so that it only holds on to the pods that were bound to the node. A pod is deleted by
+// the first delete of a pod with its namespace and name after it was bound, as long as their UIDs
+// don't differ when both are known.
+type podBindings struct {
+	nodeName string
+	pods     []BoundPod
+	// byName indexes pods by their namespace and name, as deletes don't always log the pod's UID
+	byName map[types.NamespacedName][]int
+	// early are the deletes that didn't match a binding yet, by the namespace and name of their pod.
+	// Providers don't all stream events in order, and deletes don't log the pod, so a delete can't be
+	// told apart from the deletes of pods on other nodes until a binding of a pod with its name arrives.
+	early map[types.NamespacedName][]podDelete
+}
+
+// podDelete is the delete of a pod, without the rest of the event
+type podDelete struct {
+	timestamp time.Time
+	uid       types.UID
+}
+
+func newPodBindings(nodeName string) *podBindings {
+	return &podBindings{nodeName: nodeName, byName: map[types.NamespacedName][]int{}, early: map[types.NamespacedName][]podDelete{}}
+}
+
+func (b *podBindings) add(e ParsedEvent) {
+	switch e.Event {
+	case EventTypePodBound:
+		if e.AdditionalProperties["NodeName"] != b.nodeName {
+			return
 		}
-		if e.NamespaceName.String() != nn.String() {
-			continue
+		b.byName[e.NamespaceName] = append(b.byName[e.NamespaceName], len(b.pods))
+		b.pods = append(b.pods, BoundPod{NamespaceName: e.NamespaceName, UID: e.UID, BindTime: e.Timestamp})
+		early := b.early[e.NamespaceName]
+		delete(b.early, e.NamespaceName)
+		for _, d := range early {
+			b.delete(e.NamespaceName, d)
 		}
-		switch e.Event {
-		case EventTypeNodeCreated:
-			n.CreationTime = e.Timestamp
-			n.Node = e.Object.(*v1.Node)
-		case EventTypeNodeUpdated:
-			n.LastUpdatedTime = e.Timestamp
-			n.Node = e.Object.(*v1.Node)
-		case EventTypePodDeleted:
-			n.DeletionTime = e.Timestamp
+	case EventTypePodDeleted:
+		b.delete(e.NamespaceName, podDelete{timestamp: e.Timestamp, uid: e.UID})
+	}
+}
+
+// delete marks the pod that a delete is for as deleted, or holds on to the delete until a binding
+// arrives that it's for
+func (b *podBindings) delete(nn types.NamespacedName, d podDelete) {
+	// The delete is for the latest binding of a pod with its name before it
+	i := -1
+	for _, j := range b.byName[nn] {
+		if !b.pods[j].BindTime.After(d.timestamp) && (i == -1 || b.pods[j].BindTime.After(b.pods[i].BindTime)) {
+			i = j
 		}
 	}
-	return n
-}
-
-func (NodeParser) Extract(event auditmodel.Event) ParsedEvent {
-	pe := ParsedEvent{
-		Timestamp:            event.RequestReceivedTimestamp.Time,
-		ObjectType:           ObjectTypeNode,
-		AdditionalProperties: map[string]string{},
-	}
-	var n v1.Node
-	switch {
-	case event.Verb == "create":
-		pe.Event = EventTypeNodeCreated
-		lo.Must0(json.Unmarshal(lo.Must(json.Marshal(event.ResponseObject)), &n))
-		n.ManagedFields = nil
-		pe.Object = &n
-		pe.NamespaceName = types.NamespacedName{Name: event.ObjectRef.Name}
-	case event.Verb == "update":
-		pe.Event = EventTypeNodeUpdated
-		lo.Must0(json.Unmarshal(lo.Must(json.Marshal(event.ResponseObject)), &n))
-		n.ManagedFields = nil
-		pe.Object = &n
-		pe.NamespaceName = types.NamespacedName{Name: event.ObjectRef.Name}
-	case event.Verb == "delete":
-		pe.Event = EventTypeNodeDeleted
-		pe.NamespaceName = types.NamespacedName{Name: event.ObjectRef.Name}
-	default:
-		return ParsedEvent{}
-	}
-	return pe
-}
-
-func (e NodeParser) DescribeQuery(nn types.NamespacedName) string {
-	panic("implement me")
-}
-
-func (e NodeParser) GetQuery(nn types.NamespacedName) string {
-	podQueryTemplate := `
-fields @timestamp, @message
-| filter @logStream like "apiserver"
-| filter verb like /create|delete|update/
-| filter requestURI like "nodes"
-| filter requestURI not like "csi" and requestURI not like "cni"
-| filter @message like "%s"
-`
-	return fmt.Sprintf(podQueryTemplate, nn.Name)
+	if i == -1 || (b.pods[i].UID != "" && d.uid != "" && b.pods[i].UID != d.uid) {
+		b.early[nn] = append(b.early[nn], d)
+		return
+	}
+	p := &b.pods[i]
+	if p.DeletionTime.IsZero() || d.timestamp.Before(p.DeletionTime) {
+		p.DeletionTime = d.timestamp
+	}
+	p.UID = lo.CoalesceOrEmpty(p.UID, d.uid)
+}
+
+// boundPods returns the pods that were bound to the node, in order of binding
+func (b *podBindings) boundPods() []BoundPod {
+	pods := slices.Clone(b.pods)
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].BindTime.Before(pods[j].BindTime)
+	})
+	return pods
+}
+
+type NodeParser struct{}
+
+func (NodeParser) ObjectType() ObjectType {
+	return ObjectTypeNode
+}
+
+func (NodeParser) Coalesce(nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]Object, error) {
+	// Pod events are only in the stream when describing a node, to find the pods that were bound to it
+	bindings := newPodBindings(nn.Name)
+	nodes, err := coalesceIncarnations(nn, ObjectTypeNode, divertEvents(events, ObjectTypePod, bindings.add), func(uid types.UID) *Node {
+		return &Node{lifetime: lifetime{NamespaceName: nn, UID: uid}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := bindings.boundPods()
+	if len(nodes) == 0 && len(pods) > 0 {
+		nodes = []*Node{{lifetime: lifetime{NamespaceName: nn}}}
+	}
+	for _, p := range pods {
+		// Pods belong to the latest incarnation of the node that was created before they were bound
+		node := nodes[0]
+		for _, n := range nodes[1:] {
+			if !n.CreationTime.After(p.BindTime) {
+				node = n
+			}
+		}
+		node.Pods = append(node.Pods, p)
+	}
+	for _, n := range nodes {
+		n.Lifecycle = n.lifecycle()
+	}
+	return lo.Map(nodes, func(n *Node, _ int) Object { return *n }), nil
+}
+
+func (NodeParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	return resourceExtractor{
+		objectType: ObjectTypeNode,
+		newObject:  func() client.Object { return &v1.Node{} },
+		created:    EventTypeNodeCreated,
+		updated:    EventTypeNodeUpdated,
+		deleted:    EventTypeNodeDeleted,
+	}.Extract(event)
+}
+
+func (e NodeParser) DescribeFilter(nn types.NamespacedName) Filter {
+	get := e.GetFilter(nn)
+	return func(ref *auditmodel.ObjectReference) bool {
+		// Bindings of the pods that ran on the node only mention the node in their body, and deletes of
+		// those pods may not mention it at all. Coalesce drops the ones of pods bound to other nodes.
+		return get(ref) || (ref.Resource == "pods" && (ref.Subresource == "binding" || ref.Subresource == ""))
+	}
+}
+
+func (e NodeParser) GetFilter(nn types.NamespacedName) Filter {
+	return func(ref *auditmodel.ObjectReference) bool {
+		return ref.Resource == "nodes" && ref.Name == nn.Name
+	}
+}
+
+func (e NodeParser) DescribeQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs: mutatingVerbs,
+		URIs: []URIMatch{
+			{Contains: []string{"nodes"}, Excludes: []string{"csi", "cni"}},
+			{Contains: []string{"pods", "binding"}},
+			// Deletes only mention the node when their pod is logged along with them
+			{Contains: []string{"pods"}, Verbs: []string{"delete"}, SkipContains: true},
+		},
+		Contains: nn.Name,
+	}
+}
+
+func (e NodeParser) GetQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs:    mutatingVerbs,
+		URIs:     []URIMatch{{Contains: []string{"nodes"}, Excludes: []string{"csi", "cni"}}},
+		Contains: nn.Name,
+	}
 }
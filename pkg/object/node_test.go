@@ -0,0 +1,109 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPodBindings(t *testing.T) {
+	at := func(minutes int) time.Time { return time.Date(2025, 9, 15, 16, minutes, 0, 0, time.UTC) }
+	bound := func(name, node string, minutes int) test.Event {
+		return test.Event{
+			Verb: "create", Resource: "pods", Subresource: "binding", Namespace: "default", Name: name, Time: at(minutes),
+			RequestObject:  fmt.Sprintf(`{"kind":"Binding","apiVersion":"v1","metadata":{"name":%q},"target":{"kind":"Node","name":%q}}`, name, node),
+			ResponseObject: `{"kind":"Status","apiVersion":"v1","status":"Success"}`,
+		}
+	}
+	// deleted is a delete of a pod, whose response is the pod when it's deleted gracefully and a
+	// Status with the pod's UID when it's gone
+	deleted := func(namespace, name string, uid types.UID, minutes int) test.Event {
+		e := test.Event{Verb: "delete", Resource: "pods", Namespace: namespace, Name: name, Time: at(minutes)}
+		if uid != "" {
+			e.ResponseObject = fmt.Sprintf(`{"kind":"Status","apiVersion":"v1","status":"Success","details":{"name":%q,"kind":"pods","uid":%q}}`, name, uid)
+		}
+		return e
+	}
+	gracefullyDeleted := func(uid types.UID, minutes int) test.Event {
+		e := deleted("default", "web", "", minutes)
+		e.ResponseObject = fmt.Sprintf(`{"kind":"Pod","apiVersion":"v1","metadata":{"namespace":"default","name":"web","uid":%q},"spec":{"nodeName":"node-a"}}`, uid)
+		return e
+	}
+	updated := test.Event{
+		Verb: "update", Resource: "pods", Namespace: "default", Name: "web", Time: at(3),
+		ResponseObject: `{"kind":"Pod","apiVersion":"v1","metadata":{"namespace":"default","name":"web","uid":"uid-1"},"spec":{"nodeName":"node-a"}}`,
+	}
+	for _, tc := range []struct {
+		name        string
+		events      []test.Event
+		wantUID     types.UID
+		wantDeleted time.Time
+	}{
+		{
+			name:        "delete without a UID",
+			events:      []test.Event{bound("web", "node-a", 1), updated, deleted("default", "web", "", 5)},
+			wantDeleted: at(5),
+		},
+		{
+			name:        "UID only known from the delete",
+			events:      []test.Event{bound("web", "node-a", 1), deleted("default", "web", "uid-1", 5)},
+			wantUID:     "uid-1",
+			wantDeleted: at(5),
+		},
+		{
+			name:        "UID only known from the graceful delete",
+			events:      []test.Event{bound("web", "node-a", 1), gracefullyDeleted("uid-1", 5)},
+			wantUID:     "uid-1",
+			wantDeleted: at(5),
+		},
+		{
+			name:    "delete of a pod with the same name in another namespace",
+			events:  []test.Event{bound("web", "node-a", 1), deleted("other", "web", "", 5)},
+			wantUID: "",
+		},
+		{
+			name:    "delete before the binding",
+			events:  []test.Event{deleted("default", "web", "", 0), bound("web", "node-a", 1)},
+			wantUID: "",
+		},
+		{
+			name:        "first of several deletes",
+			events:      []test.Event{bound("web", "node-a", 1), deleted("default", "web", "uid-1", 6), deleted("default", "web", "uid-1", 5)},
+			wantUID:     "uid-1",
+			wantDeleted: at(5),
+		},
+		{
+			name:        "delete that arrives before the binding",
+			events:      []test.Event{deleted("default", "web", "uid-1", 5), bound("web", "node-a", 1)},
+			wantUID:     "uid-1",
+			wantDeleted: at(5),
+		},
+		{
+			name:        "delete without a UID that arrives before the binding",
+			events:      []test.Event{deleted("default", "web", "", 5), bound("web", "node-a", 1)},
+			wantDeleted: at(5),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// A pod that ran on another node is bound and deleted in between
+			events := append(tc.events, bound("api", "node-b", 2), deleted("default", "api", "uid-9", 4))
+			objs, err := NodeParser{}.Coalesce(types.NamespacedName{Name: "node-a"}, ParseEvents(test.AuditEvents(t, events...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objs) != 1 {
+				t.Fatalf("got %d nodes, want 1", len(objs))
+			}
+			pods := objs[0].(Node).Pods
+			if len(pods) != 1 {
+				t.Fatalf("got %d bound pods, want 1", len(pods))
+			}
+			if pods[0].UID != tc.wantUID || !pods[0].DeletionTime.Equal(tc.wantDeleted) {
+				t.Errorf("got UID %q deleted at %s, want UID %q deleted at %s", pods[0].UID, pods[0].DeletionTime, tc.wantUID, tc.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/awslabs/operatorpkg/status"
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	EventTypeNodeClaimCreated = "NodeClaimCreated"
+	EventTypeNodeClaimUpdated = "NodeClaimUpdated"
+	EventTypeNodeClaimDeleted = "NodeClaimDeleted"
+)
+
+type NodeClaim struct {
+	NodeClaim *karpv1.NodeClaim
+	lifetime
+
+	// Lifecycle is the NodeClaim's lifecycle, oldest first
+	Lifecycle []Transition
+	// Pods are the pods that Karpenter nominated to the NodeClaim, in order of nomination
+	Pods []NominatedPod
+
+	// reorder holds back the latest snapshots to fold them into transitions in order
+	reorder reorderBuffer
+	// prev is the latest snapshot that was folded into transitions
+	prev        *karpv1.NodeClaim
+	transitions []Transition
+	// disrupted and drifted are set once a folded snapshot showed the NodeClaim disrupted or drifted
+	disrupted, drifted bool
+}
+
+// NominatedPod is a pod that Karpenter nominated to schedule on a NodeClaim
+type NominatedPod struct {
+	NamespaceName  types.NamespacedName
+	UID            types.UID
+	NominationTime time.Time `json:",omitzero"`
+}
+
+// nodeClaimConditions are the NodeClaim conditions that mark a step in its lifecycle when they
+// become true, along with the name of the step
+var nodeClaimConditions = []lo.Tuple2[string, string]{
+	{A: karpv1.ConditionTypeLaunched, B: "Launched"},
+	{A: karpv1.ConditionTypeRegistered, B: "Registered"},
+	{A: karpv1.ConditionTypeInitialized, B: "Initialized"},
+	{A: karpv1.ConditionTypeDrifted, B: "Drifted"},
+	{A: karpv1.ConditionTypeDisruptionReason, B: "Disrupted"},
+}
+
+func (n NodeClaim) Describe() string {
+	pods := &bytes.Buffer{}
+	w := tabwriter.NewWriter(pods, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "POD\tUID\tNOMINATED")
+	for _, p := range n.Pods {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.NamespaceName, lo.CoalesceOrEmpty(string(p.UID), "N/A"), formatTime(p.NominationTime))
+	}
+	lo.Must0(w.Flush())
+
+	var nodeClaim karpv1.NodeClaim
+	if n.NodeClaim != nil {
+		nodeClaim = *n.NodeClaim
+	}
+	return fmt.Sprintf(`
+%s
+%s
+UID: %s
+NodePool: %s
+Node: %s
+ProviderID: %s
+InstanceType: %s
+
+CreationTime: %s
+LastUpdatedTime: %s
+DeletionTime: %s
+
+Lifecycle
+---------
+%s
+Pods
+----
+%s`,
+		n.NamespaceName.Name,
+		strings.Repeat("-", len(n.NamespaceName.Name)),
+		lo.Ternary(n.UID == "", "N/A", string(n.UID)),
+		lo.CoalesceOrEmpty(nodeClaim.Labels[karpv1.NodePoolLabelKey], "N/A"),
+		lo.CoalesceOrEmpty(nodeClaim.Status.NodeName, "N/A"),
+		lo.CoalesceOrEmpty(nodeClaim.Status.ProviderID, "N/A"),
+		lo.CoalesceOrEmpty(nodeClaim.Labels[v1.LabelInstanceTypeStable], "N/A"),
+		formatTime(n.CreationTime),
+		formatTime(n.LastUpdatedTime),
+		formatTime(n.DeletionTime),
+		FormatTransitions(n.Lifecycle),
+		pods.String(),
+	)
+}
+
+func (n NodeClaim) Get() string {
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(n.Snapshot()))))
+}
+
+func (n NodeClaim) Snapshot() client.Object {
+	if n.NodeClaim == nil {
+		return nil
+	}
+	return n.NodeClaim
+}
+
+func (n *NodeClaim) apply(e ParsedEvent) {
+	if e.Object != nil {
+		n.reorder.push(e, n.fold)
+	}
+	if n.observe(e, EventTypeNodeClaimCreated, EventTypeNodeClaimUpdated, EventTypeNodeClaimDeleted) {
+		n.NodeClaim = e.Object.(*karpv1.NodeClaim)
+	}
+}
+
+// fold adds the status conditions that became true since the previous snapshot of the NodeClaim to
+// its transitions
+func (n *NodeClaim) fold(e ParsedEvent) {
+	prev, nodeClaim := lo.CoalesceOrEmpty(n.prev, &karpv1.NodeClaim{}), e.Object.(*karpv1.NodeClaim)
+	for _, c := range nodeClaimConditions {
+		cond := findCondition(nodeClaim.Status.Conditions, c.A)
+		if !cond.IsTrue() || findCondition(prev.Status.Conditions, c.A).IsTrue() {
+			continue
+		}
+		n.disrupted = n.disrupted || c.A == karpv1.ConditionTypeDisruptionReason
+		// Drift is reported once, whether it's seen from the Drifted condition or the disruption
+		// reason that follows it
+		if isDrift(cond) {
+			if n.drifted {
+				continue
+			}
+			n.drifted = true
+		}
+		// The condition knows when it transitioned, which can be well before the status was written
+		ts := lo.Ternary(cond.LastTransitionTime.IsZero(), e.Timestamp, cond.LastTransitionTime.Time)
+		n.transitions = append(n.transitions, Transition{Timestamp: ts, Event: c.B, Details: nodeClaimTransitionDetails(nodeClaim, cond)})
+	}
+	n.prev = nodeClaim
+}
+
+// lifecycle returns the transitions of the NodeClaim from its creation, deletion and the conditions
+// that were folded in from its snapshots
+func (n *NodeClaim) lifecycle() []Transition {
+	n.reorder.flush(n.fold)
+	return withLifetime(n.lifetime, n.transitions, Transition{Details: lo.Ternary(!n.disrupted && n.expired(), "Expired", "")})
+}
+
+// findCondition finds a status condition of a Karpenter object. StatusConditions isn't used for this
+// since it fills in the missing conditions on the object.
+func findCondition(conditions []status.Condition, conditionType string) *status.Condition {
+	cond, ok := lo.Find(conditions, func(c status.Condition) bool { return c.Type == conditionType })
+	if !ok {
+		return nil
+	}
+	return &cond
+}
+
+// isDrift reports whether the condition marks the NodeClaim as drifted
+func isDrift(cond *status.Condition) bool {
+	return cond.Type == karpv1.ConditionTypeDrifted ||
+		(cond.Type == karpv1.ConditionTypeDisruptionReason && cond.Reason == string(karpv1.DisruptionReasonDrifted))
+}
+
+func nodeClaimTransitionDetails(nodeClaim *karpv1.NodeClaim, cond *status.Condition) string {
+	switch cond.Type {
+	case karpv1.ConditionTypeLaunched:
+		return nodeClaim.Status.ProviderID
+	case karpv1.ConditionTypeRegistered:
+		return nodeClaim.Status.NodeName
+	case karpv1.ConditionTypeDisruptionReason:
+		// Karpenter consolidates nodes that are empty or underutilized
+		return lo.Ternary(cond.Reason == string(karpv1.DisruptionReasonDrifted), cond.Reason, "Consolidation: "+cond.Reason)
+	case karpv1.ConditionTypeInitialized:
+		return ""
+	}
+	return strings.Join(lo.Compact([]string{cond.Reason, cond.Message}), ": ")
+}
+
+// expired reports whether the NodeClaim was deleted once it had lived for its expireAfter. Karpenter
+// deletes expired NodeClaims without disrupting them first, so this is the only trace of it.
+func (n *NodeClaim) expired() bool {
+	if n.NodeClaim == nil || n.NodeClaim.Spec.ExpireAfter.Duration == nil {
+		return false
+	}
+	created := lo.Ternary(n.NodeClaim.CreationTimestamp.IsZero(), n.CreationTime, n.NodeClaim.CreationTimestamp.Time)
+	return !created.IsZero() && !n.DeletionTime.Before(created.Add(*n.NodeClaim.Spec.ExpireAfter.Duration))
+}
+
+// podNominations collects the pods that were nominated to a NodeClaim as the events are streamed,
+// each at the time of its first nomination
+type podNominations struct {
+	nodeClaimName string
+	pods          []NominatedPod
+	// byPod indexes pods by their namespace, name and UID, as a pod is nominated again every time
+	// that Karpenter looks at it
+	byPod map[lo.Tuple2[types.NamespacedName, types.UID]]int
+}
+
+func newPodNominations(nodeClaimName string) *podNominations {
+	return &podNominations{nodeClaimName: nodeClaimName, byPod: map[lo.Tuple2[types.NamespacedName, types.UID]]int{}}
+}
+
+func (n *podNominations) add(e ParsedEvent) {
+	if e.Event != EventTypePodNominated || e.AdditionalProperties["NodeClaim"] != n.nodeClaimName {
+		return
+	}
+	key := lo.T2(e.NamespaceName, e.UID)
+	if i, ok := n.byPod[key]; ok {
+		if e.Timestamp.Before(n.pods[i].NominationTime) {
+			n.pods[i].NominationTime = e.Timestamp
+		}
+		return
+	}
+	n.byPod[key] = len(n.pods)
+	n.pods = append(n.pods, NominatedPod{NamespaceName: e.NamespaceName, UID: e.UID, NominationTime: e.Timestamp})
+}
+
+// nominatedPods returns the pods that were nominated to the NodeClaim, in order of nomination
+func (n *podNominations) nominatedPods() []NominatedPod {
+	pods := slices.Clone(n.pods)
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].NominationTime.Before(pods[j].NominationTime)
+	})
+	return pods
+}
+
+type NodeClaimParser struct{}
+
+func (NodeClaimParser) ObjectType() ObjectType {
+	return ObjectTypeNodeClaim
+}
+
+func (NodeClaimParser) Coalesce(nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]Object, error) {
+	// Pod events are only in the stream when describing a NodeClaim, to find the pods that it was launched for
+	nominations := newPodNominations(nn.Name)
+	nodeClaims, err := coalesceIncarnations(nn, ObjectTypeNodeClaim, divertEvents(events, ObjectTypePod, nominations.add), func(uid types.UID) *NodeClaim {
+		return &NodeClaim{lifetime: lifetime{NamespaceName: nn, UID: uid}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := nominations.nominatedPods()
+	if len(nodeClaims) == 0 && len(pods) > 0 {
+		nodeClaims = []*NodeClaim{{lifetime: lifetime{NamespaceName: nn}}}
+	}
+	for _, p := range pods {
+		// NodeClaim names are generated, so the pods can only belong to the one incarnation
+		nodeClaims[len(nodeClaims)-1].Pods = append(nodeClaims[len(nodeClaims)-1].Pods, p)
+	}
+	for _, n := range nodeClaims {
+		n.Lifecycle = n.lifecycle()
+	}
+	return lo.Map(nodeClaims, func(n *NodeClaim, _ int) Object { return *n }), nil
+}
+
+func (NodeClaimParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	return resourceExtractor{
+		objectType: ObjectTypeNodeClaim,
+		newObject:  func() client.Object { return &karpv1.NodeClaim{} },
+		created:    EventTypeNodeClaimCreated,
+		updated:    EventTypeNodeClaimUpdated,
+		deleted:    EventTypeNodeClaimDeleted,
+	}.Extract(event)
+}
+
+func (p NodeClaimParser) DescribeFilter(nn types.NamespacedName) Filter {
+	get := p.GetFilter(nn)
+	return func(ref *auditmodel.ObjectReference) bool {
+		// Karpenter's nominations of pods to the NodeClaim are Events in the pods' namespaces
+		return get(ref) || ref.Resource == "events"
+	}
+}
+
+func (NodeClaimParser) GetFilter(nn types.NamespacedName) Filter {
+	return func(ref *auditmodel.ObjectReference) bool {
+		// Karpenter creates NodeClaims with generateName, so these are kept and matched against the
+		// name in the response object when the event is extracted
+		return ref.Resource == "nodeclaims" && (ref.Name == "" || ref.Name == nn.Name)
+	}
+}
+
+func (NodeClaimParser) DescribeQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs:    mutatingVerbs,
+		URIs:     []URIMatch{{Contains: []string{"nodeclaims"}}, {Contains: []string{"events"}, Verbs: []string{"create"}}},
+		Contains: nn.Name,
+	}
+}
+
+func (NodeClaimParser) GetQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs:       mutatingVerbs,
+		URIContains: []string{"nodeclaims"},
+		Contains:    nn.Name,
+	}
+}
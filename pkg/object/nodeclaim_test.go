@@ -0,0 +1,184 @@
+package object
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awslabs/operatorpkg/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+var nodeClaimNN = types.NamespacedName{Name: "default-abcde"}
+
+// nodeClaimEvent is an event of the NodeClaim default-abcde, logged with the conditions that are
+// true at the time
+func nodeClaimEvent(verb string, at time.Time, conditions ...status.Condition) ParsedEvent {
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeClaimNN.Name, UID: "nc-1", Labels: map[string]string{karpv1.NodePoolLabelKey: "default"}},
+		Status:     karpv1.NodeClaimStatus{ProviderID: "aws:///us-west-2a/i-0123", NodeName: "ip-10-0-0-1"},
+	}
+	for _, c := range conditions {
+		c.Status = metav1.ConditionTrue
+		nodeClaim.Status.Conditions = append(nodeClaim.Status.Conditions, c)
+	}
+	return ParsedEvent{
+		Timestamp:     at,
+		NamespaceName: nodeClaimNN,
+		UID:           "nc-1",
+		Verb:          verb,
+		ObjectType:    ObjectTypeNodeClaim,
+		Event:         map[string]EventType{"create": EventTypeNodeClaimCreated, "update": EventTypeNodeClaimUpdated, "delete": EventTypeNodeClaimDeleted}[verb],
+		Object:        nodeClaim,
+	}
+}
+
+func TestNodeClaimLifecycle(t *testing.T) {
+	minute := func(m int) time.Time { return atTime.Add(time.Duration(m) * time.Minute) }
+	launched := status.Condition{Type: karpv1.ConditionTypeLaunched}
+	registered := status.Condition{Type: karpv1.ConditionTypeRegistered}
+	initialized := status.Condition{Type: karpv1.ConditionTypeInitialized}
+	drifted := status.Condition{Type: karpv1.ConditionTypeDrifted, Reason: "NodePoolDrifted", Message: "spec.template changed"}
+	disruptedBy := func(reason karpv1.DisruptionReason) status.Condition {
+		return status.Condition{Type: karpv1.ConditionTypeDisruptionReason, Reason: string(reason)}
+	}
+	started := []ParsedEvent{
+		nodeClaimEvent("create", minute(0)),
+		nodeClaimEvent("update", minute(1), launched),
+		nodeClaimEvent("update", minute(2), launched, registered),
+		nodeClaimEvent("update", minute(3), launched, registered, initialized),
+	}
+	startedTransitions := []Transition{
+		{Timestamp: minute(0), Event: "Created"},
+		{Timestamp: minute(1), Event: "Launched", Details: "aws:///us-west-2a/i-0123"},
+		{Timestamp: minute(2), Event: "Registered", Details: "ip-10-0-0-1"},
+		{Timestamp: minute(3), Event: "Initialized"},
+	}
+	for _, tc := range []struct {
+		name   string
+		events []ParsedEvent
+		want   []Transition
+	}{
+		{
+			name:   "launch, registration and initialization",
+			events: started,
+			want:   startedTransitions,
+		},
+		{
+			name: "status written out of order",
+			events: []ParsedEvent{
+				started[3], started[1], started[0], started[2],
+			},
+			want: startedTransitions,
+		},
+		{
+			name: "drift and its disruption reported once",
+			events: append(started,
+				nodeClaimEvent("update", minute(10), launched, registered, initialized, drifted),
+				nodeClaimEvent("update", minute(11), launched, registered, initialized, drifted, disruptedBy(karpv1.DisruptionReasonDrifted)),
+				nodeClaimEvent("delete", minute(12), launched, registered, initialized, drifted, disruptedBy(karpv1.DisruptionReasonDrifted)),
+			),
+			want: append(startedTransitions,
+				Transition{Timestamp: minute(10), Event: "Drifted", Details: "NodePoolDrifted: spec.template changed"},
+				Transition{Timestamp: minute(12), Event: "Deleted"},
+			),
+		},
+		{
+			name: "drift only seen from its disruption reason",
+			events: append(started,
+				nodeClaimEvent("update", minute(11), launched, registered, initialized, disruptedBy(karpv1.DisruptionReasonDrifted)),
+			),
+			want: append(startedTransitions,
+				Transition{Timestamp: minute(11), Event: "Disrupted", Details: "Drifted"},
+			),
+		},
+		{
+			name: "consolidation",
+			events: append(started,
+				nodeClaimEvent("update", minute(20), launched, registered, initialized, disruptedBy(karpv1.DisruptionReasonUnderutilized)),
+				nodeClaimEvent("delete", minute(21), launched, registered, initialized, disruptedBy(karpv1.DisruptionReasonUnderutilized)),
+			),
+			want: append(startedTransitions,
+				Transition{Timestamp: minute(20), Event: "Disrupted", Details: "Consolidation: Underutilized"},
+				Transition{Timestamp: minute(21), Event: "Deleted"},
+			),
+		},
+		{
+			name: "condition transition time before the status was written",
+			events: []ParsedEvent{
+				nodeClaimEvent("create", minute(0)),
+				nodeClaimEvent("update", minute(5), status.Condition{Type: karpv1.ConditionTypeLaunched, LastTransitionTime: metav1.NewTime(minute(1))}),
+			},
+			want: []Transition{
+				{Timestamp: minute(0), Event: "Created"},
+				{Timestamp: minute(1), Event: "Launched", Details: "aws:///us-west-2a/i-0123"},
+			},
+		},
+		{
+			name: "expired",
+			events: []ParsedEvent{
+				func() ParsedEvent {
+					e := nodeClaimEvent("create", minute(0))
+					e.Object.(*karpv1.NodeClaim).Spec.ExpireAfter = karpv1.MustParseNillableDuration("30m")
+					return e
+				}(),
+				{Timestamp: minute(30), NamespaceName: nodeClaimNN, Verb: "delete", ObjectType: ObjectTypeNodeClaim, Event: EventTypeNodeClaimDeleted},
+			},
+			want: []Transition{
+				{Timestamp: minute(0), Event: "Created"},
+				{Timestamp: minute(30), Event: "Deleted", Details: "Expired"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			objs, err := NodeClaimParser{}.Coalesce(nodeClaimNN, seqOf(tc.events...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objs) != 1 {
+				t.Fatalf("got %d NodeClaims, want 1", len(objs))
+			}
+			got := objs[0].(NodeClaim).Lifecycle
+			if len(got) != len(tc.want) {
+				t.Fatalf("got lifecycle\n%s\nwant\n%s", FormatTransitions(got), FormatTransitions(tc.want))
+			}
+			for i := range got {
+				if !got[i].Timestamp.Equal(tc.want[i].Timestamp) || got[i].Event != tc.want[i].Event || got[i].Details != tc.want[i].Details {
+					t.Fatalf("got lifecycle\n%s\nwant\n%s", FormatTransitions(got), FormatTransitions(tc.want))
+				}
+			}
+		})
+	}
+}
+
+func TestNodeClaimPods(t *testing.T) {
+	nominated := func(name string, uid types.UID, nodeClaim string, minutes int) ParsedEvent {
+		return ParsedEvent{
+			Timestamp:            atTime.Add(time.Duration(minutes) * time.Minute),
+			NamespaceName:        types.NamespacedName{Namespace: "default", Name: name},
+			UID:                  uid,
+			ObjectType:           ObjectTypePod,
+			Event:                EventTypePodNominated,
+			AdditionalProperties: map[string]string{"NodeClaim": nodeClaim},
+		}
+	}
+	objs, err := NodeClaimParser{}.Coalesce(nodeClaimNN, seqOf(
+		nodeClaimEvent("create", atTime),
+		nominated("api", "uid-2", nodeClaimNN.Name, 3),
+		nominated("web", "uid-1", nodeClaimNN.Name, 2),
+		// Pods are nominated again every time that Karpenter looks at them
+		nominated("web", "uid-1", nodeClaimNN.Name, 1),
+		nominated("db", "uid-3", "default-fghij", 1),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := objs[0].(NodeClaim).Pods
+	if len(pods) != 2 || pods[0].NamespaceName.Name != "web" || pods[1].NamespaceName.Name != "api" {
+		t.Fatalf("got pods %v, want web and api", pods)
+	}
+	if !pods[0].NominationTime.Equal(atTime.Add(time.Minute)) {
+		t.Errorf("got web nominated at %s, want its first nomination", pods[0].NominationTime)
+	}
+}
@@ -0,0 +1,265 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/awslabs/operatorpkg/status"
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	EventTypeNodePoolCreated = "NodePoolCreated"
+	EventTypeNodePoolUpdated = "NodePoolUpdated"
+	EventTypeNodePoolDeleted = "NodePoolDeleted"
+)
+
+type NodePool struct {
+	NodePool *karpv1.NodePool
+	lifetime
+
+	// Lifecycle is the NodePool's lifecycle, oldest first
+	Lifecycle []Transition
+	// NodeClaims are the NodeClaims that were launched from the NodePool, in order of creation
+	NodeClaims []LaunchedNodeClaim
+
+	// reorder holds back the latest snapshots to fold them into transitions in order
+	reorder reorderBuffer
+	// prev is the latest snapshot that was folded into transitions
+	prev        *karpv1.NodePool
+	transitions []Transition
+}
+
+// LaunchedNodeClaim is a NodeClaim that was launched from a NodePool
+type LaunchedNodeClaim struct {
+	Name         string
+	UID          types.UID
+	CreationTime time.Time `json:",omitzero"`
+	DeletionTime time.Time `json:",omitzero"`
+}
+
+func (n NodePool) Describe() string {
+	nodeClaims := &bytes.Buffer{}
+	w := tabwriter.NewWriter(nodeClaims, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NODECLAIM\tUID\tCREATED\tDELETED")
+	for _, nc := range n.NodeClaims {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", nc.Name, lo.CoalesceOrEmpty(string(nc.UID), "N/A"), formatTime(nc.CreationTime), formatTime(nc.DeletionTime))
+	}
+	lo.Must0(w.Flush())
+
+	return fmt.Sprintf(`
+%s
+%s
+UID: %s
+
+CreationTime: %s
+LastUpdatedTime: %s
+DeletionTime: %s
+
+Lifecycle
+---------
+%s
+NodeClaims
+----------
+%s`,
+		n.NamespaceName.Name,
+		strings.Repeat("-", len(n.NamespaceName.Name)),
+		lo.Ternary(n.UID == "", "N/A", string(n.UID)),
+		formatTime(n.CreationTime),
+		formatTime(n.LastUpdatedTime),
+		formatTime(n.DeletionTime),
+		FormatTransitions(n.Lifecycle),
+		nodeClaims.String(),
+	)
+}
+
+func (n NodePool) Get() string {
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(n.Snapshot()))))
+}
+
+func (n NodePool) Snapshot() client.Object {
+	if n.NodePool == nil {
+		return nil
+	}
+	return n.NodePool
+}
+
+func (n *NodePool) apply(e ParsedEvent) {
+	if e.Object != nil {
+		n.reorder.push(e, n.fold)
+	}
+	if n.observe(e, EventTypeNodePoolCreated, EventTypeNodePoolUpdated, EventTypeNodePoolDeleted) {
+		n.NodePool = e.Object.(*karpv1.NodePool)
+	}
+}
+
+// fold adds the changes to the spec and the Ready condition since the previous snapshot of the
+// NodePool to its transitions
+func (n *NodePool) fold(e ParsedEvent) {
+	nodePool := e.Object.(*karpv1.NodePool)
+	var prevReady *status.Condition
+	if n.prev != nil {
+		prevReady = findCondition(n.prev.Status.Conditions, status.ConditionReady)
+		// Changes to the spec are what drift the NodeClaims that were launched from it
+		if paths := lo.Filter(ChangedPaths(n.prev, nodePool, 0), func(p string, _ int) bool { return strings.HasPrefix(p, "spec.") }); len(paths) > 0 {
+			n.transitions = append(n.transitions, Transition{Timestamp: e.Timestamp, Event: "Updated", Details: strings.Join(paths, ", ")})
+		}
+	}
+	if ready := findCondition(nodePool.Status.Conditions, status.ConditionReady); ready != nil && (prevReady == nil || prevReady.Status != ready.Status) {
+		ts := lo.Ternary(ready.LastTransitionTime.IsZero(), e.Timestamp, ready.LastTransitionTime.Time)
+		n.transitions = append(n.transitions, Transition{
+			Timestamp: ts,
+			Event:     lo.Ternary(ready.IsTrue(), "Ready", "NotReady"),
+			Details:   strings.Join(lo.Compact([]string{ready.Reason, ready.Message}), ": "),
+		})
+	}
+	n.prev = nodePool
+}
+
+// lifecycle returns the transitions of the NodePool from its creation, deletion and the changes that
+// were folded in from its snapshots
+func (n *NodePool) lifecycle() []Transition {
+	n.reorder.flush(n.fold)
+	return withLifetime(n.lifetime, n.transitions, Transition{})
+}
+
+// nodeClaimLaunches collects the NodeClaims that were launched from a NodePool as the events are
+// streamed. NodeClaims are matched on the NodePool label of their logged state, and deletes that
+// didn't log the NodeClaim are matched by name, so the times of every NodeClaim are held on to until
+// one of its states was logged.
+type nodeClaimLaunches struct {
+	nodePoolName string
+	nodeClaims   map[string]*launchedNodeClaim
+}
+
+type launchedNodeClaim struct {
+	LaunchedNodeClaim
+	// launched is set once a logged state of the NodeClaim was labeled with the NodePool
+	launched bool
+}
+
+func newNodeClaimLaunches(nodePoolName string) *nodeClaimLaunches {
+	return &nodeClaimLaunches{nodePoolName: nodePoolName, nodeClaims: map[string]*launchedNodeClaim{}}
+}
+
+func (l *nodeClaimLaunches) add(e ParsedEvent) {
+	nc, ok := l.nodeClaims[e.NamespaceName.Name]
+	if !ok {
+		nc = &launchedNodeClaim{LaunchedNodeClaim: LaunchedNodeClaim{Name: e.NamespaceName.Name}}
+		l.nodeClaims[e.NamespaceName.Name] = nc
+	}
+	nc.UID = lo.CoalesceOrEmpty(nc.UID, e.UID)
+	if e.Object != nil && e.Object.GetLabels()[karpv1.NodePoolLabelKey] == l.nodePoolName {
+		nc.launched = true
+	}
+	switch e.Event {
+	case EventTypeNodeClaimCreated:
+		if nc.CreationTime.IsZero() || e.Timestamp.Before(nc.CreationTime) {
+			nc.CreationTime = e.Timestamp
+		}
+	case EventTypeNodeClaimDeleted:
+		nc.DeletionTime = lo.Latest(nc.DeletionTime, e.Timestamp)
+	}
+}
+
+// launchedNodeClaims returns the NodeClaims that were launched from the NodePool, in order of creation
+func (l *nodeClaimLaunches) launchedNodeClaims() []LaunchedNodeClaim {
+	var nodeClaims []LaunchedNodeClaim
+	for _, nc := range l.nodeClaims {
+		if nc.launched {
+			nodeClaims = append(nodeClaims, nc.LaunchedNodeClaim)
+		}
+	}
+	sort.Slice(nodeClaims, func(i, j int) bool {
+		if !nodeClaims[i].CreationTime.Equal(nodeClaims[j].CreationTime) {
+			return nodeClaims[i].CreationTime.Before(nodeClaims[j].CreationTime)
+		}
+		return nodeClaims[i].Name < nodeClaims[j].Name
+	})
+	return nodeClaims
+}
+
+type NodePoolParser struct{}
+
+func (NodePoolParser) ObjectType() ObjectType {
+	return ObjectTypeNodePool
+}
+
+func (NodePoolParser) Coalesce(nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]Object, error) {
+	// NodeClaim events are only in the stream when describing a NodePool, to find the NodeClaims that were launched from it
+	launches := newNodeClaimLaunches(nn.Name)
+	nodePools, err := coalesceIncarnations(nn, ObjectTypeNodePool, divertEvents(events, ObjectTypeNodeClaim, launches.add), func(uid types.UID) *NodePool {
+		return &NodePool{lifetime: lifetime{NamespaceName: nn, UID: uid}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodeClaims := launches.launchedNodeClaims()
+	if len(nodePools) == 0 && len(nodeClaims) > 0 {
+		nodePools = []*NodePool{{lifetime: lifetime{NamespaceName: nn}}}
+	}
+	for _, nc := range nodeClaims {
+		// NodeClaims belong to the latest incarnation of the NodePool that was created before them
+		nodePool := nodePools[0]
+		for _, n := range nodePools[1:] {
+			if !n.CreationTime.After(nc.CreationTime) {
+				nodePool = n
+			}
+		}
+		nodePool.NodeClaims = append(nodePool.NodeClaims, nc)
+	}
+	for _, n := range nodePools {
+		n.Lifecycle = n.lifecycle()
+	}
+	return lo.Map(nodePools, func(n *NodePool, _ int) Object { return *n }), nil
+}
+
+func (NodePoolParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	return resourceExtractor{
+		objectType: ObjectTypeNodePool,
+		newObject:  func() client.Object { return &karpv1.NodePool{} },
+		created:    EventTypeNodePoolCreated,
+		updated:    EventTypeNodePoolUpdated,
+		deleted:    EventTypeNodePoolDeleted,
+	}.Extract(event)
+}
+
+func (p NodePoolParser) DescribeFilter(nn types.NamespacedName) Filter {
+	get := p.GetFilter(nn)
+	return func(ref *auditmodel.ObjectReference) bool {
+		// NodeClaims are named after their NodePool and labeled with it
+		return get(ref) || ref.Resource == "nodeclaims"
+	}
+}
+
+func (NodePoolParser) GetFilter(nn types.NamespacedName) Filter {
+	return func(ref *auditmodel.ObjectReference) bool {
+		return ref.Resource == "nodepools" && ref.Name == nn.Name
+	}
+}
+
+func (NodePoolParser) DescribeQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs:    mutatingVerbs,
+		URIs:     []URIMatch{{Contains: []string{"nodepools"}}, {Contains: []string{"nodeclaims"}}},
+		Contains: nn.Name,
+	}
+}
+
+func (NodePoolParser) GetQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs:       mutatingVerbs,
+		URIContains: []string{"nodepools"},
+		Contains:    nn.Name,
+	}
+}
@@ -0,0 +1,117 @@
+package object
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awslabs/operatorpkg/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+var nodePoolNN = types.NamespacedName{Name: "default"}
+
+func nodePoolEvent(verb string, uid types.UID, at time.Time, nodePool *karpv1.NodePool) ParsedEvent {
+	nodePool.Name, nodePool.UID = nodePoolNN.Name, uid
+	return ParsedEvent{
+		Timestamp:     at,
+		NamespaceName: nodePoolNN,
+		UID:           uid,
+		Verb:          verb,
+		ObjectType:    ObjectTypeNodePool,
+		Event:         map[string]EventType{"create": EventTypeNodePoolCreated, "update": EventTypeNodePoolUpdated, "delete": EventTypeNodePoolDeleted}[verb],
+		Object:        nodePool,
+	}
+}
+
+func TestNodePoolLifecycle(t *testing.T) {
+	minute := func(m int) time.Time { return atTime.Add(time.Duration(m) * time.Minute) }
+	nodePool := func(consolidateAfter string, ready metav1.ConditionStatus, reason string) *karpv1.NodePool {
+		n := &karpv1.NodePool{Spec: karpv1.NodePoolSpec{Disruption: karpv1.Disruption{ConsolidateAfter: karpv1.MustParseNillableDuration(consolidateAfter)}}}
+		if ready != "" {
+			n.Status.Conditions = []status.Condition{{Type: status.ConditionReady, Status: ready, Reason: reason}}
+		}
+		return n
+	}
+	events := []ParsedEvent{
+		nodePoolEvent("create", "np-1", minute(0), nodePool("30s", "", "")),
+		nodePoolEvent("update", "np-1", minute(1), nodePool("30s", metav1.ConditionTrue, "Ready")),
+		nodePoolEvent("update", "np-1", minute(10), nodePool("5m", metav1.ConditionTrue, "Ready")),
+		nodePoolEvent("update", "np-1", minute(20), nodePool("5m", metav1.ConditionFalse, "NodeClassNotReady")),
+		// A status write that changes neither the spec nor readiness
+		nodePoolEvent("update", "np-1", minute(21), nodePool("5m", metav1.ConditionFalse, "NodeClassNotReady")),
+		nodePoolEvent("delete", "np-1", minute(30), nodePool("5m", metav1.ConditionFalse, "NodeClassNotReady")),
+	}
+	want := []Transition{
+		{Timestamp: minute(0), Event: "Created"},
+		{Timestamp: minute(1), Event: "Ready", Details: "Ready"},
+		{Timestamp: minute(10), Event: "Updated", Details: "spec.disruption.consolidateAfter"},
+		{Timestamp: minute(20), Event: "NotReady", Details: "NodeClassNotReady"},
+		{Timestamp: minute(30), Event: "Deleted"},
+	}
+	// The status is written out of order
+	events[1], events[3] = events[3], events[1]
+	objs, err := NodePoolParser{}.Coalesce(nodePoolNN, seqOf(events...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("got %d NodePools, want 1", len(objs))
+	}
+	got := objs[0].(NodePool).Lifecycle
+	if len(got) != len(want) {
+		t.Fatalf("got lifecycle\n%s\nwant\n%s", FormatTransitions(got), FormatTransitions(want))
+	}
+	for i := range got {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) || got[i].Event != want[i].Event || got[i].Details != want[i].Details {
+			t.Fatalf("got lifecycle\n%s\nwant\n%s", FormatTransitions(got), FormatTransitions(want))
+		}
+	}
+}
+
+func TestNodePoolNodeClaims(t *testing.T) {
+	minute := func(m int) time.Time { return atTime.Add(time.Duration(m) * time.Minute) }
+	nodeClaim := func(verb, name string, uid types.UID, nodePool string, minutes int) ParsedEvent {
+		e := ParsedEvent{
+			Timestamp:     minute(minutes),
+			NamespaceName: types.NamespacedName{Name: name},
+			UID:           uid,
+			Verb:          verb,
+			ObjectType:    ObjectTypeNodeClaim,
+			Event:         map[string]EventType{"create": EventTypeNodeClaimCreated, "delete": EventTypeNodeClaimDeleted}[verb],
+		}
+		if nodePool != "" {
+			e.Object = &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, UID: uid, Labels: map[string]string{karpv1.NodePoolLabelKey: nodePool}}}
+		}
+		return e
+	}
+	objs, err := NodePoolParser{}.Coalesce(nodePoolNN, seqOf(
+		nodePoolEvent("create", "np-1", minute(0), &karpv1.NodePool{}),
+		nodeClaim("create", "default-bbbbb", "nc-2", "default", 5),
+		// A delete that didn't log the NodeClaim, which arrives before its creation
+		nodeClaim("delete", "default-aaaaa", "", "", 8),
+		nodeClaim("create", "default-aaaaa", "nc-1", "default", 2),
+		nodeClaim("create", "other-ccccc", "nc-3", "other", 3),
+		nodePoolEvent("delete", "np-1", minute(10), &karpv1.NodePool{}),
+		// The NodePool is created again with the same name
+		nodePoolEvent("create", "np-2", minute(15), &karpv1.NodePool{}),
+		nodeClaim("create", "default-ddddd", "nc-4", "default", 16),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("got %d NodePools, want 2", len(objs))
+	}
+	first, second := objs[0].(NodePool).NodeClaims, objs[1].(NodePool).NodeClaims
+	if len(first) != 2 || first[0].Name != "default-aaaaa" || first[1].Name != "default-bbbbb" {
+		t.Fatalf("got NodeClaims %v of the first NodePool, want default-aaaaa and default-bbbbb", first)
+	}
+	if first[0].UID != "nc-1" || !first[0].CreationTime.Equal(minute(2)) || !first[0].DeletionTime.Equal(minute(8)) {
+		t.Errorf("got default-aaaaa %v, want nc-1 created at %s and deleted at %s", first[0], minute(2), minute(8))
+	}
+	if len(second) != 1 || second[0].Name != "default-ddddd" {
+		t.Errorf("got NodeClaims %v of the second NodePool, want default-ddddd", second)
+	}
+}
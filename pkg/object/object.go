@@ -1,12 +1,15 @@
 package object
 
 import (
+	"encoding/json"
 	"fmt"
+	"iter"
+	"strings"
 	"time"
 
 	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
 	"github.com/samber/lo"
-	lop "github.com/samber/lo/parallel"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -14,53 +17,167 @@ import (
 type Object interface {
 	Get() string
 	Describe() string
+	Incarnation() Incarnation
+	// Snapshot returns the latest known state of the object, or nil if none was logged
+	Snapshot() client.Object
 }
 
 type ObjectParser interface {
-	Extract(event auditmodel.Event) ParsedEvent
-	Coalesce(types.NamespacedName, []ParsedEvent) Object
-	GetQuery(types.NamespacedName) string
-	DescribeQuery(types.NamespacedName) string
+	ObjectType() ObjectType
+	Extract(event auditmodel.Event) (ParsedEvent, error)
+	// Coalesce returns one Object per incarnation of the named object, in order of creation
+	Coalesce(types.NamespacedName, iter.Seq2[ParsedEvent, error]) ([]Object, error)
+	GetQuery(types.NamespacedName) Query
+	DescribeQuery(types.NamespacedName) Query
+	GetFilter(types.NamespacedName) Filter
+	DescribeFilter(types.NamespacedName) Filter
 }
 
+// Lister is implemented by the parsers whose objects can be listed rather than named, across a
+// namespace or across every namespace when it's empty
+type Lister interface {
+	ListQuery(namespace string) Query
+	ListFilter(namespace string) Filter
+}
+
+// Filter reports whether an audit event referencing the given object is relevant to a query.
+// It is the local counterpart of GetQuery and DescribeQuery for providers that can't push
+// filtering down to a query engine, and only relies on the objectRef so that it can be evaluated
+// before the full event is decoded.
+type Filter func(ref *auditmodel.ObjectReference) bool
+
 type ParsedEvent struct {
 	Timestamp            time.Time
 	NamespaceName        types.NamespacedName
+	UID                  types.UID
+	Verb                 string
+	Subresource          string
+	User                 string
 	ObjectType           ObjectType
 	Object               client.Object
 	Event                EventType
 	AdditionalProperties map[string]string
+	// Patch is set for patch and apply events that didn't log the patched object
+	Patch *Patch
 }
 
 type ObjectType string
 
 const (
-	ObjectTypePod  = "Pod"
-	ObjectTypeNode = "Node"
+	ObjectTypePod         = "Pod"
+	ObjectTypeNode        = "Node"
+	ObjectTypeNodeClaim   = "NodeClaim"
+	ObjectTypeNodePool    = "NodePool"
+	ObjectTypeDeployment  = "Deployment"
+	ObjectTypeReplicaSet  = "ReplicaSet"
+	ObjectTypeStatefulSet = "StatefulSet"
+	ObjectTypeDaemonSet   = "DaemonSet"
+	ObjectTypeJob         = "Job"
+	ObjectTypeCronJob     = "CronJob"
 )
 
 type EventType string
 
-func ParseEvents(events []auditmodel.Event) []ParsedEvent {
-	return lo.Filter(lop.Map(events, func(e auditmodel.Event, _ int) ParsedEvent {
-		var parser ObjectParser
-		switch e.ObjectRef.Resource {
-		case "pods":
-			parser = PodParser{}
-		case "nodes":
-			parser = NodeParser{}
+// ParseEvents extracts the events that kubereplay understands from a stream of audit events,
+// dropping everything else. Events are extracted as the returned sequence is consumed. Events that
+// can't be extracted are yielded as a *ParseError and don't end the sequence.
+//
+// Patches that were logged without the patched object are applied to the latest state of the
+// object seen earlier in the stream. Patches to objects without any earlier state are yielded
+// without an object.
+func ParseEvents(events iter.Seq2[auditmodel.Event, error]) iter.Seq2[ParsedEvent, error] {
+	return func(yield func(ParsedEvent, error) bool) {
+		latest := map[ObjectType]map[types.NamespacedName]client.Object{}
+		for e, err := range events {
+			if err != nil {
+				if !yield(ParsedEvent{}, err) {
+					return
+				}
+				continue
+			}
+			// Failed requests didn't change the object, and their response is a Status rather than the object
+			if e.ResponseStatus != nil && e.ResponseStatus.Code >= 400 {
+				continue
+			}
+			if e.ObjectRef == nil {
+				continue
+			}
+			var parser interface {
+				Extract(auditmodel.Event) (ParsedEvent, error)
+			}
+			switch {
+			case e.ObjectRef.Resource == "events":
+				parser = EventParser{}
+			default:
+				parser = ParserFor(schema.GroupResource{Group: e.ObjectRef.APIGroup, Resource: e.ObjectRef.Resource})
+			}
+			pe, err := parser.Extract(e)
+			if err != nil {
+				if !yield(ParsedEvent{}, &ParseError{AuditID: e.AuditID, Err: err}) {
+					return
+				}
+				continue
+			}
+			if lo.IsEmpty(pe.NamespaceName) {
+				continue
+			}
+			pe.Verb = e.Verb
+			pe.User = e.User.Username
+			pe.Subresource = e.ObjectRef.Subresource
+			if pe.Patch != nil {
+				// The latest state may belong to an earlier incarnation if the object was re-created
+				if base, ok := latest[pe.ObjectType][pe.NamespaceName]; ok && (pe.UID == "" || pe.UID == base.GetUID()) {
+					if pe.Object, err = pe.Patch.Apply(base); err != nil {
+						if !yield(ParsedEvent{}, &ParseError{AuditID: e.AuditID, Err: err}) {
+							return
+						}
+						continue
+					}
+					// The resourceVersion that the patch produced isn't logged with it, unlike the base's
+					pe.Object.SetResourceVersion(e.ObjectRef.ResourceVersion)
+				}
+			}
+			if pe.Object != nil {
+				pe.UID = lo.CoalesceOrEmpty(pe.UID, pe.Object.GetUID())
+				if latest[pe.ObjectType] == nil {
+					latest[pe.ObjectType] = map[types.NamespacedName]client.Object{}
+				}
+				latest[pe.ObjectType][pe.NamespaceName] = pe.Object
+			}
+			if !yield(pe, nil) {
+				return
+			}
 		}
-		return parser.Extract(e)
-	}), func(pe ParsedEvent, _ int) bool { return lo.IsNotEmpty(pe.NamespaceName) })
+	}
+}
+
+// decodeObject decodes an object logged in an audit event into a typed object
+func decodeObject(event auditmodel.Event, obj json.RawMessage, into any) error {
+	if isEmptyObject(obj) {
+		return fmt.Errorf("%s event at %s level %w", event.Verb, lo.CoalesceOrEmpty(event.Level, "unknown"), errObjectNotLogged)
+	}
+	return json.Unmarshal(obj, into)
 }
 
+func isEmptyObject(obj json.RawMessage) bool {
+	return len(obj) == 0 || string(obj) == "null"
+}
+
+// NewObjectParserFrom returns the parser for a resource named like the get and describe commands
+// take it, either by the name of one of their subcommands or as <resource>[.<group>]
 func NewObjectParserFrom(objectType string) ObjectParser {
 	switch objectType {
 	case "pod":
 		return PodParser{}
 	case "node":
 		return NodeParser{}
-	default:
-		panic(fmt.Sprintf("invalid object type: %s", objectType))
+	case "nodeclaim":
+		return NodeClaimParser{}
+	case "nodepool":
+		return NodePoolParser{}
+	}
+	if kind, ok := lo.Find(WorkloadTypes(), func(kind ObjectType) bool { return strings.ToLower(string(kind)) == objectType }); ok {
+		return WorkloadParser{Kind: kind}
 	}
+	return ParserFor(schema.ParseGroupResource(objectType))
 }
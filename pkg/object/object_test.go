@@ -0,0 +1,18 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewObjectParserFromWorkloads(t *testing.T) {
+	for _, kind := range WorkloadTypes() {
+		parser := NewObjectParserFrom(strings.ToLower(string(kind)))
+		if parser.ObjectType() != kind {
+			t.Errorf("parser for %s has type %s", kind, parser.ObjectType())
+		}
+		if _, ok := ParserFor(workloadKinds[kind].resource).(WorkloadParser); !ok {
+			t.Errorf("%s isn't parsed as a workload", workloadKinds[kind].resource)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Patch is the body of a patch or apply request. It's carried by events that didn't log the
+// patched object, so that the object can be reconstructed from its previous known state.
+type Patch struct {
+	Type types.PatchType
+	Data []byte
+}
+
+// strategicMergeDirectives are keys that only appear in strategic-merge patches
+var strategicMergeDirectives = [][]byte{
+	[]byte(`"$patch"`),
+	[]byte(`"$retainKeys"`),
+	[]byte(`"$setElementOrder/`),
+	[]byte(`"$deleteFromPrimitiveList/`),
+}
+
+// newPatch returns the patch logged in the request of a patch or apply event. Audit events don't
+// record the request's Content-Type, so the patch type is inferred from the verb and the shape of
+// the patch: JSON patches are arrays of operations, strategic-merge patches are told apart from
+// JSON merge patches by their directives, and server-side apply is treated as a strategic merge of
+// the applied configuration. A patch without directives is applied as a JSON merge patch, like
+// controller-runtime's MergeFrom sends them, since a strategic merge would add back the list
+// entries that the patch removes.
+func newPatch(event auditmodel.Event) (*Patch, error) {
+	data := bytes.TrimSpace(event.RequestObject)
+	if isEmptyObject(data) {
+		return nil, fmt.Errorf("%s event at %s level %w or the patch", event.Verb, event.Level, errObjectNotLogged)
+	}
+	switch {
+	case data[0] == '[':
+		return &Patch{Type: types.JSONPatchType, Data: data}, nil
+	case event.Verb == "apply":
+		return &Patch{Type: types.ApplyPatchType, Data: data}, nil
+	}
+	for _, directive := range strategicMergeDirectives {
+		if bytes.Contains(data, directive) {
+			return &Patch{Type: types.StrategicMergePatchType, Data: data}, nil
+		}
+	}
+	return &Patch{Type: types.MergePatchType, Data: data}, nil
+}
+
+// Apply applies the patch to a copy of obj and returns the patched object
+func (p *Patch) Apply(obj client.Object) (client.Object, error) {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	switch p.Type {
+	case types.JSONPatchType:
+		ops, err := jsonpatch.DecodePatch(p.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding json patch, %w", err)
+		}
+		patched, err = ops.Apply(original)
+		if err != nil {
+			return nil, fmt.Errorf("applying json patch, %w", err)
+		}
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, p.Data)
+		if err != nil {
+			return nil, fmt.Errorf("applying merge patch, %w", err)
+		}
+	case types.StrategicMergePatchType, types.ApplyPatchType:
+		dataStruct, ok := strategicMergeSchema(obj)
+		if !ok {
+			// Custom resources don't support strategic merge, and are applied as a merge
+			patched, err = jsonpatch.MergePatch(original, p.Data)
+			if err != nil {
+				return nil, fmt.Errorf("applying merge patch, %w", err)
+			}
+			break
+		}
+		patched, err = strategicpatch.StrategicMergePatch(original, p.Data, dataStruct)
+		if err != nil {
+			return nil, fmt.Errorf("applying strategic merge patch, %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %s", p.Type)
+	}
+	// Decode into a new object rather than a copy, so that fields removed by the patch don't linger
+	out := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+	if err := json.Unmarshal(patched, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// strategicMergeSchema returns the typed object that describes how obj is strategically merged. An
+// unstructured object is looked up by its kind, and only has one if it's a built-in kind.
+func strategicMergeSchema(obj client.Object) (runtime.Object, bool) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, true
+	}
+	typed, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return nil, false
+	}
+	return typed, true
+}
@@ -0,0 +1,196 @@
+package object
+
+import (
+	"encoding/json"
+	"testing"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPatch(t *testing.T) {
+	pod := func() client.Object {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Labels: map[string]string{"app": "web"}},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "app:1"}, {Name: "proxy", Image: "proxy:1"}}},
+		}
+	}
+	// widget is a custom resource, which has no Go struct to strategically merge with
+	widget := func() client.Object {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata":   map[string]any{"namespace": "default", "name": "web"},
+			"spec":       map[string]any{"items": []any{map[string]any{"name": "a"}, map[string]any{"name": "b"}}},
+		}}
+	}
+	containers := func(obj client.Object) []string {
+		var images []string
+		for _, c := range obj.(*v1.Pod).Spec.Containers {
+			images = append(images, c.Image)
+		}
+		return images
+	}
+	for _, tc := range []struct {
+		name     string
+		verb     string
+		patch    string
+		obj      func() client.Object
+		wantType types.PatchType
+		check    func(t *testing.T, patched client.Object)
+	}{
+		{
+			name:     "json patch",
+			verb:     "patch",
+			patch:    `[{"op":"replace","path":"/metadata/labels/app","value":"api"}]`,
+			obj:      pod,
+			wantType: types.JSONPatchType,
+			check: func(t *testing.T, patched client.Object) {
+				if got := patched.GetLabels()["app"]; got != "api" {
+					t.Errorf("got app label %q, want api", got)
+				}
+			},
+		},
+		{
+			name:     "merge patch of a custom resource",
+			verb:     "patch",
+			patch:    `{"spec":{"items":[{"name":"c"}]}}`,
+			obj:      widget,
+			wantType: types.MergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				// Merge patches replace lists
+				items, _, _ := unstructured.NestedSlice(patched.(*unstructured.Unstructured).Object, "spec", "items")
+				if len(items) != 1 || items[0].(map[string]any)["name"] != "c" {
+					t.Errorf("got items %v, want only c", items)
+				}
+			},
+		},
+		{
+			name:     "merge patch of a built-in kind",
+			verb:     "patch",
+			patch:    `{"metadata":{"labels":{"team":"payments"}},"spec":{"containers":[{"name":"proxy","image":"proxy:2"}]}}`,
+			obj:      pod,
+			wantType: types.MergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				if got := patched.GetLabels(); got["app"] != "web" || got["team"] != "payments" {
+					t.Errorf("got labels %v, want app and team", got)
+				}
+				// Merge patches replace lists
+				if got := containers(patched); len(got) != 1 || got[0] != "proxy:2" {
+					t.Errorf("got containers %v, want [proxy:2]", got)
+				}
+			},
+		},
+		{
+			name:  "merge patch that removes a finalizer",
+			verb:  "patch",
+			patch: `{"metadata":{"finalizers":null}}`,
+			obj: func() client.Object {
+				return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Finalizers: []string{"karpenter.sh/termination"}}}
+			},
+			wantType: types.MergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				if got := patched.GetFinalizers(); len(got) != 0 {
+					t.Errorf("got finalizers %v, want none", got)
+				}
+			},
+		},
+		{
+			name:  "merge patch that removes one of the finalizers",
+			verb:  "patch",
+			patch: `{"metadata":{"finalizers":["example.com/cleanup"],"resourceVersion":"2"}}`,
+			obj: func() client.Object {
+				return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Finalizers: []string{"karpenter.sh/termination", "example.com/cleanup"}}}
+			},
+			wantType: types.MergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				if got := patched.GetFinalizers(); len(got) != 1 || got[0] != "example.com/cleanup" {
+					t.Errorf("got finalizers %v, want [example.com/cleanup]", got)
+				}
+			},
+		},
+		{
+			name:     "strategic merge with element order",
+			verb:     "patch",
+			patch:    `{"spec":{"$setElementOrder/containers":[{"name":"app"},{"name":"proxy"}],"containers":[{"name":"proxy","image":"proxy:2"}]}}`,
+			obj:      pod,
+			wantType: types.StrategicMergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				// Containers are merged by name rather than replaced
+				if got := containers(patched); len(got) != 2 || got[0] != "app:1" || got[1] != "proxy:2" {
+					t.Errorf("got containers %v, want [app:1 proxy:2]", got)
+				}
+			},
+		},
+		{
+			name:     "strategic merge with directives",
+			verb:     "patch",
+			patch:    `{"spec":{"containers":[{"name":"proxy","$patch":"delete"}]}}`,
+			obj:      pod,
+			wantType: types.StrategicMergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				if got := containers(patched); len(got) != 1 || got[0] != "app:1" {
+					t.Errorf("got containers %v, want [app:1]", got)
+				}
+			},
+		},
+		{
+			name:  "merge patch of a built-in kind without a typed object",
+			verb:  "patch",
+			patch: `{"data":{"b":"2"}}`,
+			obj: func() client.Object {
+				return &unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]any{"namespace": "default", "name": "settings"},
+					"data":       map[string]any{"a": "1"},
+				}}
+			},
+			wantType: types.MergePatchType,
+			check: func(t *testing.T, patched client.Object) {
+				data, _, _ := unstructured.NestedStringMap(patched.(*unstructured.Unstructured).Object, "data")
+				if len(data) != 2 || data["a"] != "1" || data["b"] != "2" {
+					t.Errorf("got data %v, want a and b", data)
+				}
+			},
+		},
+		{
+			name:     "apply",
+			verb:     "apply",
+			patch:    `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","labels":{"team":"payments"}},"spec":{"containers":[{"name":"app","image":"app:2"}]}}`,
+			obj:      pod,
+			wantType: types.ApplyPatchType,
+			check: func(t *testing.T, patched client.Object) {
+				if got := patched.GetLabels(); got["app"] != "web" || got["team"] != "payments" {
+					t.Errorf("got labels %v, want app and team", got)
+				}
+				if got := containers(patched); len(got) != 2 || got[0] != "app:2" || got[1] != "proxy:1" {
+					t.Errorf("got containers %v, want [app:2 proxy:1]", got)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := newPatch(auditmodel.Event{Verb: tc.verb, Level: "Request", RequestObject: json.RawMessage(tc.patch)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if patch.Type != tc.wantType {
+				t.Errorf("got patch type %s, want %s", patch.Type, tc.wantType)
+			}
+			obj := tc.obj()
+			patched, err := patch.Apply(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, patched)
+			if patched == obj {
+				t.Error("the object was patched in place")
+			}
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package object
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"iter"
+	"slices"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
@@ -12,6 +15,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/yaml"
 )
 
@@ -24,137 +28,263 @@ const (
 )
 
 type Pod struct {
-	Pod             *v1.Pod
-	NamespaceName   types.NamespacedName
-	NodeName        string
-	CreationTime    time.Time
-	LastUpdatedTime time.Time
-	BindTime        time.Time
-	EvictionTime    time.Time
-	DeletionTime    time.Time
+	Pod *v1.Pod
+	lifetime
+	NodeName string
+	BindTime time.Time `json:",omitzero"`
+	// ReadyTime is when the pod was first logged with its Ready condition true, which is usually
+	// the kubelet's status patch once its containers have started
+	ReadyTime    time.Time `json:",omitzero"`
+	EvictionTime time.Time `json:",omitzero"`
+	// Nominations are Karpenter's nominations of the pod, oldest first
+	Nominations []Nomination
+}
+
+// Nomination is Karpenter nominating a pod to schedule on a NodeClaim that it launched for the pod,
+// or on an existing Node
+type Nomination struct {
+	Timestamp time.Time
+	NodeClaim string
+	Node      string
+	Message   string
+	// LaunchTime is when the NodeClaim was launched, if its launch was logged
+	LaunchTime time.Time `json:",omitzero"`
 }
 
 func (p Pod) Describe() string {
+	nominations := &bytes.Buffer{}
+	w := tabwriter.NewWriter(nominations, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "TIMESTAMP\tNODECLAIM\tLAUNCHED\tNODE")
+	for _, n := range p.Nominations {
+		launched := lo.Ternary(n.LaunchTime.IsZero(), "-", formatTime(n.LaunchTime))
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", formatTime(n.Timestamp), lo.CoalesceOrEmpty(n.NodeClaim, "-"), launched, lo.CoalesceOrEmpty(n.Node, "-"))
+	}
+	lo.Must0(w.Flush())
+
 	return fmt.Sprintf(`
 %s
 %s
+UID: %s
 NodeName: %s
 
 CreationTime: %s
 LastUpdatedTime: %s
 BindTime: %s
+ReadyTime: %s
 EvictionTime: %s
 DeletionTime: %s
 
 Nominations
 ------------
-%s
-`,
+%s`,
 		p.NamespaceName,
 		strings.Repeat("-", len(p.NamespaceName.String())),
+		lo.Ternary(p.UID == "", "N/A", string(p.UID)),
 		lo.Ternary(p.NodeName == "", "N/A", p.NodeName),
 		lo.Ternary(p.CreationTime.IsZero(), "N/A", p.CreationTime.UTC().Format(time.RFC3339)),
-		lo.Ternary(p.LastUpdatedTime.IsZero(), "N/A", p.CreationTime.UTC().Format(time.RFC3339)),
+		lo.Ternary(p.LastUpdatedTime.IsZero(), "N/A", p.LastUpdatedTime.UTC().Format(time.RFC3339)),
 		lo.Ternary(p.BindTime.IsZero(), "N/A", p.BindTime.UTC().Format(time.RFC3339)),
+		lo.Ternary(p.ReadyTime.IsZero(), "N/A", p.ReadyTime.UTC().Format(time.RFC3339)),
 		lo.Ternary(p.EvictionTime.IsZero(), "N/A", p.EvictionTime.UTC().Format(time.RFC3339)),
 		lo.Ternary(p.DeletionTime.IsZero(), "N/A", p.DeletionTime.UTC().Format(time.RFC3339)),
-		"<fill-in-nominations-here>",
+		nominations.String(),
 	)
 }
 
 func (p Pod) Get() string {
-	return string(lo.Must(yaml.Marshal(p.Pod)))
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(p.Snapshot()))))
 }
 
-type PodParser struct{}
+func (p Pod) Snapshot() client.Object {
+	if p.Pod == nil {
+		return nil
+	}
+	return p.Pod
+}
 
-func (PodParser) Coalesce(nn types.NamespacedName, events []ParsedEvent) Object {
-	p := Pod{NamespaceName: nn}
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].Timestamp.Before(events[j].Timestamp)
-	})
-	for _, e := range events {
-		if e.ObjectType != ObjectTypePod {
-			continue
-		}
-		if e.NamespaceName.String() != nn.String() {
-			continue
-		}
-		switch e.Event {
-		case EventTypePodCreated:
-			p.CreationTime = e.Timestamp
-			p.Pod = e.Object.(*v1.Pod)
-		case EventTypePodUpdated:
-			p.LastUpdatedTime = e.Timestamp
-			p.Pod = e.Object.(*v1.Pod)
-		case EventTypePodBound:
+func (p *Pod) apply(e ParsedEvent) {
+	switch e.Event {
+	case EventTypePodBound:
+		if !e.Timestamp.Before(p.BindTime) {
 			p.BindTime = e.Timestamp
 			p.NodeName = e.AdditionalProperties["NodeName"]
-		case EventTypePodEvicted:
-			p.EvictionTime = e.Timestamp
-		case EventTypePodDeleted:
-			p.DeletionTime = e.Timestamp
+		}
+	case EventTypePodEvicted:
+		p.EvictionTime = lo.Latest(p.EvictionTime, e.Timestamp)
+	case EventTypePodNominated:
+		// Nominations are kept in order as they arrive, after the nominations at the same time
+		i := sort.Search(len(p.Nominations), func(i int) bool { return p.Nominations[i].Timestamp.After(e.Timestamp) })
+		p.Nominations = slices.Insert(p.Nominations, i, Nomination{
+			Timestamp: e.Timestamp,
+			NodeClaim: e.AdditionalProperties["NodeClaim"],
+			Node:      e.AdditionalProperties["Node"],
+			Message:   e.AdditionalProperties["Message"],
+		})
+	}
+	if e.Object != nil && isReady(e.Object.(*v1.Pod)) && (p.ReadyTime.IsZero() || e.Timestamp.Before(p.ReadyTime)) {
+		p.ReadyTime = e.Timestamp
+	}
+	if p.observe(e, EventTypePodCreated, EventTypePodUpdated, EventTypePodDeleted) {
+		p.Pod = e.Object.(*v1.Pod)
+	}
+}
+
+// isReady returns whether the pod's Ready condition is true
+func isReady(pod *v1.Pod) bool {
+	return lo.ContainsBy(pod.Status.Conditions, func(c v1.PodCondition) bool {
+		return c.Type == v1.PodReady && c.Status == v1.ConditionTrue
+	})
+}
+
+// node returns the node that the pod was bound to, or that its latest state was scheduled to
+func (p Pod) node() string {
+	if p.NodeName != "" || p.Pod == nil {
+		return p.NodeName
+	}
+	return p.Pod.Spec.NodeName
+}
+
+// phase returns the phase of the latest logged state of the pod
+func (p Pod) phase() v1.PodPhase {
+	if p.Pod == nil {
+		return ""
+	}
+	return p.Pod.Status.Phase
+}
+
+type PodParser struct{}
+
+func (PodParser) ObjectType() ObjectType {
+	return ObjectTypePod
+}
+
+func (PodParser) Coalesce(nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]Object, error) {
+	// NodeClaim events are only in the stream when describing a pod, to find when the NodeClaims
+	// that it was nominated to launched
+	launches := launchTimes{}
+	pods, err := coalesceIncarnations(nn, ObjectTypePod, divertEvents(events, ObjectTypeNodeClaim, launches.add), newPodState(nn))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pods {
+		for i, n := range p.Nominations {
+			p.Nominations[i].LaunchTime = launches[n.NodeClaim]
 		}
 	}
-	return p
+	return lo.Map(pods, func(p *Pod, _ int) Object { return *p }), nil
 }
 
-func (PodParser) Extract(event auditmodel.Event) ParsedEvent {
+// launchTimes collects when each NodeClaim launched as the events are streamed, which is when its
+// Launched condition became true
+type launchTimes map[string]time.Time
+
+func (l launchTimes) add(e ParsedEvent) {
+	if e.Object == nil {
+		return
+	}
+	cond := findCondition(e.Object.(*karpv1.NodeClaim).Status.Conditions, karpv1.ConditionTypeLaunched)
+	if !cond.IsTrue() {
+		return
+	}
+	ts := lo.Ternary(cond.LastTransitionTime.IsZero(), e.Timestamp, cond.LastTransitionTime.Time)
+	if t, ok := l[e.NamespaceName.Name]; !ok || ts.Before(t) {
+		l[e.NamespaceName.Name] = ts
+	}
+}
+
+// newPodState starts the state of an incarnation of the named pod
+func newPodState(nn types.NamespacedName) func(types.UID) *Pod {
+	return func(uid types.UID) *Pod {
+		return &Pod{lifetime: lifetime{NamespaceName: nn, UID: uid}}
+	}
+}
+
+func (PodParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
 	pe := ParsedEvent{
 		Timestamp:            event.RequestReceivedTimestamp.Time,
 		ObjectType:           ObjectTypePod,
+		UID:                  uidOf(event),
 		AdditionalProperties: map[string]string{},
+		NamespaceName:        types.NamespacedName{Namespace: event.ObjectRef.Namespace, Name: event.ObjectRef.Name},
 	}
-	var p v1.Pod
 	switch {
-	case event.Verb == "create" && strings.Contains(event.RequestURI, "binding"):
+	case event.Verb == "create" && event.ObjectRef.Subresource == "binding":
+		var b v1.Binding
+		if err := decodeObject(event, event.RequestObject, &b); err != nil {
+			return ParsedEvent{}, err
+		}
+		if b.Target.Name == "" {
+			return ParsedEvent{}, fmt.Errorf("binding has no target node")
+		}
 		pe.Event = EventTypePodBound
-		pe.AdditionalProperties["NodeName"] = event.RequestObject["target"].(map[string]interface{})["name"].(string)
-		pe.NamespaceName = types.NamespacedName{Namespace: event.ObjectRef.Namespace, Name: event.ObjectRef.Name}
-	case event.Verb == "create" && strings.Contains(event.RequestURI, "eviction"):
+		pe.AdditionalProperties["NodeName"] = b.Target.Name
+		return pe, nil
+	case event.Verb == "create" && event.ObjectRef.Subresource == "eviction":
 		pe.Event = EventTypePodEvicted
-		pe.NamespaceName = types.NamespacedName{Namespace: event.ObjectRef.Namespace, Name: event.ObjectRef.Name}
-	case event.Verb == "create":
-		pe.Event = EventTypePodCreated
-		lo.Must0(json.Unmarshal(lo.Must(json.Marshal(event.ResponseObject)), &p))
-		p.ManagedFields = nil
-		pe.Object = &p
-		pe.NamespaceName = client.ObjectKeyFromObject(&p)
-	case event.Verb == "update":
-		pe.Event = EventTypePodUpdated
-		lo.Must0(json.Unmarshal(lo.Must(json.Marshal(event.ResponseObject)), &p))
-		p.ManagedFields = nil
-		pe.Object = &p
-		pe.NamespaceName = client.ObjectKeyFromObject(&p)
-	case event.Verb == "delete":
-		pe.Event = EventTypePodDeleted
-		pe.NamespaceName = types.NamespacedName{Namespace: event.ObjectRef.Namespace, Name: event.ObjectRef.Name}
-	default:
-		return ParsedEvent{}
-	}
-	return pe
-}
-
-func (PodParser) DescribeQuery(nn types.NamespacedName) string {
-	podQueryTemplate := `
-fields @timestamp, @message
-| filter @logStream like "apiserver"
-| filter verb like /create|delete/
-| filter requestURI like "pods"
-| filter @message like "%s"
-| filter requestURI like "%s"
-`
-	return fmt.Sprintf(podQueryTemplate, nn.Name, nn.Namespace)
-}
-
-func (PodParser) GetQuery(nn types.NamespacedName) string {
-	podQueryTemplate := `
-fields @timestamp, @message
-| filter @logStream like "apiserver"
-| filter verb like /create|delete/
-| filter requestURI like "pods"
-| filter @message like "%s"
-| filter requestURI like "%s"
-`
-	return fmt.Sprintf(podQueryTemplate, nn.Name, nn.Namespace)
+		return pe, nil
+	}
+	return resourceExtractor{
+		objectType: ObjectTypePod,
+		newObject:  func() client.Object { return &v1.Pod{} },
+		created:    EventTypePodCreated,
+		updated:    EventTypePodUpdated,
+		deleted:    EventTypePodDeleted,
+	}.Extract(event)
+}
+
+func (p PodParser) DescribeFilter(nn types.NamespacedName) Filter {
+	get := p.GetFilter(nn)
+	return func(ref *auditmodel.ObjectReference) bool {
+		// Events about the pod, like Karpenter's nominations, are created in the pod's namespace, and
+		// the NodeClaims that it's nominated to don't mention it
+		return get(ref) || (ref.Resource == "events" && ref.Namespace == nn.Namespace) || ref.Resource == "nodeclaims"
+	}
+}
+
+func (PodParser) GetFilter(nn types.NamespacedName) Filter {
+	return func(ref *auditmodel.ObjectReference) bool {
+		// Pods created with generateName don't have a name in their objectRef, so these are kept
+		// and matched against the name in the response object when the event is extracted
+		return ref.Resource == "pods" && ref.Namespace == nn.Namespace && (ref.Name == "" || ref.Name == nn.Name)
+	}
+}
+
+func (PodParser) DescribeQuery(nn types.NamespacedName) Query {
+	namespace := "/namespaces/" + nn.Namespace + "/"
+	return Query{
+		Verbs: mutatingVerbs,
+		URIs: []URIMatch{
+			{Contains: []string{"pods", namespace}},
+			{Contains: []string{"events", namespace}, Verbs: []string{"create"}},
+			// NodeClaims don't mention the pods that they're launched for, and are marked as launched
+			// by a write to their status
+			{Contains: []string{"nodeclaims"}, Verbs: []string{"update", "patch", "apply"}, SkipContains: true},
+		},
+		Contains: nn.Name,
+	}
+}
+
+func (PodParser) GetQuery(nn types.NamespacedName) Query {
+	return Query{
+		Verbs:       mutatingVerbs,
+		URIContains: []string{"pods", "/namespaces/" + nn.Namespace + "/"},
+		Contains:    nn.Name,
+	}
+}
+
+func (PodParser) ListFilter(namespace string) Filter {
+	return func(ref *auditmodel.ObjectReference) bool {
+		return ref.Resource == "pods" && ref.APIGroup == "" && (namespace == "" || ref.Namespace == namespace)
+	}
+}
+
+func (PodParser) ListQuery(namespace string) Query {
+	q := Query{
+		Verbs:       mutatingVerbs,
+		URIContains: []string{"/api/v1/", "/pods"},
+	}
+	if namespace != "" {
+		q.URIContains = append(q.URIContains, "/namespaces/"+namespace+"/")
+	}
+	return q
 }
@@ -0,0 +1,63 @@
+package object
+
+import (
+	"testing"
+
+	"github.com/joinnis/kubereplay/pkg/test"
+)
+
+func TestPodParserExtract(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		event     test.Event
+		wantEvent EventType
+		wantNode  string
+	}{
+		{
+			name: "binding",
+			event: test.Event{
+				Verb: "create", Resource: "pods", Subresource: "binding", Namespace: "default", Name: "web", Time: atTime,
+				RequestObject: `{"kind":"Binding","apiVersion":"v1","metadata":{"name":"web"},"target":{"kind":"Node","name":"node-a"}}`,
+			},
+			wantEvent: EventTypePodBound,
+			wantNode:  "node-a",
+		},
+		{
+			name:      "eviction",
+			event:     test.Event{Verb: "create", Resource: "pods", Subresource: "eviction", Namespace: "default", Name: "web", Time: atTime},
+			wantEvent: EventTypePodEvicted,
+		},
+		{
+			name: "create in a namespace named like a binding",
+			event: test.Event{
+				Verb: "create", Resource: "pods", Namespace: "rolebinding-tests", Name: "web", Time: atTime,
+				ResponseObject: `{"kind":"Pod","apiVersion":"v1","metadata":{"namespace":"rolebinding-tests","name":"web","uid":"uid-1"}}`,
+			},
+			wantEvent: EventTypePodCreated,
+		},
+		{
+			name: "create of a pod named like an eviction",
+			event: test.Event{
+				Verb: "create", Resource: "pods", Namespace: "default", Name: "eviction-test", Time: atTime,
+				RequestURI:     "/api/v1/namespaces/default/pods?name=eviction-test",
+				ResponseObject: `{"kind":"Pod","apiVersion":"v1","metadata":{"namespace":"default","name":"eviction-test","uid":"uid-1"}}`,
+			},
+			wantEvent: EventTypePodCreated,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for e, err := range test.AuditEvents(t, tc.event) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				pe, err := PodParser{}.Extract(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pe.Event != tc.wantEvent || pe.AdditionalProperties["NodeName"] != tc.wantNode {
+					t.Errorf("got %q to node %q, want %q to node %q", pe.Event, pe.AdditionalProperties["NodeName"], tc.wantEvent, tc.wantNode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package object
+
+import "github.com/samber/lo"
+
+// mutatingVerbs are the verbs of the requests that change objects, which are the only ones that
+// parsers extract events from
+var mutatingVerbs = []string{"create", "update", "patch", "apply", "delete"}
+
+// Query describes the audit events that a command needs, for providers that push filtering down to
+// a query engine. Each provider renders it in its own query language. Like a Filter, it may match
+// more events than are relevant, but never fewer. An event matches when it matches every field that
+// is set.
+type Query struct {
+	// Verbs are the verbs that the request must have one of
+	Verbs []string
+	// URIs are alternatives that the request URI must match at least one of
+	URIs []URIMatch
+	// URIContains are substrings that the request URI must all contain, like the object's namespace
+	URIContains []string
+	// Contains is a substring that the event must contain anywhere, like the object's name
+	Contains string
+}
+
+// URIMatch matches the request URIs that contain every one of Contains and none of Excludes, of the
+// requests with one of Verbs when it's set
+type URIMatch struct {
+	Contains []string
+	Excludes []string
+	Verbs    []string
+	// SkipContains matches requests whether or not they contain the query's Contains, for requests
+	// that don't have to mention the object, like the deletes of the pods that were bound to a node
+	SkipContains bool
+}
+
+// Substrings returns substrings that every event matching the query contains at least one of, so
+// that events can be dropped before they're decoded. Alternatives that skip Contains are only known
+// to contain their verb. It returns nil when there's no such substring.
+func (q Query) Substrings() []string {
+	if q.Contains == "" {
+		return nil
+	}
+	substrings := []string{q.Contains}
+	for _, m := range q.URIs {
+		if !m.SkipContains {
+			continue
+		}
+		verbs := lo.CoalesceSliceOrEmpty(m.Verbs, q.Verbs)
+		if len(verbs) == 0 {
+			return nil
+		}
+		substrings = append(substrings, verbs...)
+	}
+	return lo.Uniq(substrings)
+}
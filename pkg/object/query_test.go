@@ -0,0 +1,46 @@
+package object
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestQuerySubstrings(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{
+			name:  "name",
+			query: PodParser{}.GetQuery(types.NamespacedName{Namespace: "default", Name: "web"}),
+			want:  []string{"web"},
+		},
+		{
+			name:  "deletes that skip the name",
+			query: NodeParser{}.DescribeQuery(types.NamespacedName{Name: "node-a"}),
+			want:  []string{"node-a", "delete"},
+		},
+		{
+			name:  "list",
+			query: PodParser{}.ListQuery("default"),
+		},
+		{
+			name:  "alternative that skips the name without verbs",
+			query: Query{URIs: []URIMatch{{Contains: []string{"pods"}, SkipContains: true}}, Contains: "web"},
+		},
+		{
+			name:  "alternative that skips the name with the query's verbs",
+			query: Query{Verbs: []string{"delete"}, URIs: []URIMatch{{Contains: []string{"pods"}, SkipContains: true}}, Contains: "web"},
+			want:  []string{"web", "delete"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.query.Substrings(); !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
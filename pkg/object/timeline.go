@@ -0,0 +1,65 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// EventsOf returns the events of the named object from a stream that may also hold the events of
+// the objects that it's described with
+func EventsOf(objectType ObjectType, nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]ParsedEvent, error) {
+	var matched []ParsedEvent
+	for e, err := range events {
+		if err != nil {
+			return nil, err
+		}
+		if e.ObjectType == objectType && e.NamespaceName == nn {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// FormatTimeline renders the events of several objects as one chronological table, with the time
+// that passed since the previous event on each row
+func FormatTimeline(events []ParsedEvent) string {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "TIMESTAMP\tDELTA\tOBJECT\tEVENT\tUSER\tDETAILS")
+	for i, e := range events {
+		delta := "-"
+		if i > 0 {
+			delta = "+" + e.Timestamp.Sub(events[i-1].Timestamp).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", formatTime(e.Timestamp), delta, objectName(e), e.Event, lo.CoalesceOrEmpty(e.User, "-"), eventDetails(e))
+	}
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+// objectName names the object of an event as <type>/[<namespace>/]<name>
+func objectName(e ParsedEvent) string {
+	return strings.ToLower(string(e.ObjectType)) + "/" + lo.Ternary(e.NamespaceName.Namespace == "", e.NamespaceName.Name, e.NamespaceName.String())
+}
+
+// eventDetails summarizes an event from the properties that were extracted with it, or from the
+// request that produced it when there are none
+func eventDetails(e ParsedEvent) string {
+	if len(e.AdditionalProperties) == 0 {
+		return lo.CoalesceOrEmpty(strings.Join(lo.Compact([]string{e.Verb, e.Subresource}), " "), "-")
+	}
+	keys := lo.Keys(e.AdditionalProperties)
+	sort.Strings(keys)
+	return strings.Join(lo.Map(keys, func(k string, _ int) string { return k + "=" + e.AdditionalProperties[k] }), ", ")
+}
@@ -0,0 +1,373 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// clusterScoped are the object types with a hand-written parser that aren't namespaced
+var clusterScoped = sets.New[ObjectType](ObjectTypeNode, ObjectTypeNodeClaim, ObjectTypeNodePool)
+
+// IsClusterScoped reports whether the objects of a parser are known not to be namespaced, which
+// they are for the hand-written cluster-scoped parsers and the built-in cluster-scoped resources
+func IsClusterScoped(parser ObjectParser) bool {
+	if u, ok := parser.(UnstructuredParser); ok {
+		return clusterScopedResources.Has(u.Resource)
+	}
+	return clusterScoped.Has(parser.ObjectType())
+}
+
+// NamespacedName names an object of a parser, dropping the namespace of objects that are known to
+// be cluster-scoped so that a default namespace doesn't have to be cleared for them
+func NamespacedName(parser ObjectParser, namespace, name string) types.NamespacedName {
+	if IsClusterScoped(parser) {
+		namespace = ""
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+// TreeNode is an object in a tree of objects related through ownerReferences and bindings
+type TreeNode struct {
+	Kind          string
+	NamespaceName types.NamespacedName
+	UID           types.UID
+	CreationTime  time.Time
+	DeletionTime  time.Time
+	// Relation is how the object relates to its parent in the tree. It's empty when the parent owns
+	// the object, "bound" for the Node that a Pod was bound to and "owner" for the owners of a Node.
+	Relation string
+	Children []*TreeNode
+}
+
+// Fetch returns the parsed events that a get or describe of the named object would be made from
+type Fetch func(parser ObjectParser, cmdType string, nn types.NamespacedName) iter.Seq2[ParsedEvent, error]
+
+// Tree returns a tree for each incarnation of the named object. The tree is rooted at the object's
+// top-most owner and goes down through its owners to the object, then through everything that the
+// object owns and the Nodes that its Pods were bound to, up to the owners of those Nodes. The events
+// of related objects are fetched as they are discovered.
+func Tree(parser ObjectParser, nn types.NamespacedName, fetch Fetch) ([]*TreeNode, error) {
+	b := &treeBuilder{fetch: fetch, fetched: sets.New[string]()}
+	if err := b.load(parser, "describe", nn); err != nil {
+		return nil, err
+	}
+	var trees []*TreeNode
+	for _, root := range b.graph.lookup(parser.ObjectType(), nn) {
+		subtree, err := b.subtree(root, "", sets.New[*graphObject]())
+		if err != nil {
+			return nil, err
+		}
+		seen := sets.New(root)
+		for o := root; ; {
+			ref := primaryOwner(o.owners)
+			if ref == nil {
+				break
+			}
+			owner, err := b.resolveOwner(*ref, o.nn.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			if seen.Has(owner) {
+				break
+			}
+			seen.Insert(owner)
+			parent := owner.treeNode("")
+			parent.Children = []*TreeNode{subtree}
+			subtree, o = parent, owner
+		}
+		trees = append(trees, subtree)
+	}
+	return trees, nil
+}
+
+// FormatTree renders a tree with the creation and deletion times of each object
+func FormatTree(tree *TreeNode) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tDELETED")
+	formatTreeNode(w, tree, "", "")
+	lo.Must0(w.Flush())
+	return buf.String()
+}
+
+func formatTreeNode(w *tabwriter.Writer, n *TreeNode, prefix, childPrefix string) {
+	name := n.Kind + "/" + n.NamespaceName.Name
+	if n.Relation != "" {
+		name += " (" + n.Relation + ")"
+	}
+	fmt.Fprintf(w, "%s%s\t%s\t%s\n", prefix, name, formatTime(n.CreationTime), formatTime(n.DeletionTime))
+	for i, c := range n.Children {
+		last := i == len(n.Children)-1
+		formatTreeNode(w, c, childPrefix+lo.Ternary(last, "└── ", "├── "), childPrefix+lo.Ternary(last, "    ", "│   "))
+	}
+}
+
+type treeBuilder struct {
+	graph   graph
+	fetch   Fetch
+	fetched sets.Set[string]
+}
+
+// load adds the events of a get or describe of the named object to the graph, unless they've
+// already been added
+func (b *treeBuilder) load(parser ObjectParser, cmdType string, nn types.NamespacedName) error {
+	key := fmt.Sprintf("%s/%s/%s", parser.ObjectType(), cmdType, nn)
+	if b.fetched.Has(key) {
+		return nil
+	}
+	b.fetched.Insert(key)
+	return b.graph.add(b.fetch(parser, cmdType, nn))
+}
+
+// subtree returns the tree of everything that o owns, with the Node that o was bound to if it's a
+// Pod. Workloads whose children weren't in the events so far are described to find them, and the
+// Node of a NodeClaim is looked up by the name in its status.
+func (b *treeBuilder) subtree(o *graphObject, relation string, visited sets.Set[*graphObject]) (*TreeNode, error) {
+	n := o.treeNode(relation)
+	if visited.Has(o) {
+		return n, nil
+	}
+	visited.Insert(o)
+	children := b.graph.children(o)
+	if len(children) == 0 {
+		var err error
+		if _, ok := workloadKinds[o.objectType]; ok {
+			err = b.load(WorkloadParser{Kind: o.objectType}, "describe", o.nn)
+		} else if o.objectType == ObjectTypeNodeClaim && o.nodeName != "" {
+			err = b.load(NodeParser{}, "get", types.NamespacedName{Name: o.nodeName})
+		}
+		if err != nil {
+			return nil, err
+		}
+		children = b.graph.children(o)
+	}
+	for _, c := range children {
+		child, err := b.subtree(c, "", visited)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, child)
+	}
+	if o.objectType == ObjectTypePod && o.nodeName != "" {
+		node, err := b.boundNode(o)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, node)
+	}
+	return n, nil
+}
+
+// boundNode returns the Node that a Pod was bound to, followed by the owners of the Node, like the
+// NodeClaim that it was launched for
+func (b *treeBuilder) boundNode(pod *graphObject) (*TreeNode, error) {
+	nn := types.NamespacedName{Name: pod.nodeName}
+	if err := b.load(NodeParser{}, "get", nn); err != nil {
+		return nil, err
+	}
+	// A Node name can be reused, so the Pod was bound to the latest Node created before it
+	nodes := b.graph.lookup(ObjectTypeNode, nn)
+	if len(nodes) == 0 {
+		nodes = []*graphObject{b.graph.placeholder(ObjectTypeNode, string(ObjectTypeNode), nn, "")}
+	}
+	node := nodes[0]
+	for _, n := range nodes[1:] {
+		if !n.creationTime.After(pod.creationTime) {
+			node = n
+		}
+	}
+	tree := node.treeNode("bound")
+	seen := sets.New(node)
+	for o, parent := node, tree; ; {
+		ref := primaryOwner(o.owners)
+		if ref == nil {
+			break
+		}
+		owner, err := b.resolveOwner(*ref, "")
+		if err != nil {
+			return nil, err
+		}
+		if seen.Has(owner) {
+			break
+		}
+		seen.Insert(owner)
+		child := owner.treeNode("owner")
+		parent.Children = append(parent.Children, child)
+		o, parent = owner, child
+	}
+	return tree, nil
+}
+
+// resolveOwner returns the object that an ownerReference points at, getting it when its events
+// weren't in the events so far. Owners of kinds without a hand-written parser are only known by
+// their ownerReference.
+func (b *treeBuilder) resolveOwner(ref metav1.OwnerReference, namespace string) (*graphObject, error) {
+	if o := b.graph.owner(ref); o != nil {
+		return o, nil
+	}
+	nn := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	if parser, ok := parserForKind(ref.Kind); ok {
+		if clusterScoped.Has(parser.ObjectType()) {
+			nn.Namespace = ""
+		}
+		if err := b.load(parser, "get", nn); err != nil {
+			return nil, err
+		}
+		if o := b.graph.owner(ref); o != nil {
+			return o, nil
+		}
+	}
+	return b.graph.placeholder(ObjectType(ref.Kind), ref.Kind, nn, ref.UID), nil
+}
+
+// parserForKind returns the hand-written parser of a kind
+func parserForKind(kind string) (ObjectParser, bool) {
+	return lo.Find(lo.Values(parsers), func(p ObjectParser) bool { return string(p.ObjectType()) == kind })
+}
+
+// primaryOwner returns the controller of an object, or its first owner if it has no controller
+func primaryOwner(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	if len(refs) == 0 {
+		return nil
+	}
+	if ref, ok := lo.Find(refs, func(r metav1.OwnerReference) bool { return lo.FromPtr(r.Controller) }); ok {
+		return &ref
+	}
+	return &refs[0]
+}
+
+// graph holds the objects of every type seen in a stream of parsed events, with just enough of their
+// state to relate them to each other
+type graph struct {
+	objects []*graphObject
+}
+
+type graphObject struct {
+	objectType   ObjectType
+	kind         string
+	nn           types.NamespacedName
+	uid          types.UID
+	creationTime time.Time
+	deletionTime time.Time
+	owners       []metav1.OwnerReference
+	// nodeName is the Node that a Pod was bound to, or that a NodeClaim registered
+	nodeName string
+
+	// snapshotTime is when the latest snapshot that owners were taken from was logged
+	snapshotTime time.Time
+}
+
+func (o *graphObject) treeNode(relation string) *TreeNode {
+	return &TreeNode{
+		Kind:          o.kind,
+		NamespaceName: o.nn,
+		UID:           o.uid,
+		CreationTime:  o.creationTime,
+		DeletionTime:  o.deletionTime,
+		Relation:      relation,
+	}
+}
+
+func (g *graph) add(events iter.Seq2[ParsedEvent, error]) error {
+	for e, err := range events {
+		if err != nil {
+			return err
+		}
+		// Nominations are Events about a Pod rather than changes to it
+		if e.Event == EventTypePodNominated {
+			continue
+		}
+		o := g.incarnation(e)
+		switch {
+		case e.Verb == "create" && e.Subresource == "":
+			if o.creationTime.IsZero() || e.Timestamp.Before(o.creationTime) {
+				o.creationTime = e.Timestamp
+			}
+		case e.Verb == "delete" && e.Subresource == "":
+			o.deletionTime = lo.Latest(o.deletionTime, e.Timestamp)
+		case e.Event == EventTypePodBound:
+			o.nodeName = e.AdditionalProperties["NodeName"]
+		}
+		if e.Object == nil || e.Timestamp.Before(o.snapshotTime) {
+			continue
+		}
+		o.snapshotTime = e.Timestamp
+		o.owners = e.Object.GetOwnerReferences()
+		if kind := e.Object.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+			o.kind = kind
+		}
+		if o.creationTime.IsZero() {
+			o.creationTime = e.Object.GetCreationTimestamp().Time
+		}
+		switch obj := e.Object.(type) {
+		case *v1.Pod:
+			o.nodeName = lo.CoalesceOrEmpty(obj.Spec.NodeName, o.nodeName)
+		case *karpv1.NodeClaim:
+			o.nodeName = lo.CoalesceOrEmpty(obj.Status.NodeName, o.nodeName)
+		}
+	}
+	return nil
+}
+
+// incarnation returns the object that an event belongs to, adding it to the graph if it's new.
+// Events without a UID belong to the latest incarnation of the named object.
+func (g *graph) incarnation(e ParsedEvent) *graphObject {
+	candidates := g.lookup(e.ObjectType, e.NamespaceName)
+	if e.UID == "" && len(candidates) > 0 {
+		return candidates[len(candidates)-1]
+	}
+	for _, o := range candidates {
+		if o.uid == e.UID {
+			return o
+		}
+		if o.uid == "" {
+			o.uid = e.UID
+			return o
+		}
+	}
+	return g.placeholder(e.ObjectType, string(e.ObjectType), e.NamespaceName, e.UID)
+}
+
+// placeholder adds an object that's only known by name to the graph
+func (g *graph) placeholder(objectType ObjectType, kind string, nn types.NamespacedName, uid types.UID) *graphObject {
+	o := &graphObject{objectType: objectType, kind: kind, nn: nn, uid: uid}
+	g.objects = append(g.objects, o)
+	return o
+}
+
+// lookup returns the incarnations of the named object, in order of creation
+func (g *graph) lookup(objectType ObjectType, nn types.NamespacedName) []*graphObject {
+	objs := lo.Filter(g.objects, func(o *graphObject, _ int) bool { return o.objectType == objectType && o.nn == nn })
+	sort.SliceStable(objs, func(i, j int) bool { return objs[i].creationTime.Before(objs[j].creationTime) })
+	return objs
+}
+
+// owner returns the object that an ownerReference points at, if it's in the graph
+func (g *graph) owner(ref metav1.OwnerReference) *graphObject {
+	o, _ := lo.Find(g.objects, func(o *graphObject) bool {
+		return o.kind == ref.Kind && (o.uid == ref.UID || o.uid == "" && o.nn.Name == ref.Name)
+	})
+	return o
+}
+
+// children returns the objects that o owns, in order of creation
+func (g *graph) children(o *graphObject) []*graphObject {
+	children := lo.Filter(g.objects, func(c *graphObject, _ int) bool {
+		return lo.ContainsBy(c.owners, func(ref metav1.OwnerReference) bool {
+			return ref.Kind == o.kind && (ref.UID == o.uid || o.uid == "" && ref.Name == o.nn.Name)
+		})
+	})
+	sort.SliceStable(children, func(i, j int) bool { return children[i].creationTime.Before(children[j].creationTime) })
+	return children
+}
@@ -0,0 +1,230 @@
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joinnis/kubereplay/pkg/test"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNamespacedName(t *testing.T) {
+	for _, tc := range []struct {
+		resource string
+		want     types.NamespacedName
+	}{
+		{resource: "pod", want: types.NamespacedName{Namespace: "default", Name: "web"}},
+		{resource: "deployment", want: types.NamespacedName{Namespace: "default", Name: "web"}},
+		{resource: "node", want: types.NamespacedName{Name: "web"}},
+		{resource: "nodes", want: types.NamespacedName{Name: "web"}},
+		{resource: "nodeclaim", want: types.NamespacedName{Name: "web"}},
+		{resource: "nodepools.karpenter.sh", want: types.NamespacedName{Name: "web"}},
+		{resource: "clusterroles.rbac.authorization.k8s.io", want: types.NamespacedName{Name: "web"}},
+		{resource: "namespaces", want: types.NamespacedName{Name: "web"}},
+		{resource: "configmaps", want: types.NamespacedName{Namespace: "default", Name: "web"}},
+		// Custom resources aren't known to be cluster-scoped, and keep the namespace they're given
+		{resource: "ec2nodeclasses.karpenter.k8s.aws", want: types.NamespacedName{Namespace: "default", Name: "web"}},
+	} {
+		if got := NamespacedName(NewObjectParserFrom(tc.resource), "default", "web"); got != tc.want {
+			t.Errorf("NamespacedName of %s is %s, want %s", tc.resource, got, tc.want)
+		}
+	}
+}
+
+// fetchFrom fetches the events of a get or describe from the audit events, like a provider would
+// read them from an audit log
+func fetchFrom(t *testing.T, events ...test.Event) Fetch {
+	return func(parser ObjectParser, cmdType string, nn types.NamespacedName) iter.Seq2[ParsedEvent, error] {
+		filter := lo.Ternary(cmdType == "describe", parser.DescribeFilter(nn), parser.GetFilter(nn))
+		return ParseEvents(filtered(test.AuditEvents(t, events...), filter))
+	}
+}
+
+// treeLines renders a tree with one indented line per object, naming its kind, UID and relation
+func treeLines(n *TreeNode, indent string) []string {
+	line := fmt.Sprintf("%s%s/%s %s", indent, n.Kind, n.NamespaceName.Name, n.UID)
+	if n.Relation != "" {
+		line += " (" + n.Relation + ")"
+	}
+	lines := []string{line}
+	for _, c := range n.Children {
+		lines = append(lines, treeLines(c, indent+"  ")...)
+	}
+	return lines
+}
+
+// createEvent is the create of an object, logged with its owner if it has one
+func createEvent(group, resource, kind, namespace, name string, uid types.UID, at time.Time, owner *metav1.OwnerReference, extra string) test.Event {
+	metadata := map[string]any{"name": name, "uid": uid}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if owner != nil {
+		metadata["ownerReferences"] = []metav1.OwnerReference{*owner}
+	}
+	apiVersion := lo.Ternary(group == "", "v1", group+"/v1")
+	return test.Event{
+		Verb: "create", APIGroup: group, Resource: resource, Namespace: namespace, Name: name, Time: at,
+		ResponseObject: fmt.Sprintf(`{"apiVersion":%q,"kind":%q,"metadata":%s%s}`, apiVersion, kind, lo.Must(json.Marshal(metadata)), extra),
+	}
+}
+
+func controller(apiVersion, kind, name string, uid types.UID) *metav1.OwnerReference {
+	return &metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: uid, Controller: lo.ToPtr(true)}
+}
+
+func bindingEvent(namespace, name, node string, at time.Time) test.Event {
+	return test.Event{
+		Verb: "create", Resource: "pods", Subresource: "binding", Namespace: namespace, Name: name, Time: at,
+		RequestObject: fmt.Sprintf(`{"kind":"Binding","apiVersion":"v1","metadata":{"name":%q},"target":{"kind":"Node","name":%q}}`, name, node),
+	}
+}
+
+func TestTree(t *testing.T) {
+	at := func(minutes int) time.Time { return atTime.Add(time.Duration(minutes) * time.Minute) }
+	deployment := []test.Event{
+		deploymentEvent("create", "alice", at(0), 2, "web:v1"),
+		replicaSetEvent("web-1", "1", at(1), "web:v1"),
+		replicaSetEvent("web-2", "2", at(2), "web:v2"),
+		ownedPodEvent("web-1-a", "web-1", at(1)),
+		ownedPodEvent("web-1-b", "web-1", at(1)),
+		ownedPodEvent("web-2-a", "web-2", at(2)),
+	}
+	cronJob := []test.Event{
+		createEvent("batch", "cronjobs", "CronJob", "default", "backup", "uid-backup", at(0), nil, ""),
+		createEvent("batch", "jobs", "Job", "default", "backup-1", "uid-backup-1", at(1), controller("batch/v1", "CronJob", "backup", "uid-backup"), ""),
+		createEvent("", "pods", "Pod", "default", "backup-1-a", "uid-backup-1-a", at(1), controller("batch/v1", "Job", "backup-1", "uid-backup-1"), ""),
+	}
+	// The node name is reused by a new incarnation that's launched for another NodeClaim, and each
+	// pod is bound to the incarnation that was running when it was created
+	nodes := []test.Event{
+		createEvent("karpenter.sh", "nodeclaims", "NodeClaim", "", "default-a", "uid-nc-a", at(0), nil, `,"status":{"nodeName":"node-a"}`),
+		createEvent("", "nodes", "Node", "", "node-a", "uid-node-1", at(1), controller("karpenter.sh/v1", "NodeClaim", "default-a", "uid-nc-a"), ""),
+		createEvent("", "pods", "Pod", "default", "old", "uid-old", at(2), nil, ""),
+		bindingEvent("default", "old", "node-a", at(2)),
+		{Verb: "delete", Resource: "nodes", Name: "node-a", Time: at(3)},
+		createEvent("karpenter.sh", "nodeclaims", "NodeClaim", "", "default-b", "uid-nc-b", at(4), nil, `,"status":{"nodeName":"node-a"}`),
+		createEvent("", "nodes", "Node", "", "node-a", "uid-node-2", at(5), controller("karpenter.sh/v1", "NodeClaim", "default-b", "uid-nc-b"), ""),
+		createEvent("", "pods", "Pod", "default", "new", "uid-new", at(6), nil, ""),
+		bindingEvent("default", "new", "node-a", at(6)),
+	}
+	for _, tc := range []struct {
+		name   string
+		parser ObjectParser
+		nn     types.NamespacedName
+		events []test.Event
+		want   []string
+	}{
+		{
+			name:   "deployment",
+			parser: WorkloadParser{Kind: ObjectTypeDeployment},
+			nn:     atNN,
+			events: deployment,
+			want: []string{
+				"Deployment/web uid-web",
+				"  ReplicaSet/web-1 uid-web-1",
+				"    Pod/web-1-a uid-web-1-a",
+				"    Pod/web-1-b uid-web-1-b",
+				"  ReplicaSet/web-2 uid-web-2",
+				"    Pod/web-2-a uid-web-2-a",
+			},
+		},
+		{
+			name:   "pod of a deployment",
+			parser: PodParser{},
+			nn:     types.NamespacedName{Namespace: "default", Name: "web-2-a"},
+			events: deployment,
+			want: []string{
+				"Deployment/web uid-web",
+				"  ReplicaSet/web-2 uid-web-2",
+				"    Pod/web-2-a uid-web-2-a",
+			},
+		},
+		{
+			name:   "job of a cronjob",
+			parser: WorkloadParser{Kind: ObjectTypeJob},
+			nn:     types.NamespacedName{Namespace: "default", Name: "backup-1"},
+			events: cronJob,
+			want: []string{
+				"CronJob/backup uid-backup",
+				"  Job/backup-1 uid-backup-1",
+				"    Pod/backup-1-a uid-backup-1-a",
+			},
+		},
+		{
+			name:   "cronjob",
+			parser: WorkloadParser{Kind: ObjectTypeCronJob},
+			nn:     types.NamespacedName{Namespace: "default", Name: "backup"},
+			events: cronJob,
+			want: []string{
+				"CronJob/backup uid-backup",
+				"  Job/backup-1 uid-backup-1",
+				"    Pod/backup-1-a uid-backup-1-a",
+			},
+		},
+		{
+			name:   "pod bound to a node of a nodeclaim",
+			parser: PodParser{},
+			nn:     types.NamespacedName{Namespace: "default", Name: "old"},
+			events: nodes,
+			want: []string{
+				"Pod/old uid-old",
+				"  Node/node-a uid-node-1 (bound)",
+				"    NodeClaim/default-a uid-nc-a (owner)",
+			},
+		},
+		{
+			name:   "pod bound to the new incarnation of a node",
+			parser: PodParser{},
+			nn:     types.NamespacedName{Namespace: "default", Name: "new"},
+			events: nodes,
+			want: []string{
+				"Pod/new uid-new",
+				"  Node/node-a uid-node-2 (bound)",
+				"    NodeClaim/default-b uid-nc-b (owner)",
+			},
+		},
+		{
+			name:   "nodeclaim",
+			parser: NodeClaimParser{},
+			nn:     types.NamespacedName{Name: "default-b"},
+			events: nodes,
+			want: []string{
+				"NodeClaim/default-b uid-nc-b",
+				"  Node/node-a uid-node-2",
+			},
+		},
+		{
+			name:   "both incarnations of a node",
+			parser: NodeParser{},
+			nn:     types.NamespacedName{Name: "node-a"},
+			events: nodes,
+			want: []string{
+				"NodeClaim/default-a uid-nc-a",
+				"  Node/node-a uid-node-1",
+				"NodeClaim/default-b uid-nc-b",
+				"  Node/node-a uid-node-2",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trees, err := Tree(tc.parser, tc.nn, fetchFrom(t, tc.events...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, tree := range trees {
+				got = append(got, treeLines(tree, "")...)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("got tree\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
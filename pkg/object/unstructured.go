@@ -0,0 +1,235 @@
+package object
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	EventTypeCreated = "Created"
+	EventTypeUpdated = "Updated"
+	EventTypeDeleted = "Deleted"
+)
+
+// parsers are the hand-written parsers, which reconstruct typed objects and describe them in more
+// detail. Every other resource is reconstructed as an unstructured object.
+var parsers = map[schema.GroupResource]ObjectParser{
+	{Resource: "pods"}:  PodParser{},
+	{Resource: "nodes"}: NodeParser{},
+	{Group: "karpenter.sh", Resource: "nodeclaims"}: NodeClaimParser{},
+	{Group: "karpenter.sh", Resource: "nodepools"}:  NodePoolParser{},
+	{Group: "apps", Resource: "deployments"}:        WorkloadParser{Kind: ObjectTypeDeployment},
+	{Group: "apps", Resource: "replicasets"}:        WorkloadParser{Kind: ObjectTypeReplicaSet},
+	{Group: "apps", Resource: "statefulsets"}:       WorkloadParser{Kind: ObjectTypeStatefulSet},
+	{Group: "apps", Resource: "daemonsets"}:         WorkloadParser{Kind: ObjectTypeDaemonSet},
+	{Group: "batch", Resource: "jobs"}:              WorkloadParser{Kind: ObjectTypeJob},
+	{Group: "batch", Resource: "cronjobs"}:          WorkloadParser{Kind: ObjectTypeCronJob},
+}
+
+// clusterScopedResources are the built-in resources without a hand-written parser that aren't
+// namespaced. Custom resources can't be told apart without the cluster's discovery API.
+var clusterScopedResources = sets.New(
+	schema.GroupResource{Resource: "namespaces"},
+	schema.GroupResource{Resource: "persistentvolumes"},
+	schema.GroupResource{Resource: "componentstatuses"},
+	schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+	schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"},
+	schema.GroupResource{Group: "storage.k8s.io", Resource: "storageclasses"},
+	schema.GroupResource{Group: "storage.k8s.io", Resource: "csidrivers"},
+	schema.GroupResource{Group: "storage.k8s.io", Resource: "csinodes"},
+	schema.GroupResource{Group: "storage.k8s.io", Resource: "volumeattachments"},
+	schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"},
+	schema.GroupResource{Group: "apiregistration.k8s.io", Resource: "apiservices"},
+	schema.GroupResource{Group: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"},
+	schema.GroupResource{Group: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations"},
+	schema.GroupResource{Group: "admissionregistration.k8s.io", Resource: "validatingadmissionpolicies"},
+	schema.GroupResource{Group: "admissionregistration.k8s.io", Resource: "validatingadmissionpolicybindings"},
+	schema.GroupResource{Group: "scheduling.k8s.io", Resource: "priorityclasses"},
+	schema.GroupResource{Group: "node.k8s.io", Resource: "runtimeclasses"},
+	schema.GroupResource{Group: "networking.k8s.io", Resource: "ingressclasses"},
+	schema.GroupResource{Group: "certificates.k8s.io", Resource: "certificatesigningrequests"},
+	schema.GroupResource{Group: "flowcontrol.apiserver.k8s.io", Resource: "flowschemas"},
+	schema.GroupResource{Group: "flowcontrol.apiserver.k8s.io", Resource: "prioritylevelconfigurations"},
+)
+
+// ParserFor returns the parser for a resource, which is the hand-written parser if there is one
+func ParserFor(gr schema.GroupResource) ObjectParser {
+	if parser, ok := parsers[gr]; ok {
+		return parser
+	}
+	return UnstructuredParser{Resource: gr}
+}
+
+// objectSubresources are the subresources whose requests and responses are the object itself,
+// rather than another kind like a Scale
+var objectSubresources = sets.New("", "status", "ephemeralcontainers", "resize")
+
+// resourceExtractor reconstructs the objects of a resource from the create, update, patch, apply and
+// delete events that the audit log has for them
+type resourceExtractor struct {
+	objectType ObjectType
+	newObject  func() client.Object
+	created    EventType
+	updated    EventType
+	deleted    EventType
+}
+
+func (r resourceExtractor) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	pe := ParsedEvent{
+		Timestamp:            event.RequestReceivedTimestamp.Time,
+		ObjectType:           r.objectType,
+		UID:                  uidOf(event),
+		AdditionalProperties: map[string]string{},
+	}
+	// Objects created with generateName don't have a name in their objectRef, so the name is taken
+	// from the logged object whenever there is one
+	refName := types.NamespacedName{Namespace: event.ObjectRef.Namespace, Name: event.ObjectRef.Name}
+	switch {
+	case !objectSubresources.Has(event.ObjectRef.Subresource):
+		return ParsedEvent{}, nil
+	case event.Verb == "create":
+		pe.Event = r.created
+	case event.Verb == "update" || event.Verb == "patch" || event.Verb == "apply":
+		pe.Event = r.updated
+		if event.Verb != "update" && isEmptyObject(event.ResponseObject) {
+			patch, err := newPatch(event)
+			if err != nil {
+				return ParsedEvent{}, err
+			}
+			pe.Patch = patch
+			pe.NamespaceName = refName
+			return pe, nil
+		}
+	case event.Verb == "delete":
+		pe.Event = r.deleted
+		pe.NamespaceName = refName
+		return pe, nil
+	default:
+		return ParsedEvent{}, nil
+	}
+	obj := r.newObject()
+	if err := decodeObject(event, event.ResponseObject, obj); err != nil {
+		return ParsedEvent{}, err
+	}
+	pe.Object = obj
+	pe.NamespaceName = client.ObjectKeyFromObject(obj)
+	return pe, nil
+}
+
+// Unstructured is an object of a resource that doesn't have a hand-written parser
+type Unstructured struct {
+	Object   *unstructured.Unstructured
+	Resource schema.GroupResource
+	lifetime
+}
+
+func (u Unstructured) Describe() string {
+	title := fmt.Sprintf("%s %s", u.Resource, lo.Ternary(u.NamespaceName.Namespace == "", u.NamespaceName.Name, u.NamespaceName.String()))
+	kind := "N/A"
+	if u.Object != nil {
+		kind = u.Object.GetKind()
+	}
+	return fmt.Sprintf(`
+%s
+%s
+Kind: %s
+UID: %s
+
+CreationTime: %s
+LastUpdatedTime: %s
+DeletionTime: %s
+`,
+		title,
+		strings.Repeat("-", len(title)),
+		kind,
+		lo.Ternary(u.UID == "", "N/A", string(u.UID)),
+		formatTime(u.CreationTime),
+		formatTime(u.LastUpdatedTime),
+		formatTime(u.DeletionTime),
+	)
+}
+
+func (u Unstructured) Get() string {
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(u.Snapshot()))))
+}
+
+func (u Unstructured) Snapshot() client.Object {
+	if u.Object == nil {
+		return nil
+	}
+	return u.Object
+}
+
+func (u *Unstructured) apply(e ParsedEvent) {
+	if u.observe(e, EventTypeCreated, EventTypeUpdated, EventTypeDeleted) {
+		u.Object = e.Object.(*unstructured.Unstructured)
+	}
+}
+
+// UnstructuredParser reconstructs the objects of any resource, including custom resources, as
+// unstructured objects. Cluster-scoped objects are looked up with an empty namespace.
+type UnstructuredParser struct {
+	Resource schema.GroupResource
+}
+
+func (p UnstructuredParser) ObjectType() ObjectType {
+	return ObjectType(p.Resource.String())
+}
+
+func (p UnstructuredParser) Coalesce(nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]Object, error) {
+	objs, err := coalesceIncarnations(nn, p.ObjectType(), events, func(uid types.UID) *Unstructured {
+		return &Unstructured{Resource: p.Resource, lifetime: lifetime{NamespaceName: nn, UID: uid}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(objs, func(u *Unstructured, _ int) Object { return *u }), nil
+}
+
+func (p UnstructuredParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	return resourceExtractor{
+		objectType: p.ObjectType(),
+		newObject:  func() client.Object { return &unstructured.Unstructured{} },
+		created:    EventTypeCreated,
+		updated:    EventTypeUpdated,
+		deleted:    EventTypeDeleted,
+	}.Extract(event)
+}
+
+func (p UnstructuredParser) DescribeFilter(nn types.NamespacedName) Filter {
+	return p.GetFilter(nn)
+}
+
+func (p UnstructuredParser) GetFilter(nn types.NamespacedName) Filter {
+	return func(ref *auditmodel.ObjectReference) bool {
+		return ref.Resource == p.Resource.Resource && ref.APIGroup == p.Resource.Group && ref.Namespace == nn.Namespace &&
+			(ref.Name == "" || ref.Name == nn.Name)
+	}
+}
+
+func (p UnstructuredParser) DescribeQuery(nn types.NamespacedName) Query {
+	return p.GetQuery(nn)
+}
+
+func (p UnstructuredParser) GetQuery(nn types.NamespacedName) Query {
+	// Core resources are served under /api and every other group under /apis/<group>
+	q := Query{
+		Verbs:       mutatingVerbs,
+		URIContains: []string{lo.Ternary(p.Resource.Group == "", "/api/", "/apis/"+p.Resource.Group+"/"), "/" + p.Resource.Resource},
+		Contains:    nn.Name,
+	}
+	if nn.Namespace != "" {
+		q.URIContains = append(q.URIContains, "/namespaces/"+nn.Namespace+"/")
+	}
+	return q
+}
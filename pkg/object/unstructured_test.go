@@ -0,0 +1,143 @@
+package object
+
+import (
+	"iter"
+	"slices"
+	"testing"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// filtered keeps the audit events that a filter matches, like a provider does before parsing them
+func filtered(events iter.Seq2[auditmodel.Event, error], filter Filter) iter.Seq2[auditmodel.Event, error] {
+	return func(yield func(auditmodel.Event, error) bool) {
+		for e, err := range events {
+			if err == nil && !filter(e.ObjectRef) {
+				continue
+			}
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+}
+
+// coalesceUnstructured coalesces the only object that the audit events have of a resource named
+// like the get command takes it
+func coalesceUnstructured(t *testing.T, resource, namespace, name string, events ...test.Event) Unstructured {
+	t.Helper()
+	parser := NewObjectParserFrom(resource)
+	nn := NamespacedName(parser, namespace, name)
+	objs, err := parser.Coalesce(nn, ParseEvents(filtered(test.AuditEvents(t, events...), parser.GetFilter(nn))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("got %d objects, want 1", len(objs))
+	}
+	return objs[0].(Unstructured)
+}
+
+func TestUnstructuredParserNamespacedCustomResource(t *testing.T) {
+	at := func(minutes int) time.Time { return atTime.Add(time.Duration(minutes) * time.Minute) }
+	u := coalesceUnstructured(t, "rollouts.argoproj.io", "default", "web",
+		test.Event{
+			Verb: "create", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "default", Name: "web", Time: at(0),
+			ResponseObject: `{"apiVersion":"argoproj.io/v1alpha1","kind":"Rollout","metadata":{"name":"web","namespace":"default","uid":"uid-1"},"spec":{"replicas":2}}`,
+		},
+		// A rollout with the same name in another namespace
+		test.Event{
+			Verb: "create", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "other", Name: "web", Time: at(1),
+			ResponseObject: `{"apiVersion":"argoproj.io/v1alpha1","kind":"Rollout","metadata":{"name":"web","namespace":"other","uid":"uid-2"},"spec":{"replicas":9}}`,
+		},
+		test.Event{
+			Verb: "update", APIGroup: "argoproj.io", Resource: "rollouts", Subresource: "status", Namespace: "default", Name: "web", Time: at(2),
+			ResponseObject: `{"apiVersion":"argoproj.io/v1alpha1","kind":"Rollout","metadata":{"name":"web","namespace":"default","uid":"uid-1"},"spec":{"replicas":2},"status":{"phase":"Healthy"}}`,
+		},
+		test.Event{Verb: "delete", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "default", Name: "web", Time: at(3)},
+	)
+	if u.UID != "uid-1" || !u.CreationTime.Equal(at(0)) || !u.LastUpdatedTime.Equal(at(2)) || !u.DeletionTime.Equal(at(3)) {
+		t.Errorf("got UID %q created at %s, updated at %s and deleted at %s", u.UID, u.CreationTime, u.LastUpdatedTime, u.DeletionTime)
+	}
+	if u.Object.GetKind() != "Rollout" {
+		t.Errorf("got kind %q, want Rollout", u.Object.GetKind())
+	}
+	if phase, _, _ := unstructured.NestedString(u.Object.Object, "status", "phase"); phase != "Healthy" {
+		t.Errorf("got phase %q, want Healthy", phase)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(u.Object.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("got %d replicas, want 2", replicas)
+	}
+}
+
+func TestUnstructuredParserClusterScopedBuiltIn(t *testing.T) {
+	// The cluster role is looked up without the namespace it's asked for in
+	u := coalesceUnstructured(t, "clusterroles.rbac.authorization.k8s.io", "default", "admin",
+		test.Event{
+			Verb: "create", APIGroup: "rbac.authorization.k8s.io", Resource: "roles", Namespace: "default", Name: "admin", Time: atTime,
+			ResponseObject: `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"admin","namespace":"default","uid":"uid-1"}}`,
+		},
+		test.Event{
+			Verb: "create", APIGroup: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "admin", Time: atTime,
+			ResponseObject: `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"admin","uid":"uid-2"}}`,
+		},
+	)
+	if u.NamespaceName != (types.NamespacedName{Name: "admin"}) || u.UID != "uid-2" || u.Object.GetKind() != "ClusterRole" {
+		t.Errorf("got %s %s with UID %q, want ClusterRole admin with UID uid-2", u.Object.GetKind(), u.NamespaceName, u.UID)
+	}
+}
+
+func TestUnstructuredParserPatchWithoutSchema(t *testing.T) {
+	create := test.Event{
+		Verb: "create", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "default", Name: "web", Time: atTime,
+		ResponseObject: `{"apiVersion":"argoproj.io/v1alpha1","kind":"Rollout","metadata":{"name":"web","namespace":"default","uid":"uid-1","finalizers":["a","b"]},"spec":{"replicas":2}}`,
+	}
+	for _, tc := range []struct {
+		name  string
+		patch test.Event
+	}{
+		{
+			name: "merge patch",
+			patch: test.Event{
+				Verb: "patch", Level: "Request", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "default", Name: "web", Time: atTime.Add(time.Minute),
+				RequestObject: `{"metadata":{"finalizers":["a"]},"spec":{"replicas":3}}`,
+			},
+		},
+		{
+			// The custom resource has no schema to strategically merge the patch with
+			name: "strategic merge patch",
+			patch: test.Event{
+				Verb: "patch", Level: "Request", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "default", Name: "web", Time: atTime.Add(time.Minute),
+				RequestObject: `{"metadata":{"$setElementOrder/finalizers":["a"],"finalizers":["a"]},"spec":{"replicas":3}}`,
+			},
+		},
+		{
+			name: "apply",
+			patch: test.Event{
+				Verb: "apply", Level: "Request", APIGroup: "argoproj.io", Resource: "rollouts", Namespace: "default", Name: "web", Time: atTime.Add(time.Minute),
+				RequestObject: `{"apiVersion":"argoproj.io/v1alpha1","kind":"Rollout","metadata":{"name":"web","finalizers":["a"]},"spec":{"replicas":3}}`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u := coalesceUnstructured(t, "rollouts.argoproj.io", "default", "web", create, tc.patch)
+			if !u.LastUpdatedTime.Equal(atTime.Add(time.Minute)) {
+				t.Errorf("got last update at %s, want %s", u.LastUpdatedTime, atTime.Add(time.Minute))
+			}
+			// Like a JSON merge patch, the list is replaced rather than merged
+			if finalizers := u.Object.GetFinalizers(); !slices.Equal(finalizers, []string{"a"}) {
+				t.Errorf("got finalizers %v, want [a]", finalizers)
+			}
+			if replicas, _, _ := unstructured.NestedInt64(u.Object.Object, "spec", "replicas"); replicas != 3 {
+				t.Errorf("got %d replicas, want 3", replicas)
+			}
+			if u.Object.GetUID() != "uid-1" {
+				t.Errorf("got UID %q, want uid-1", u.Object.GetUID())
+			}
+		})
+	}
+}
@@ -0,0 +1,675 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	EventTypeScaled = "Scaled"
+)
+
+// workloadKind describes how the objects of a workload resource are reconstructed and related to
+// the objects that they own
+type workloadKind struct {
+	resource  schema.GroupResource
+	newObject func() client.Object
+	// templatePath is the path of the template that the workload makes its children from, or nil if
+	// the template can't be changed
+	templatePath []string
+	// scalable is set for workloads with spec.replicas and a scale subresource
+	scalable bool
+	// childType is the type of the objects that the workload owns
+	childType ObjectType
+	// childResources are the resources whose events are needed to describe the workload's children
+	childResources []schema.GroupResource
+	// revisionAnnotation is the annotation of the children that numbers the rollout that made them,
+	// if the children are made from the workload's template
+	revisionAnnotation string
+	// suspendPath is the path of the field that pauses the workload, if it can be paused
+	suspendPath []string
+}
+
+var (
+	replicaSets = schema.GroupResource{Group: "apps", Resource: "replicasets"}
+	pods        = schema.GroupResource{Resource: "pods"}
+	jobs        = schema.GroupResource{Group: "batch", Resource: "jobs"}
+)
+
+var workloadKinds = map[ObjectType]workloadKind{
+	ObjectTypeDeployment: {
+		resource:           schema.GroupResource{Group: "apps", Resource: "deployments"},
+		newObject:          func() client.Object { return &appsv1.Deployment{} },
+		templatePath:       []string{"spec", "template"},
+		scalable:           true,
+		childType:          ObjectTypeReplicaSet,
+		childResources:     []schema.GroupResource{replicaSets, pods},
+		revisionAnnotation: "deployment.kubernetes.io/revision",
+		suspendPath:        []string{"spec", "paused"},
+	},
+	ObjectTypeReplicaSet: {
+		resource:       replicaSets,
+		newObject:      func() client.Object { return &appsv1.ReplicaSet{} },
+		scalable:       true,
+		childType:      ObjectTypePod,
+		childResources: []schema.GroupResource{pods},
+	},
+	ObjectTypeStatefulSet: {
+		resource:       schema.GroupResource{Group: "apps", Resource: "statefulsets"},
+		newObject:      func() client.Object { return &appsv1.StatefulSet{} },
+		templatePath:   []string{"spec", "template"},
+		scalable:       true,
+		childType:      ObjectTypePod,
+		childResources: []schema.GroupResource{pods},
+	},
+	ObjectTypeDaemonSet: {
+		resource:       schema.GroupResource{Group: "apps", Resource: "daemonsets"},
+		newObject:      func() client.Object { return &appsv1.DaemonSet{} },
+		templatePath:   []string{"spec", "template"},
+		childType:      ObjectTypePod,
+		childResources: []schema.GroupResource{pods},
+	},
+	ObjectTypeJob: {
+		resource:       jobs,
+		newObject:      func() client.Object { return &batchv1.Job{} },
+		childType:      ObjectTypePod,
+		childResources: []schema.GroupResource{pods},
+		suspendPath:    []string{"spec", "suspend"},
+	},
+	ObjectTypeCronJob: {
+		resource:       schema.GroupResource{Group: "batch", Resource: "cronjobs"},
+		newObject:      func() client.Object { return &batchv1.CronJob{} },
+		templatePath:   []string{"spec", "jobTemplate"},
+		childType:      ObjectTypeJob,
+		childResources: []schema.GroupResource{jobs},
+		suspendPath:    []string{"spec", "suspend"},
+	},
+}
+
+// WorkloadTypes returns the workload kinds that have their own parser, in alphabetical order
+func WorkloadTypes() []ObjectType {
+	kinds := lo.Keys(workloadKinds)
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// Workload is an object of one of the workload resources, which own the objects that they make
+// from their template, like the ReplicaSets of a Deployment or the Pods of a ReplicaSet
+type Workload struct {
+	Object client.Object
+	Kind   ObjectType
+	lifetime
+
+	// Lifecycle is the workload's lifecycle, oldest first
+	Lifecycle []Transition
+	// Rollouts are the changes to the workload's template, starting with the template it was created
+	// with, oldest first
+	Rollouts []Rollout
+	// Scales are the changes to the workload's replicas, oldest first
+	Scales []ScaleChange
+	// Children are the objects that the workload owns, in order of creation
+	Children []Child
+
+	// reorder holds back the latest snapshots and writes to the scale subresource to fold them into
+	// the workload's history in order
+	reorder reorderBuffer
+	// prev is the latest snapshot that was folded into the history, and prevObject the object it was
+	// converted from
+	prev        map[string]interface{}
+	prevObject  client.Object
+	transitions []Transition
+	// replicas are the replicas of the latest folded snapshot or scale, if there was one
+	replicas *int64
+}
+
+// Rollout is a change to the template of a workload
+type Rollout struct {
+	Timestamp time.Time
+	// User is who made the change
+	User string
+	// Changes are the paths that changed in the template, empty for the template the workload was
+	// created with
+	Changes []string
+	// Child is the owned object that was made from the new template, like the ReplicaSet of a
+	// Deployment, if it's known
+	Child string
+	// Revision is the revision that the rollout is numbered with on its child, if it's known
+	Revision string
+
+	// template is the workload's template after the rollout, to match it with its child
+	template map[string]interface{}
+}
+
+// ScaleChange is a change to the replicas of a workload
+type ScaleChange struct {
+	Timestamp time.Time
+	// User is who made the change
+	User string
+	// From is the number of replicas before the change, or nil if no earlier state was logged
+	From *int64
+	To   int64
+}
+
+// Child is an object that a workload owns, found through the ownerReferences of the object
+type Child struct {
+	Name         string
+	UID          types.UID
+	CreationTime time.Time `json:",omitzero"`
+	DeletionTime time.Time `json:",omitzero"`
+	// Pods are the number of pods that were created for the child, for the ReplicaSets of a Deployment
+	Pods int
+
+	// ownerUID is the UID of the incarnation of the workload that owns the child
+	ownerUID types.UID
+	// object is the latest logged state of the child
+	object client.Object
+}
+
+func (w Workload) Describe() string {
+	kind := workloadKinds[w.Kind]
+	title := fmt.Sprintf("%s %s", w.Kind, w.NamespaceName)
+	owner := "N/A"
+	if w.Object != nil {
+		if ref := metav1.GetControllerOf(w.Object); ref != nil {
+			owner = ref.Kind + "/" + ref.Name
+		}
+	}
+	sections := []string{fmt.Sprintf(`
+%s
+%s
+UID: %s
+ControlledBy: %s
+
+CreationTime: %s
+LastUpdatedTime: %s
+DeletionTime: %s
+`,
+		title,
+		strings.Repeat("-", len(title)),
+		lo.Ternary(w.UID == "", "N/A", string(w.UID)),
+		owner,
+		formatTime(w.CreationTime),
+		formatTime(w.LastUpdatedTime),
+		formatTime(w.DeletionTime),
+	)}
+	sections = append(sections, formatSection("Lifecycle", FormatTransitions(w.Lifecycle)))
+	if kind.templatePath != nil {
+		sections = append(sections, formatSection("Rollouts", w.formatRollouts()))
+	}
+	if kind.scalable {
+		sections = append(sections, formatSection("Scaling", w.formatScales()))
+	}
+	sections = append(sections, formatSection(string(kind.childType)+"s", w.formatChildren()))
+	return strings.Join(sections, "\n")
+}
+
+func formatSection(title, body string) string {
+	return fmt.Sprintf("%s\n%s\n%s", title, strings.Repeat("-", len(title)), body)
+}
+
+func (w Workload) formatRollouts() string {
+	kind := workloadKinds[w.Kind]
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	if kind.revisionAnnotation != "" {
+		fmt.Fprintf(tw, "REVISION\tTIMESTAMP\tUSER\t%s\tCHANGES\n", strings.ToUpper(string(kind.childType)))
+	} else {
+		fmt.Fprintln(tw, "TIMESTAMP\tUSER\tCHANGES")
+	}
+	for _, r := range w.Rollouts {
+		changes := lo.Ternary(len(r.Changes) == 0, "Created", strings.Join(r.Changes, ", "))
+		if kind.revisionAnnotation != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", lo.CoalesceOrEmpty(r.Revision, "-"), formatTime(r.Timestamp), lo.CoalesceOrEmpty(r.User, "-"), lo.CoalesceOrEmpty(r.Child, "-"), changes)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", formatTime(r.Timestamp), lo.CoalesceOrEmpty(r.User, "-"), changes)
+		}
+	}
+	lo.Must0(tw.Flush())
+	return buf.String()
+}
+
+func (w Workload) formatScales() string {
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "TIMESTAMP\tUSER\tREPLICAS")
+	for _, s := range w.Scales {
+		from := lo.Ternary(s.From == nil, "?", fmt.Sprint(lo.FromPtr(s.From)))
+		fmt.Fprintf(tw, "%s\t%s\t%s -> %d\n", formatTime(s.Timestamp), lo.CoalesceOrEmpty(s.User, "-"), from, s.To)
+	}
+	lo.Must0(tw.Flush())
+	return buf.String()
+}
+
+func (w Workload) formatChildren() string {
+	kind := workloadKinds[w.Kind]
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 8, 3, ' ', 0)
+	header := strings.ToUpper(string(kind.childType)) + "\tUID\tCREATED\tDELETED"
+	fmt.Fprintln(tw, lo.Ternary(kind.childType == ObjectTypeReplicaSet, header+"\tPODS", header))
+	for _, c := range w.Children {
+		row := fmt.Sprintf("%s\t%s\t%s\t%s", c.Name, lo.CoalesceOrEmpty(string(c.UID), "N/A"), formatTime(c.CreationTime), formatTime(c.DeletionTime))
+		fmt.Fprintln(tw, lo.Ternary(kind.childType == ObjectTypeReplicaSet, fmt.Sprintf("%s\t%d", row, c.Pods), row))
+	}
+	lo.Must0(tw.Flush())
+	return buf.String()
+}
+
+func (w Workload) Get() string {
+	return string(lo.Must(yaml.Marshal(WithoutManagedFields(w.Snapshot()))))
+}
+
+func (w Workload) Snapshot() client.Object {
+	if w.Object == nil {
+		return nil
+	}
+	return w.Object
+}
+
+func (w *Workload) apply(e ParsedEvent) {
+	if e.Event == EventTypeScaled {
+		w.LastUpdatedTime = lo.Latest(w.LastUpdatedTime, e.Timestamp)
+	}
+	if e.Event == EventTypeScaled || e.Object != nil {
+		w.reorder.push(e, w.fold)
+	}
+	if w.observe(e, EventTypeCreated, EventTypeUpdated, EventTypeDeleted) {
+		w.Object = e.Object
+	}
+}
+
+// fold adds the lifecycle transitions, rollouts and scale changes since the previous snapshot to the
+// workload's history. Writes to the scale subresource don't log the workload, so they're folded in by
+// time and take precedence over the snapshot that later shows the same replicas.
+func (w *Workload) fold(e ParsedEvent) {
+	kind := workloadKinds[w.Kind]
+	if e.Object == nil {
+		w.replicas = w.scale(e.Timestamp, e.User, w.replicas, e.AdditionalProperties["Replicas"])
+		return
+	}
+	cur := toUnstructured(e.Object)
+	if kind.scalable {
+		if r, ok, _ := unstructured.NestedInt64(cur, "spec", "replicas"); ok {
+			if w.replicas == nil || *w.replicas != r {
+				if w.replicas != nil {
+					w.Scales = append(w.Scales, ScaleChange{Timestamp: e.Timestamp, User: e.User, From: w.replicas, To: r})
+				}
+				w.replicas = lo.ToPtr(r)
+			}
+		}
+	}
+	if kind.templatePath != nil {
+		template, _, _ := unstructured.NestedMap(cur, kind.templatePath...)
+		switch {
+		case w.prev == nil && e.Verb == "create":
+			w.Rollouts = append(w.Rollouts, Rollout{Timestamp: e.Timestamp, User: e.User, template: template})
+		case w.prev != nil:
+			prefix := strings.Join(kind.templatePath, ".") + "."
+			if paths := lo.Filter(ChangedPaths(w.prevObject, e.Object, 0), func(p string, _ int) bool { return strings.HasPrefix(p, prefix) }); len(paths) > 0 {
+				w.Rollouts = append(w.Rollouts, Rollout{Timestamp: e.Timestamp, User: e.User, Changes: paths, template: template})
+			}
+		}
+	}
+	w.transitions = append(w.transitions, workloadTransitions(kind, w.prev, cur, e)...)
+	w.prev, w.prevObject = cur, e.Object
+}
+
+// history finishes the lifecycle, rollouts and scale changes of the workload by folding in the events
+// that are still held back
+func (w *Workload) history() {
+	w.reorder.flush(w.fold)
+	w.Lifecycle = withLifetime(w.lifetime, w.transitions, Transition{})
+}
+
+// scale records a write to the scale subresource and returns the replicas that it set
+func (w *Workload) scale(ts time.Time, user string, from *int64, to string) *int64 {
+	var r int64
+	if _, err := fmt.Sscan(to, &r); err != nil {
+		return from
+	}
+	w.Scales = append(w.Scales, ScaleChange{Timestamp: ts, User: user, From: from, To: r})
+	return lo.ToPtr(r)
+}
+
+// workloadTransitions returns the transitions of a workload between two consecutive snapshots: its
+// conditions changing status and it being paused or resumed
+func workloadTransitions(kind workloadKind, prev, cur map[string]interface{}, e ParsedEvent) []Transition {
+	var transitions []Transition
+	if kind.suspendPath != nil {
+		prevSuspended, _, _ := unstructured.NestedBool(prev, kind.suspendPath...)
+		suspended, _, _ := unstructured.NestedBool(cur, kind.suspendPath...)
+		if prevSuspended != suspended && (prev != nil || suspended) {
+			transitions = append(transitions, Transition{Timestamp: e.Timestamp, Event: lo.Ternary(suspended, "Suspended", "Resumed"), Details: e.User})
+		}
+	}
+	prevConditions := conditionsOf(prev)
+	for _, c := range lo.Values(conditionsOf(cur)) {
+		if p, ok := prevConditions[c.Type]; ok && p.Status == c.Status {
+			continue
+		}
+		transitions = append(transitions, Transition{
+			Timestamp: lo.Ternary(c.LastTransitionTime.IsZero(), e.Timestamp, c.LastTransitionTime.Time),
+			Event:     lo.Ternary(c.Status == metav1.ConditionTrue, c.Type, lo.Ternary(c.Status == metav1.ConditionFalse, "Not"+c.Type, c.Type+"Unknown")),
+			Details:   strings.Join(lo.Compact([]string{c.Reason, c.Message}), ": "),
+		})
+	}
+	sortTransitions(transitions)
+	return transitions
+}
+
+// conditionsOf returns the status conditions of an object by type. The workload resources each have
+// their own condition type, but they share the fields that matter here.
+func conditionsOf(obj map[string]interface{}) map[string]metav1.Condition {
+	raw, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+	var conditions []metav1.Condition
+	if err := json.Unmarshal(lo.Must(json.Marshal(raw)), &conditions); err != nil {
+		return nil
+	}
+	return lo.SliceToMap(conditions, func(c metav1.Condition) (string, metav1.Condition) { return c.Type, c })
+}
+
+// ownedObjects collects the objects that are owned by workloads of a kind as the events are
+// streamed. Owners are found from the controller ownerReference of the objects' logged state, and
+// deletes that didn't log the object are matched by name, so the times of every object are held on
+// to until one of its states was logged.
+type ownedObjects struct {
+	ownerKind ObjectType
+	// byName holds the incarnations of the objects by name, as the pods of StatefulSets are
+	// re-created with the same name
+	byName map[string][]*ownedObject
+}
+
+type ownedObject struct {
+	Child
+	// owner is the name of the workload that owns the object, once ownerKnown is set by a logged state
+	owner      string
+	ownerKnown bool
+	// objectTime is when the object was logged, as the latest logged state is kept
+	objectTime time.Time
+}
+
+func newOwnedObjects(ownerKind ObjectType) *ownedObjects {
+	return &ownedObjects{ownerKind: ownerKind, byName: map[string][]*ownedObject{}}
+}
+
+func (o *ownedObjects) add(e ParsedEvent) {
+	name := e.NamespaceName.Name
+	// Events without a UID are for the latest incarnation, and the others for the incarnation with
+	// their UID, or for one whose UID isn't known yet
+	var obj *ownedObject
+	for _, c := range o.byName[name] {
+		if e.UID == "" || c.UID == e.UID || (c.UID == "" && obj == nil) {
+			obj = c
+		}
+	}
+	if obj == nil {
+		obj = &ownedObject{Child: Child{Name: name}}
+		o.byName[name] = append(o.byName[name], obj)
+	}
+	obj.UID = lo.CoalesceOrEmpty(obj.UID, e.UID)
+	if e.Object != nil {
+		if !obj.ownerKnown {
+			obj.ownerKnown = true
+			if ref := metav1.GetControllerOf(e.Object); ref != nil && ref.Kind == string(o.ownerKind) {
+				obj.owner, obj.ownerUID = ref.Name, ref.UID
+			}
+		}
+		// Only the objects of the workload are shown, so the others don't need their state
+		if obj.owner != "" && !e.Timestamp.Before(obj.objectTime) {
+			obj.object, obj.objectTime = e.Object, e.Timestamp
+		}
+		if obj.CreationTime.IsZero() {
+			obj.CreationTime = e.Object.GetCreationTimestamp().Time
+		}
+	}
+	switch {
+	case e.Verb == "create" && e.Subresource == "":
+		obj.CreationTime = e.Timestamp
+	case e.Verb == "delete" && (obj.DeletionTime.IsZero() || e.Timestamp.Before(obj.DeletionTime)):
+		obj.DeletionTime = e.Timestamp
+	}
+}
+
+// ownedBy returns the objects that the named workload owns, in order of creation
+func (o *ownedObjects) ownedBy(ownerName string) []Child {
+	var children []Child
+	for _, incarnations := range o.byName {
+		for _, c := range incarnations {
+			if c.owner == ownerName {
+				children = append(children, c.Child)
+			}
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if !children[i].CreationTime.Equal(children[j].CreationTime) {
+			return children[i].CreationTime.Before(children[j].CreationTime)
+		}
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+// matchRollouts finds the child that each rollout made, which is the child whose template is the
+// workload's template at the time of the rollout. A rollback to an earlier template reuses the
+// child that was made from it.
+func (w *Workload) matchRollouts() {
+	kind := workloadKinds[w.Kind]
+	if kind.revisionAnnotation == "" {
+		return
+	}
+	for i, r := range w.Rollouts {
+		for _, c := range w.Children {
+			if c.object == nil {
+				continue
+			}
+			childTemplate, _, _ := unstructured.NestedMap(toUnstructured(c.object), kind.templatePath...)
+			// The Deployment controller labels the template of its ReplicaSets with the hash of the template
+			unstructured.RemoveNestedField(childTemplate, "metadata", "labels", appsv1.DefaultDeploymentUniqueLabelKey)
+			if labels, _, _ := unstructured.NestedMap(childTemplate, "metadata", "labels"); len(labels) == 0 {
+				unstructured.RemoveNestedField(childTemplate, "metadata", "labels")
+			}
+			if reflect.DeepEqual(r.template, childTemplate) {
+				w.Rollouts[i].Child = c.Name
+				w.Rollouts[i].Revision = c.object.GetAnnotations()[kind.revisionAnnotation]
+			}
+		}
+	}
+}
+
+// WorkloadParser reconstructs the objects of one of the workload resources, along with the objects
+// that they own
+type WorkloadParser struct {
+	Kind ObjectType
+}
+
+func (p WorkloadParser) ObjectType() ObjectType {
+	return p.Kind
+}
+
+func (p WorkloadParser) Coalesce(nn types.NamespacedName, events iter.Seq2[ParsedEvent, error]) ([]Object, error) {
+	kind := workloadKinds[p.Kind]
+	// The events of the workload's children, and the pods of a Deployment's ReplicaSets, are only in
+	// the stream when describing the workload
+	owned, ownedPods := newOwnedObjects(p.Kind), newOwnedObjects(ObjectTypeReplicaSet)
+	events = divertEvents(events, kind.childType, owned.add)
+	if kind.childType == ObjectTypeReplicaSet {
+		events = divertEvents(events, ObjectTypePod, ownedPods.add)
+	}
+	workloads, err := coalesceIncarnations(nn, p.Kind, events, func(uid types.UID) *Workload {
+		return &Workload{Kind: p.Kind, lifetime: lifetime{NamespaceName: nn, UID: uid}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	children := owned.ownedBy(nn.Name)
+	for i, c := range children {
+		children[i].Pods = len(ownedPods.ownedBy(c.Name))
+	}
+	if len(workloads) == 0 && len(children) > 0 {
+		workloads = []*Workload{{Kind: p.Kind, lifetime: lifetime{NamespaceName: nn}}}
+	}
+	for _, c := range children {
+		// Children belong to the incarnation that owns them, or to the latest incarnation that was
+		// created before them when the owner's UID isn't known
+		workload, ok := lo.Find(workloads, func(w *Workload) bool { return w.UID != "" && w.UID == c.ownerUID })
+		if !ok {
+			workload = workloads[0]
+			for _, w := range workloads[1:] {
+				if !w.CreationTime.After(c.CreationTime) {
+					workload = w
+				}
+			}
+		}
+		workload.Children = append(workload.Children, c)
+	}
+	for _, w := range workloads {
+		w.history()
+		w.matchRollouts()
+	}
+	return lo.Map(workloads, func(w *Workload, _ int) Object { return *w }), nil
+}
+
+func (p WorkloadParser) Extract(event auditmodel.Event) (ParsedEvent, error) {
+	kind := workloadKinds[p.Kind]
+	if kind.scalable && event.ObjectRef.Subresource == "scale" {
+		return extractScale(p.Kind, event)
+	}
+	return resourceExtractor{
+		objectType: p.Kind,
+		newObject:  kind.newObject,
+		created:    EventTypeCreated,
+		updated:    EventTypeUpdated,
+		deleted:    EventTypeDeleted,
+	}.Extract(event)
+}
+
+// extractScale extracts a write to the scale subresource of a workload. The replicas are read from
+// the Scale that was logged, or from the result of applying the patch when the response wasn't
+// logged.
+func extractScale(objectType ObjectType, event auditmodel.Event) (ParsedEvent, error) {
+	if event.Verb != "update" && event.Verb != "patch" && event.Verb != "apply" {
+		return ParsedEvent{}, nil
+	}
+	obj := lo.Ternary(isEmptyObject(event.ResponseObject), event.RequestObject, event.ResponseObject)
+	if isEmptyObject(obj) {
+		return ParsedEvent{}, nil
+	}
+	var replicas *int64
+	if isEmptyObject(event.ResponseObject) && event.Verb != "update" {
+		var err error
+		if replicas, err = patchedReplicas(event); err != nil {
+			return ParsedEvent{}, err
+		}
+	} else {
+		var scale struct {
+			Spec struct {
+				Replicas *int64 `json:"replicas"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal(obj, &scale); err != nil {
+			return ParsedEvent{}, fmt.Errorf("decoding scale, %w", err)
+		}
+		replicas = scale.Spec.Replicas
+	}
+	// Replicas that are still unknown after a patch, or that the API server would have rejected,
+	// aren't a scale of the workload
+	if replicas == nil || *replicas < 0 {
+		return ParsedEvent{}, nil
+	}
+	return ParsedEvent{
+		Timestamp:            event.RequestReceivedTimestamp.Time,
+		ObjectType:           objectType,
+		UID:                  uidOf(event),
+		NamespaceName:        types.NamespacedName{Namespace: event.ObjectRef.Namespace, Name: event.ObjectRef.Name},
+		Event:                EventTypeScaled,
+		AdditionalProperties: map[string]string{"Replicas": fmt.Sprint(*replicas)},
+	}, nil
+}
+
+// unknownReplicas stands in for the replicas of a Scale that wasn't logged, so that JSON patches
+// that replace them have something to replace. It's negative, so it's never mistaken for replicas
+// that a patch set.
+const unknownReplicas = int64(-1)
+
+// patchedReplicas returns the replicas that a patch or apply of the scale subresource set, or nil if
+// it didn't set them. The Scale before the request isn't known, so the patch is applied to one
+// whose replicas are unknownReplicas.
+func patchedReplicas(event auditmodel.Event) (*int64, error) {
+	patch, err := newPatch(event)
+	if err != nil {
+		return nil, err
+	}
+	scale, err := patch.Apply(&unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "autoscaling/v1",
+		"kind":       "Scale",
+		"metadata":   map[string]any{"name": event.ObjectRef.Name, "namespace": event.ObjectRef.Namespace},
+		"spec":       map[string]any{"replicas": unknownReplicas},
+	}})
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		// The test may well have passed against the Scale that wasn't logged
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("patching scale, %w", err)
+	}
+	replicas, ok, _ := unstructured.NestedInt64(scale.(*unstructured.Unstructured).Object, "spec", "replicas")
+	if !ok {
+		return nil, nil
+	}
+	return &replicas, nil
+}
+
+func (p WorkloadParser) DescribeFilter(nn types.NamespacedName) Filter {
+	get := p.GetFilter(nn)
+	kind := workloadKinds[p.Kind]
+	return func(ref *auditmodel.ObjectReference) bool {
+		// Children are named after their owner, but created with generateName, so they're matched on
+		// namespace and found through their ownerReferences
+		return get(ref) || (ref.Namespace == nn.Namespace && lo.Contains(kind.childResources, schema.GroupResource{Group: ref.APIGroup, Resource: ref.Resource}))
+	}
+}
+
+func (p WorkloadParser) GetFilter(nn types.NamespacedName) Filter {
+	kind := workloadKinds[p.Kind]
+	return func(ref *auditmodel.ObjectReference) bool {
+		return ref.Resource == kind.resource.Resource && ref.APIGroup == kind.resource.Group && ref.Namespace == nn.Namespace &&
+			(ref.Name == "" || ref.Name == nn.Name)
+	}
+}
+
+func (p WorkloadParser) DescribeQuery(nn types.NamespacedName) Query {
+	kind := workloadKinds[p.Kind]
+	return workloadQuery(nn, append([]schema.GroupResource{kind.resource}, kind.childResources...))
+}
+
+func (p WorkloadParser) GetQuery(nn types.NamespacedName) Query {
+	return workloadQuery(nn, []schema.GroupResource{workloadKinds[p.Kind].resource})
+}
+
+func workloadQuery(nn types.NamespacedName, resources []schema.GroupResource) Query {
+	return Query{
+		Verbs:       mutatingVerbs,
+		URIs:        lo.Map(resources, func(gr schema.GroupResource, _ int) URIMatch { return URIMatch{Contains: []string{"/" + gr.Resource}} }),
+		URIContains: []string{"/namespaces/" + nn.Namespace + "/"},
+		Contains:    nn.Name,
+	}
+}
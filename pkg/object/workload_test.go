@@ -0,0 +1,238 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/joinnis/kubereplay/pkg/test"
+	"github.com/samber/lo"
+)
+
+func TestExtractScale(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		verb     string
+		request  string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "logged response",
+			verb:     "patch",
+			request:  `[{"op":"replace","path":"/spec/replicas","value":3}]`,
+			response: `{"apiVersion":"autoscaling/v1","kind":"Scale","spec":{"replicas":3},"status":{"replicas":1}}`,
+			want:     "3",
+		},
+		{
+			name:    "update",
+			verb:    "update",
+			request: `{"apiVersion":"autoscaling/v1","kind":"Scale","spec":{"replicas":2}}`,
+			want:    "2",
+		},
+		{
+			name:    "merge patch",
+			verb:    "patch",
+			request: `{"spec":{"replicas":4}}`,
+			want:    "4",
+		},
+		{
+			name:    "json patch",
+			verb:    "patch",
+			request: `[{"op":"replace","path":"/spec/replicas","value":5}]`,
+			want:    "5",
+		},
+		{
+			name:    "json patch that adds the replicas",
+			verb:    "patch",
+			request: `[{"op":"add","path":"/spec/replicas","value":0}]`,
+			want:    "0",
+		},
+		{
+			name:    "apply",
+			verb:    "apply",
+			request: `{"apiVersion":"autoscaling/v1","kind":"Scale","spec":{"replicas":6}}`,
+			want:    "6",
+		},
+		{
+			name:    "json patch of other fields",
+			verb:    "patch",
+			request: `[{"op":"add","path":"/metadata/labels","value":{"a":"b"}}]`,
+		},
+		{
+			name:    "json patch that doesn't apply",
+			verb:    "patch",
+			request: `[{"op":"replace","path":"/status/selector","value":"a=b"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "json patch that tests the replicas that weren't logged",
+			verb:    "patch",
+			request: `[{"op":"test","path":"/spec/replicas","value":2},{"op":"replace","path":"/spec/replicas","value":3}]`,
+		},
+		{
+			name:    "json patch that copies the replicas that weren't logged",
+			verb:    "patch",
+			request: `[{"op":"copy","from":"/spec/replicas","path":"/spec/previous"}]`,
+		},
+		{
+			name:    "merge patch that removes the replicas",
+			verb:    "patch",
+			request: `{"spec":{"replicas":null}}`,
+		},
+		{
+			name:    "negative replicas",
+			verb:    "update",
+			request: `{"apiVersion":"autoscaling/v1","kind":"Scale","spec":{"replicas":-1}}`,
+		},
+		{
+			name: "not logged",
+			verb: "patch",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := auditmodel.Event{
+				Verb:           tc.verb,
+				ObjectRef:      &auditmodel.ObjectReference{Resource: "deployments", Subresource: "scale", Namespace: "default", Name: "web"},
+				RequestObject:  []byte(tc.request),
+				ResponseObject: []byte(tc.response),
+			}
+			pe, err := extractScale(ObjectTypeDeployment, event)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if got := pe.AdditionalProperties["Replicas"]; got != tc.want {
+				t.Errorf("got replicas %q, want %q", got, tc.want)
+			}
+			if tc.want != "" && pe.Event != EventTypeScaled {
+				t.Errorf("got event %q, want %q", pe.Event, EventTypeScaled)
+			}
+		})
+	}
+}
+
+// deploymentEvent is an event of the Deployment web, logged with its replicas and the image of its template
+func deploymentEvent(verb, user string, at time.Time, replicas int, image string) test.Event {
+	return test.Event{
+		Verb: verb, APIGroup: "apps", Resource: "deployments", Namespace: "default", Name: "web", User: user, Time: at,
+		ResponseObject: fmt.Sprintf(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"default","uid":"uid-web"},`+
+			`"spec":{"replicas":%d,"template":{"metadata":{"labels":{"app":"web"}},"spec":{"containers":[{"name":"web","image":%q}]}}}}`, replicas, image),
+	}
+}
+
+// replicaSetEvent is the create of a ReplicaSet that the Deployment web made from a template with the image
+func replicaSetEvent(name, revision string, at time.Time, image string) test.Event {
+	return test.Event{
+		Verb: "create", APIGroup: "apps", Resource: "replicasets", Namespace: "default", Name: name, Time: at,
+		ResponseObject: fmt.Sprintf(`{"apiVersion":"apps/v1","kind":"ReplicaSet","metadata":{"name":%q,"namespace":"default","uid":"uid-%s",`+
+			`"annotations":{"deployment.kubernetes.io/revision":%q},"ownerReferences":[{"apiVersion":"apps/v1","kind":"Deployment","name":"web","uid":"uid-web","controller":true}]},`+
+			`"spec":{"template":{"metadata":{"labels":{"app":"web","pod-template-hash":%q}},"spec":{"containers":[{"name":"web","image":%q}]}}}}`,
+			name, name, revision, strings.TrimPrefix(name, "web-"), image),
+	}
+}
+
+// ownedPodEvent is the create of a pod that the ReplicaSet owner controls, or of a pod without an owner
+func ownedPodEvent(name, owner string, at time.Time) test.Event {
+	refs := ""
+	if owner != "" {
+		refs = fmt.Sprintf(`,"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":%q,"uid":"uid-%s","controller":true}]`, owner, owner)
+	}
+	return test.Event{
+		Verb: "create", Resource: "pods", Namespace: "default", Name: name, Time: at,
+		ResponseObject: fmt.Sprintf(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":%q,"namespace":"default","uid":"uid-%s"%s}}`, name, name, refs),
+	}
+}
+
+func scaleEvent(user string, at time.Time, replicas int) test.Event {
+	return test.Event{
+		Verb: "update", APIGroup: "apps", Resource: "deployments", Subresource: "scale", Namespace: "default", Name: "web", User: user, Time: at,
+		ResponseObject: fmt.Sprintf(`{"apiVersion":"autoscaling/v1","kind":"Scale","metadata":{"name":"web","namespace":"default"},"spec":{"replicas":%d}}`, replicas),
+	}
+}
+
+func describeDeployment(t *testing.T, events ...test.Event) Workload {
+	t.Helper()
+	objs, err := WorkloadParser{Kind: ObjectTypeDeployment}.Coalesce(atNN, ParseEvents(test.AuditEvents(t, events...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("got %d deployments, want 1", len(objs))
+	}
+	return objs[0].(Workload)
+}
+
+func TestWorkloadRollouts(t *testing.T) {
+	at := func(minutes int) time.Time { return atTime.Add(time.Duration(minutes) * time.Minute) }
+	w := describeDeployment(t,
+		deploymentEvent("create", "alice", at(0), 2, "web:v1"),
+		replicaSetEvent("web-1", "1", at(0).Add(time.Second), "web:v1"),
+		deploymentEvent("update", "bob", at(1), 2, "web:v2"),
+		replicaSetEvent("web-2", "2", at(1).Add(time.Second), "web:v2"),
+		// An update that doesn't touch the template isn't a rollout
+		deploymentEvent("update", "carol", at(2), 3, "web:v2"),
+		// The rollback reuses the ReplicaSet of the first template
+		deploymentEvent("update", "bob", at(3), 3, "web:v1"),
+	)
+	got := lo.Map(w.Rollouts, func(r Rollout, _ int) string {
+		return fmt.Sprintf("%s %s %s %s %s", r.Timestamp.Format(time.TimeOnly), r.User, r.Child, r.Revision, strings.Join(r.Changes, ","))
+	})
+	want := []string{
+		"16:00:00 alice web-1 1 ",
+		"16:01:00 bob web-2 2 spec.template.spec.containers[0].image",
+		"16:03:00 bob web-1 1 spec.template.spec.containers[0].image",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got rollouts\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWorkloadScales(t *testing.T) {
+	at := func(minutes int) time.Time { return atTime.Add(time.Duration(minutes) * time.Minute) }
+	w := describeDeployment(t,
+		deploymentEvent("create", "alice", at(0), 2, "web:v1"),
+		// The scale is logged after the update that follows it, and is merged in by time
+		deploymentEvent("update", "bob", at(2), 3, "web:v2"),
+		scaleEvent("hpa", at(1), 3),
+		deploymentEvent("update", "carol", at(3), 5, "web:v2"),
+		// A scale after the last logged state of the Deployment
+		scaleEvent("hpa", at(4), 1),
+	)
+	got := lo.Map(w.Scales, func(s ScaleChange, _ int) string {
+		return fmt.Sprintf("%s %s %d -> %d", s.Timestamp.Format(time.TimeOnly), s.User, lo.FromPtr(s.From), s.To)
+	})
+	// The update that shows the replicas that the scale set isn't another change
+	want := []string{
+		"16:01:00 hpa 2 -> 3",
+		"16:03:00 carol 3 -> 5",
+		"16:04:00 hpa 5 -> 1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got scales\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !w.LastUpdatedTime.Equal(at(4)) {
+		t.Errorf("got last update at %s, want %s", w.LastUpdatedTime, at(4))
+	}
+}
+
+func TestWorkloadChildPods(t *testing.T) {
+	at := func(minutes int) time.Time { return atTime.Add(time.Duration(minutes) * time.Minute) }
+	w := describeDeployment(t,
+		deploymentEvent("create", "alice", at(0), 2, "web:v1"),
+		replicaSetEvent("web-1", "1", at(1), "web:v1"),
+		replicaSetEvent("web-2", "2", at(2), "web:v2"),
+		ownedPodEvent("web-1-a", "web-1", at(1)),
+		ownedPodEvent("web-1-b", "web-1", at(1)),
+		ownedPodEvent("web-2-a", "web-2", at(2)),
+		// Pods of another ReplicaSet and without an owner aren't counted
+		ownedPodEvent("api-1-a", "api-1", at(2)),
+		ownedPodEvent("debug", "", at(2)),
+	)
+	got := lo.Map(w.Children, func(c Child, _ int) string { return fmt.Sprintf("%s %d", c.Name, c.Pods) })
+	if want := []string{"web-1 2", "web-2 1"}; strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got children %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,320 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/joinnis/kubereplay/pkg/object"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Formats are the output formats that can be given with -o, kubectl style. The formats that take
+// an argument are given as <format>=<argument>.
+var Formats = []string{"yaml", "json", "wide", "name", "jsonpath=...", "go-template=...", "custom-columns=..."}
+
+// Item is something to print. Data is what the structured formats render, like the latest state of
+// an object for get, or the data that its description is made from for describe.
+type Item struct {
+	// Resource is the resource of the item in the <kind>[.<group>] form that kubectl prints
+	Resource string
+	Name     string
+	Data     any
+}
+
+// View holds the ways that a command renders its items as text
+type View struct {
+	// Text renders the items when no output format is given
+	Text func() string
+	// Wide renders the items as a table with extra columns, or is nil if there's no such table
+	Wide func() string
+	// Table is set when Text renders a table, whose header is left out with --no-headers like the
+	// headers of the wide and custom-columns tables
+	Table bool
+}
+
+// Printer prints items in the output format that was given with -o
+type Printer struct {
+	format   string
+	jsonPath *jsonpath.JSONPath
+	template *template.Template
+	columns  []column
+	// noHeaders leaves the header row out of tables
+	noHeaders bool
+	// showManagedFields keeps the managedFields of objects in the structured formats
+	showManagedFields bool
+}
+
+type column struct {
+	header string
+	path   *jsonpath.JSONPath
+}
+
+// AddFlags adds the -o flag, and the flags that tune the output formats, to a command and its
+// subcommands
+func AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringP("output", "o", "", fmt.Sprintf("Output format. One of: (%s)", strings.Join(Formats, ", ")))
+	cmd.PersistentFlags().Bool("no-headers", false, "Leave the header row out of tables, including wide and custom-columns output")
+	cmd.PersistentFlags().Bool("show-managed-fields", false, "Keep the managedFields of objects when printing them in JSON, YAML or a template format")
+}
+
+func FromFlags(cmd *cobra.Command) (Printer, error) {
+	output, _ := cmd.Flags().GetString("output")
+	p, err := New(output)
+	if err != nil {
+		return Printer{}, err
+	}
+	p.noHeaders, _ = cmd.Flags().GetBool("no-headers")
+	p.showManagedFields, _ = cmd.Flags().GetBool("show-managed-fields")
+	return p, nil
+}
+
+// New returns the printer of an output format, parsing the argument of the formats that take one
+func New(output string) (Printer, error) {
+	format, arg, hasArg := strings.Cut(output, "=")
+	p := Printer{format: format}
+	var err error
+	switch format {
+	case "", "yaml", "json", "wide", "name":
+		if hasArg {
+			return Printer{}, fmt.Errorf("output format %s doesn't take an argument", format)
+		}
+	case "jsonpath":
+		p.jsonPath, err = parseJSONPath("jsonpath", arg)
+	case "go-template":
+		p.template, err = template.New("output").Parse(arg)
+	case "custom-columns":
+		p.columns, err = parseColumns(arg)
+	default:
+		return Printer{}, fmt.Errorf("unsupported output format %q, supported formats are %s", output, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return Printer{}, fmt.Errorf("parsing %s output format, %w", format, err)
+	}
+	return p, nil
+}
+
+// parseJSONPath parses a JSONPath expression, wrapping it in braces like kubectl does when it's
+// given without them
+func parseJSONPath(name, expr string) (*jsonpath.JSONPath, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("missing expression")
+	}
+	if !strings.Contains(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	j := jsonpath.New(name).AllowMissingKeys(true)
+	if err := j.Parse(expr); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// parseColumns parses custom columns given as <header>:<jsonpath>[,<header>:<jsonpath>...]
+func parseColumns(spec string) ([]column, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("missing columns")
+	}
+	var columns []column
+	for _, c := range strings.Split(spec, ",") {
+		header, expr, ok := strings.Cut(c, ":")
+		if !ok || header == "" {
+			return nil, fmt.Errorf("column %q isn't in the <header>:<jsonpath> form", c)
+		}
+		path, err := parseJSONPath(header, expr)
+		if err != nil {
+			return nil, fmt.Errorf("column %s, %w", header, err)
+		}
+		columns = append(columns, column{header: header, path: path})
+	}
+	return columns, nil
+}
+
+// PrintObject prints a single item
+func (p Printer) PrintObject(w io.Writer, item Item, view View) error {
+	item = p.prepare(item)
+	return p.print(w, []Item{item}, item.Data, view)
+}
+
+// PrintList prints a list of items. The structured formats render them as a kubectl style List.
+func (p Printer) PrintList(w io.Writer, items []Item, view View) error {
+	items = lo.Map(items, func(item Item, _ int) Item { return p.prepare(item) })
+	list := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      lo.Map(items, func(item Item, _ int) any { return item.Data }),
+	}
+	return p.print(w, items, list, view)
+}
+
+func (p Printer) print(w io.Writer, items []Item, data any, view View) error {
+	switch p.format {
+	case "":
+		_, err := fmt.Fprint(w, lo.Ternary(view.Table, p.table(view.Text()), view.Text()))
+		return err
+	case "wide":
+		if view.Wide == nil {
+			return fmt.Errorf("output format wide isn't supported by this command")
+		}
+		_, err := fmt.Fprint(w, p.table(view.Wide()))
+		return err
+	case "name":
+		for _, item := range items {
+			if _, err := fmt.Fprintf(w, "%s/%s\n", item.Resource, item.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		out, err := json.MarshalIndent(data, "", "    ")
+		if err != nil {
+			return fmt.Errorf("marshaling json, %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	case "yaml":
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("marshaling yaml, %w", err)
+		}
+		_, err = fmt.Fprint(w, string(out))
+		return err
+	}
+	// The remaining formats walk the data as plain JSON values, the way that kubectl does with
+	// unstructured objects
+	generic, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+	switch p.format {
+	case "jsonpath":
+		if err := p.jsonPath.Execute(w, generic); err != nil {
+			return fmt.Errorf("executing jsonpath, %w", err)
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	case "go-template":
+		if err := p.template.Execute(w, generic); err != nil {
+			return fmt.Errorf("executing go-template, %w", err)
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	case "custom-columns":
+		rows := make([]any, 0, len(items))
+		for _, item := range items {
+			row, err := toGeneric(item.Data)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+		return p.printColumns(w, rows)
+	}
+	return nil
+}
+
+func (p Printer) printColumns(w io.Writer, items []any) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	if !p.noHeaders {
+		fmt.Fprintln(tw, strings.Join(lo.Map(p.columns, func(c column, _ int) string { return c.header }), "\t"))
+	}
+	for _, item := range items {
+		var cells []string
+		for _, c := range p.columns {
+			results, err := c.path.FindResults(item)
+			if err != nil {
+				return fmt.Errorf("column %s, %w", c.header, err)
+			}
+			var values []string
+			for _, r := range results {
+				for _, v := range r {
+					buf := &bytes.Buffer{}
+					if err := c.path.PrintResults(buf, []reflect.Value{v}); err != nil {
+						return fmt.Errorf("column %s, %w", c.header, err)
+					}
+					values = append(values, buf.String())
+				}
+			}
+			cells = append(cells, lo.CoalesceOrEmpty(strings.Join(values, ","), "<none>"))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// table renders a table, leaving out its header row with --no-headers
+func (p Printer) table(table string) string {
+	if !p.noHeaders {
+		return table
+	}
+	_, rows, _ := strings.Cut(table, "\n")
+	return rows
+}
+
+// prepare readies an item for printing, leaving the managedFields out of its data unless they were
+// asked for
+func (p Printer) prepare(item Item) Item {
+	if !p.showManagedFields {
+		item.Data = withoutManagedFields(item.Data)
+	}
+	return item
+}
+
+// withoutManagedFields leaves the managedFields out of data, which is either an object or a struct
+// that holds objects in its fields, like the description of an object
+func withoutManagedFields(data any) any {
+	if obj, ok := data.(client.Object); ok {
+		if v := reflect.ValueOf(obj); v.Kind() == reflect.Pointer && v.IsNil() {
+			return data
+		}
+		return object.WithoutManagedFields(obj)
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Struct {
+		return data
+	}
+	out := reflect.New(v.Type()).Elem()
+	out.Set(v)
+	for i := range out.NumField() {
+		f := out.Field(i)
+		if !f.CanSet() || (f.Kind() != reflect.Pointer && f.Kind() != reflect.Interface) || f.IsNil() {
+			continue
+		}
+		if obj, ok := f.Interface().(client.Object); ok {
+			f.Set(reflect.ValueOf(withoutManagedFields(obj)))
+		}
+	}
+	return out.Interface()
+}
+
+func toGeneric(data any) (any, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling json, %w", err)
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("unmarshaling json, %w", err)
+	}
+	return generic, nil
+}
+
+// ResourceOf returns the resource of an object in the <kind>[.<group>] form that kubectl prints
+// with -o name, from the kind of its snapshot or from its parser when it has no snapshot
+func ResourceOf(parser object.ObjectParser, snapshot client.Object) string {
+	if snapshot != nil {
+		if gvk := snapshot.GetObjectKind().GroupVersionKind(); gvk.Kind != "" {
+			return strings.ToLower(gvk.Kind) + lo.Ternary(gvk.Group == "", "", "."+gvk.Group)
+		}
+	}
+	return strings.ToLower(string(parser.ObjectType()))
+}
@@ -0,0 +1,287 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPod(name, node string) *v1.Pod {
+	return &v1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:     "default",
+			Name:          name,
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate}},
+		},
+		Spec: v1.PodSpec{NodeName: node},
+	}
+}
+
+var testView = View{
+	Text: func() string { return "NAME   NODE\nweb    node-a\n" },
+	Wide: func() string { return "NAME   NODE     UID\nweb    node-a   uid-1\n" },
+}
+
+func TestPrintObject(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		output            string
+		noHeaders         bool
+		showManagedFields bool
+		data              any
+		want              []string
+		wantNot           []string
+	}{
+		{
+			name:   "text",
+			output: "",
+			want:   []string{"NAME   NODE\nweb    node-a\n"},
+		},
+		{
+			name:    "json",
+			output:  "json",
+			want:    []string{`"kind": "Pod"`, `"name": "web"`, `"nodeName": "node-a"`},
+			wantNot: []string{"managedFields"},
+		},
+		{
+			name:    "yaml",
+			output:  "yaml",
+			want:    []string{"kind: Pod\n", "  name: web\n", "  nodeName: node-a\n"},
+			wantNot: []string{"managedFields"},
+		},
+		{
+			name:   "name",
+			output: "name",
+			want:   []string{"pod/web\n"},
+		},
+		{
+			name:   "jsonpath",
+			output: "jsonpath={.metadata.name} on {.spec.nodeName}",
+			want:   []string{"web on node-a\n"},
+		},
+		{
+			name:   "jsonpath without braces",
+			output: "jsonpath=.metadata.name",
+			want:   []string{"web\n"},
+		},
+		{
+			name:   "go-template",
+			output: "go-template={{.metadata.name}} on {{.spec.nodeName}}",
+			want:   []string{"web on node-a\n"},
+		},
+		{
+			name:   "custom-columns",
+			output: "custom-columns=NAME:.metadata.name,NODE:.spec.nodeName,IP:.status.podIP",
+			want:   []string{"NAME   NODE     IP\nweb    node-a   <none>\n"},
+		},
+		{
+			name:      "custom-columns without headers",
+			output:    "custom-columns=NAME:.metadata.name,NODE:.spec.nodeName",
+			noHeaders: true,
+			want:      []string{"web   node-a\n"},
+			wantNot:   []string{"NAME"},
+		},
+		{
+			name:   "wide",
+			output: "wide",
+			want:   []string{"NAME   NODE     UID\nweb    node-a   uid-1\n"},
+		},
+		{
+			name:      "wide without headers",
+			output:    "wide",
+			noHeaders: true,
+			want:      []string{"web    node-a   uid-1\n"},
+			wantNot:   []string{"NAME"},
+		},
+		{
+			// The text of a single object isn't a table, so it's left as it is
+			name:      "text without headers",
+			output:    "",
+			noHeaders: true,
+			want:      []string{"NAME   NODE\nweb    node-a\n"},
+		},
+		{
+			name:              "json with managed fields",
+			output:            "json",
+			showManagedFields: true,
+			want:              []string{`"managedFields"`, `"manager": "kubectl"`},
+		},
+		{
+			name:              "yaml with managed fields",
+			output:            "yaml",
+			showManagedFields: true,
+			want:              []string{"managedFields:\n", "manager: kubectl\n"},
+		},
+		{
+			name:   "description",
+			output: "json",
+			data: struct {
+				Pod      *v1.Pod
+				NodeName string
+			}{Pod: testPod("web", "node-a"), NodeName: "node-a"},
+			want:    []string{`"NodeName": "node-a"`, `"name": "web"`},
+			wantNot: []string{"managedFields"},
+		},
+		{
+			name:   "description without a state",
+			output: "json",
+			data: struct {
+				Pod      *v1.Pod
+				NodeName string
+			}{NodeName: "node-a"},
+			want: []string{`"Pod": null`, `"NodeName": "node-a"`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.noHeaders, p.showManagedFields = tc.noHeaders, tc.showManagedFields
+			pod := testPod("web", "node-a")
+			data := tc.data
+			if data == nil {
+				data = pod
+			}
+			buf := &bytes.Buffer{}
+			if err := p.PrintObject(buf, Item{Resource: "pod", Name: "web", Data: data}, testView); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, buf.String())
+				}
+			}
+			for _, notWant := range tc.wantNot {
+				if strings.Contains(buf.String(), notWant) {
+					t.Errorf("output contains %q:\n%s", notWant, buf.String())
+				}
+			}
+			// The object is left as it was, with its managedFields
+			if len(pod.ManagedFields) != 1 {
+				t.Errorf("printing changed the object's managedFields to %v", pod.ManagedFields)
+			}
+		})
+	}
+}
+
+func TestPrintList(t *testing.T) {
+	items := []Item{
+		{Resource: "pod", Name: "web", Data: testPod("web", "node-a")},
+		{Resource: "pod", Name: "api", Data: testPod("api", "")},
+	}
+	for _, tc := range []struct {
+		name      string
+		output    string
+		noHeaders bool
+		want      string
+	}{
+		{
+			name:   "table",
+			output: "",
+			want:   "NAME   NODE\nweb    node-a\n",
+		},
+		{
+			name:      "table without headers",
+			output:    "",
+			noHeaders: true,
+			want:      "web    node-a\n",
+		},
+		{
+			name:   "name",
+			output: "name",
+			want:   "pod/web\npod/api\n",
+		},
+		{
+			name:   "jsonpath over the List",
+			output: "jsonpath={.kind}: {.items[*].metadata.name}",
+			want:   "List: web api\n",
+		},
+		{
+			name:   "go-template over the List",
+			output: `go-template={{range .items}}{{.metadata.name}} {{end}}`,
+			want:   "web api \n",
+		},
+		{
+			name:   "custom-columns",
+			output: "custom-columns=NAME:.metadata.name,NODE:.spec.nodeName",
+			want:   "NAME   NODE\nweb    node-a\napi    <none>\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.noHeaders = tc.noHeaders
+			view := testView
+			view.Table = true
+			buf := &bytes.Buffer{}
+			if err := p.PrintList(buf, items, view); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, output := range []string{
+		"xml",
+		"yaml=full",
+		"jsonpath",
+		"jsonpath={.metadata.name",
+		"go-template={{.metadata.name}",
+		"go-template={{if}}",
+		"custom-columns",
+		"custom-columns=NAME",
+		"custom-columns=:.metadata.name",
+		"custom-columns=NAME:{.metadata.name",
+	} {
+		t.Run(output, func(t *testing.T) {
+			if _, err := New(output); err == nil {
+				t.Errorf("expected an error for -o %s", output)
+			}
+		})
+	}
+}
+
+func TestPrintErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		view   View
+	}{
+		{
+			name:   "wide without a wide table",
+			output: "wide",
+			view:   View{Text: testView.Text},
+		},
+		{
+			name:   "go-template that fails to execute",
+			output: `go-template={{template "missing"}}`,
+			view:   testView,
+		},
+		{
+			name:   "jsonpath that fails to execute",
+			output: "jsonpath={.metadata.name[0]}",
+			view:   testView,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.PrintObject(&bytes.Buffer{}, Item{Resource: "pod", Name: "web", Data: testPod("web", "node-a")}, tc.view); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	auditmodel "github.com/joinnis/kubereplay/pkg/audit/model"
+	"github.com/samber/lo"
+)
+
+// Event is an audit event of a fixture log. Fields that are left empty are filled in with the values
+// of a ResponseComplete event logged at the RequestResponse level by admin.
+type Event struct {
+	AuditID     string
+	Level       string
+	Verb        string
+	APIGroup    string
+	Resource    string
+	Subresource string
+	Namespace   string
+	Name        string
+	// RequestURI defaults to the URI of the object, or of its collection for creates
+	RequestURI string
+	User       string
+	Time       time.Time
+	// RequestObject and ResponseObject are JSON, and are left out of the event when they're empty
+	RequestObject  string
+	ResponseObject string
+}
+
+// WriteFile writes lines to a file in a temporary directory, and returns its path
+func WriteFile(t testing.TB, name string, lines ...string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// WriteAuditLog writes an audit log with one event per line, and returns its path
+func WriteAuditLog(t testing.TB, events ...Event) string {
+	t.Helper()
+	lines := make([]string, len(events))
+	for i, e := range events {
+		line, err := json.Marshal(e.audit(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = string(line)
+	}
+	return WriteFile(t, "audit.log", lines...)
+}
+
+// AuditEvents returns the events as a stream of audit events, like a provider would read them from
+// an audit log
+func AuditEvents(t testing.TB, events ...Event) iter.Seq2[auditmodel.Event, error] {
+	t.Helper()
+	decoded := make([]auditmodel.Event, len(events))
+	for i, e := range events {
+		line, err := json.Marshal(e.audit(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(line, &decoded[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func(yield func(auditmodel.Event, error) bool) {
+		for _, e := range decoded {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// audit returns the event in the audit.k8s.io/v1 format, numbering it with i when it has no ID
+func (e Event) audit(i int) map[string]any {
+	apiVersion := "v1"
+	if e.APIGroup != "" {
+		apiVersion = e.APIGroup + "/v1"
+	}
+	if e.RequestURI == "" {
+		// Core resources are served under /api and every other group under /apis/<group>
+		e.RequestURI = "/api/v1"
+		if e.APIGroup != "" {
+			e.RequestURI = "/apis/" + apiVersion
+		}
+		if e.Namespace != "" {
+			e.RequestURI = path.Join(e.RequestURI, "namespaces", e.Namespace)
+		}
+		e.RequestURI = path.Join(e.RequestURI, e.Resource)
+		if e.Verb != "create" || e.Subresource != "" {
+			e.RequestURI = path.Join(e.RequestURI, e.Name, e.Subresource)
+		}
+	}
+	event := map[string]any{
+		"kind":       "Event",
+		"apiVersion": "audit.k8s.io/v1",
+		"level":      lo.CoalesceOrEmpty(e.Level, "RequestResponse"),
+		"auditID":    lo.CoalesceOrEmpty(e.AuditID, fmt.Sprintf("a%d", i)),
+		"stage":      "ResponseComplete",
+		"requestURI": e.RequestURI,
+		"verb":       e.Verb,
+		"user":       map[string]string{"username": lo.CoalesceOrEmpty(e.User, "admin")},
+		"objectRef": map[string]string{
+			"resource":    e.Resource,
+			"apiGroup":    e.APIGroup,
+			"apiVersion":  "v1",
+			"namespace":   e.Namespace,
+			"name":        e.Name,
+			"subresource": e.Subresource,
+		},
+		"requestReceivedTimestamp": e.Time.UTC().Format(time.RFC3339Nano),
+		"stageTimestamp":           e.Time.UTC().Format(time.RFC3339Nano),
+	}
+	if e.RequestObject != "" {
+		event["requestObject"] = json.RawMessage(e.RequestObject)
+	}
+	if e.ResponseObject != "" {
+		event["responseObject"] = json.RawMessage(e.ResponseObject)
+	}
+	return event
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// CaptureOutput returns what f writes to stdout and stderr, failing the test if f returns an error
+// or doesn't return in time, like when a lookback never ends
+func CaptureOutput(t testing.TB, f func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+	done := make(chan error)
+	go func() { done <- f() }()
+	select {
+	case err := <-done:
+		w.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		w.Close()
+		t.Fatalf("didn't return, output ends with:\n%s", lastLines(<-output, 5))
+	}
+	return <-output
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.Join(lines[max(len(lines)-n, 0):], "\n")
+}